@@ -0,0 +1,41 @@
+package influxdb
+
+import (
+	"math"
+	"strconv"
+	"sync"
+)
+
+// numericTagKinds decides which tags are promoted to numeric fields when
+// AutoFieldNumericTags is enabled. The kind of a key is fixed by its first numeric value,
+// so a field never changes its type during a test run, InfluxDB would reject the points otherwise.
+type numericTagKinds struct {
+	kinds sync.Map
+}
+
+// fieldValue returns the typed value of the tag, or false when the tag has to stay a tag.
+// A key seen first with an integer value is an Int, the following values that aren't integers
+// are kept as tags. A key seen first with a float value is a Float and it accepts integers too.
+func (n *numericTagKinds) fieldValue(key, val string) (interface{}, bool) {
+	i, ierr := strconv.ParseInt(val, 10, 64)
+	f, ferr := strconv.ParseFloat(val, 64)
+	if ferr != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+
+	kind := Float
+	if ierr == nil {
+		kind = Int
+	}
+	if k, loaded := n.kinds.LoadOrStore(key, kind); loaded {
+		kind = k.(FieldKind) //nolint:forcetypeassert
+	}
+
+	if kind == Float {
+		return f, true
+	}
+	if ierr != nil {
+		return nil, false
+	}
+	return i, true
+}
@@ -0,0 +1,59 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+)
+
+// writeBackoff pauses the writes when the server responds with a Retry-After header,
+// e.g. for a 429 when the rate limit of the InfluxDB Cloud's plan is exceeded.
+// It is shared by all the concurrent writes, so they all back off.
+type writeBackoff struct {
+	clock clock
+	max   time.Duration
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+// record pauses the writes for the duration requested by the error, capped by max,
+// it returns the pause or zero if the error doesn't request it.
+func (b *writeBackoff) record(err error) time.Duration {
+	var serr *http2.Error
+	if !errors.As(err, &serr) || serr.RetryAfter == 0 {
+		return 0
+	}
+	d := time.Duration(serr.RetryAfter) * time.Second
+	if b.max > 0 && d > b.max {
+		d = b.max
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until := b.clock.Now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+	return d
+}
+
+// wait blocks until the pause, if any, is over or the context is done.
+func (b *writeBackoff) wait(ctx context.Context) error {
+	b.mu.Lock()
+	until := b.until
+	b.mu.Unlock()
+
+	d := until.Sub(b.clock.Now())
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-b.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
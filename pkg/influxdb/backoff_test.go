@@ -0,0 +1,37 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBackoff(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := &writeBackoff{clock: newFakeClock(now), max: 10 * time.Second}
+	assert.Zero(t, b.record(errors.New("connection refused")))
+	assert.Zero(t, b.record(&http2.Error{StatusCode: 500}))
+	require.NoError(t, b.wait(context.Background()))
+
+	retryAfter := func(s uint) error {
+		return fmt.Errorf("write failed: %w", &http2.Error{StatusCode: 429, RetryAfter: s})
+	}
+	assert.Equal(t, 3*time.Second, b.record(retryAfter(3)))
+	// capped by the max
+	assert.Equal(t, 10*time.Second, b.record(retryAfter(60)))
+	// a shorter pause doesn't reduce the current one
+	assert.Equal(t, time.Second, b.record(retryAfter(1)))
+	assert.Equal(t, now.Add(10*time.Second), b.until)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, b.wait(ctx), context.Canceled)
+}
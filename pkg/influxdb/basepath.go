@@ -0,0 +1,40 @@
+package influxdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkBasePath returns an error if the BasePath option isn't an absolute path
+// or it is used with the telegraf flavor, that doesn't use HTTP.
+func checkBasePath(c Config) error {
+	if c.BasePath.String == "" {
+		return nil
+	}
+	if c.Flavor.String == FlavorTelegraf {
+		return fmt.Errorf("the BasePath option isn't supported by the %s flavor", FlavorTelegraf)
+	}
+	if !strings.HasPrefix(c.BasePath.String, "/") {
+		return fmt.Errorf("the BasePath option must be an absolute path, got %q", c.BasePath.String)
+	}
+	return nil
+}
+
+// splitBasePath splits the URL's path in the base path, where InfluxDB is mounted,
+// and the bucket. Without a base path, the whole path is the bucket.
+func splitBasePath(path, basePath string) (string, error) {
+	base := strings.TrimSuffix(basePath, "/")
+	if base == "" {
+		return strings.TrimPrefix(path, "/"), nil
+	}
+	if path != base && !strings.HasPrefix(path, base+"/") {
+		return "", fmt.Errorf("the URL's path (%s) doesn't start with the base path (%s)", path, basePath)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(path, base), "/"), nil
+}
+
+// serverURL returns the URL of the HTTP API, the address followed by the base path,
+// so the writes are sent to <base>/api/v2/write.
+func serverURL(conf Config) string {
+	return strings.TrimSuffix(httpAddr(conf.Addr.String), "/") + strings.TrimSuffix(conf.BasePath.String, "/")
+}
@@ -0,0 +1,99 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/output"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestParseURLBasePath(t *testing.T) {
+	t.Parallel()
+
+	testdata := map[string]struct {
+		url      string
+		basePath string
+		exp      Config
+	}{
+		"bucket in path": {
+			url: "https://gw.example.com/influx",
+			exp: Config{Addr: null.StringFrom("https://gw.example.com"), Bucket: null.StringFrom("influx")},
+		},
+		"base path": {
+			url:      "https://gw.example.com/influx",
+			basePath: "/influx",
+			exp:      Config{Addr: null.StringFrom("https://gw.example.com")},
+		},
+		"base path and bucket": {
+			url:      "https://gw.example.com/influx/k6",
+			basePath: "/influx",
+			exp:      Config{Addr: null.StringFrom("https://gw.example.com"), Bucket: null.StringFrom("k6")},
+		},
+		"base path with trailing slash": {
+			url:      "https://gw.example.com/influx/k6",
+			basePath: "/influx/",
+			exp:      Config{Addr: null.StringFrom("https://gw.example.com"), Bucket: null.StringFrom("k6")},
+		},
+		"base path in query": {
+			url: "https://gw.example.com/proxy/influx/k6?basePath=/proxy/influx",
+			exp: Config{
+				Addr:     null.StringFrom("https://gw.example.com"),
+				Bucket:   null.StringFrom("k6"),
+				BasePath: null.StringFrom("/proxy/influx"),
+			},
+		},
+	}
+	for name, tc := range testdata {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			config, err := parseURL(tc.url, tc.basePath)
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, config)
+		})
+	}
+
+	_, err := parseURL("https://gw.example.com/influxdb/k6", "/influx")
+	assert.ErrorContains(t, err, "the URL's path (/influxdb/k6) doesn't start with the base path (/influx)")
+}
+
+func TestGetConsolidatedConfigBasePath(t *testing.T) {
+	t.Parallel()
+
+	config, err := GetConsolidatedConfig(nil,
+		map[string]string{"K6_INFLUXDB_BASE_PATH": "/influx"}, "https://gw.example.com/influx/k6")
+	require.NoError(t, err)
+	assert.Equal(t, "https://gw.example.com", config.Addr.String)
+	assert.Equal(t, "k6", config.Bucket.String)
+	assert.Equal(t, "https://gw.example.com/influx", serverURL(config))
+}
+
+func TestOutputBasePath(t *testing.T) {
+	t.Parallel()
+
+	var path, bucket string
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		path, bucket = r.URL.Path, r.URL.Query().Get("bucket")
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	logger, _ := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/influx/k6",
+		JSONConfig:     json.RawMessage(`{"token":"secret","organization":"testorg","basePath":"/influx"}`),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, o.Preflight(context.Background()))
+	assert.Equal(t, "/influx/api/v2/write", path)
+	assert.Equal(t, "k6", bucket)
+}
@@ -0,0 +1,105 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/domain"
+)
+
+// ensureBucket checks if the configured bucket exists, creating it if it doesn't.
+func (o *Output) ensureBucket(ctx context.Context) error {
+	org, bucket := o.config.Organization.String, o.config.Bucket.String
+	if org == "" {
+		return errors.New("the Organization option is required for creating the bucket")
+	}
+
+	found, err := o.client.APIClient().GetBuckets(ctx, &domain.GetBucketsParams{
+		Org:  &org,
+		Name: &bucket,
+	})
+	if err != nil {
+		return bucketError("check if the bucket exists", err)
+	}
+	if found.Buckets != nil && len(*found.Buckets) > 0 {
+		o.logger.WithField("bucket", bucket).Debug("The bucket already exists")
+		return nil
+	}
+
+	organization, err := o.client.OrganizationsAPI().FindOrganizationByName(ctx, org)
+	if err != nil {
+		return bucketError("find the organization", err)
+	}
+
+	req := domain.PostBucketRequest{
+		Name:           bucket,
+		OrgID:          *organization.Id,
+		RetentionRules: &domain.RetentionRules{},
+	}
+	if o.config.BucketRetention.Valid {
+		*req.RetentionRules = append(*req.RetentionRules, domain.RetentionRule{
+			EverySeconds: int64(time.Duration(o.config.BucketRetention.Duration).Seconds()),
+		})
+	}
+	if o.config.BucketSchemaType.String != "" {
+		schemaType := domain.SchemaType(o.config.BucketSchemaType.String)
+		req.SchemaType = &schemaType
+	}
+	// the buckets API's helpers don't support the schema's type
+	params := &domain.PostBucketsAllParams{Body: domain.PostBucketsJSONRequestBody(req)}
+	if _, err := o.client.APIClient().PostBuckets(ctx, params); err != nil {
+		return bucketError("create the bucket", err)
+	}
+	o.logger.WithField("bucket", bucket).Info("The bucket has been created")
+	return nil
+}
+
+// minBucketRetention is the shortest retention period accepted by InfluxDB,
+// zero means an infinite retention.
+const minBucketRetention = time.Hour
+
+// checkBucket returns an error if the retention or the schema's type of the created bucket aren't valid.
+func checkBucket(conf Config) error {
+	if retention := time.Duration(conf.BucketRetention.Duration); retention < 0 ||
+		(retention > 0 && retention < minBucketRetention) {
+		return fmt.Errorf("the BucketRetention option must be zero, for an infinite retention, or at least %s",
+			minBucketRetention)
+	}
+	switch domain.SchemaType(conf.BucketSchemaType.String) {
+	case "", domain.SchemaTypeImplicit, domain.SchemaTypeExplicit:
+		return nil
+	default:
+		return fmt.Errorf("an invalid bucket schema type (%s) is specified, the allowed values are: %s and %s",
+			conf.BucketSchemaType.String, domain.SchemaTypeImplicit, domain.SchemaTypeExplicit)
+	}
+}
+
+// bucketError wraps an error returned by the buckets API,
+// adding a suggestion when it is caused by missing permissions.
+func bucketError(op string, err error) error {
+	if isPermissionError(err) {
+		return fmt.Errorf("couldn't %s, the token doesn't have the required permissions: "+
+			"create the bucket manually or use a token with the read and write permissions for buckets: %w", op, err)
+	}
+	return fmt.Errorf("couldn't %s: %w", op, err)
+}
+
+// isPermissionError reports whether the error returned by the InfluxDB API
+// is caused by an invalid token or by a token without the required permissions.
+func isPermissionError(err error) bool {
+	msg := err.Error()
+	for _, prefix := range []string{
+		string(domain.ErrorCodeUnauthorized),
+		string(domain.ErrorCodeForbidden),
+		"401 ",
+		"403 ",
+	} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
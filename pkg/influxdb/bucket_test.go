@@ -0,0 +1,170 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+)
+
+// bucketsAPIMock mocks the InfluxDB endpoints used for creating a bucket.
+type bucketsAPIMock struct {
+	exists    bool
+	forbidden bool
+
+	mu      sync.Mutex
+	created map[string]interface{}
+}
+
+func (m *bucketsAPIMock) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if m.forbidden {
+		rw.WriteHeader(http.StatusForbidden)
+		_, _ = rw.Write([]byte(`{"code":"forbidden","message":"insufficient permissions for read:orgs/myorg/buckets"}`))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/buckets":
+		if r.URL.Query().Get("name") != "mybucket" || r.URL.Query().Get("org") != "myorg" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if m.exists {
+			_, _ = rw.Write([]byte(`{"buckets":[{"id":"b1","name":"mybucket","orgID":"o1","retentionRules":[]}]}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"buckets":[]}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/orgs":
+		_, _ = rw.Write([]byte(`{"orgs":[{"id":"o1","name":"myorg"}]}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/buckets":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if err := json.NewDecoder(r.Body).Decode(&m.created); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"id":"b1","name":"mybucket","orgID":"o1","retentionRules":[]}`))
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (m *bucketsAPIMock) createdBucket() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.created
+}
+
+func TestOutputCreateBucket(t *testing.T) {
+	t.Parallel()
+
+	start := func(t *testing.T, mock *bucketsAPIMock, jsonConf string) error {
+		ts := httptest.NewServer(mock)
+		t.Cleanup(ts.Close)
+
+		o, err := New(output.Params{
+			Logger:         testutils.NewLogger(t),
+			ConfigArgument: ts.URL + "/mybucket",
+			JSONConfig:     json.RawMessage(jsonConf),
+		})
+		require.NoError(t, err)
+		if err := o.Start(); err != nil {
+			return err
+		}
+		return o.Stop()
+	}
+
+	t.Run("Exists", func(t *testing.T) {
+		t.Parallel()
+		mock := &bucketsAPIMock{exists: true}
+		require.NoError(t, start(t, mock, `{"organization":"myorg","createBucket":true}`))
+		assert.Nil(t, mock.createdBucket())
+	})
+
+	t.Run("Created", func(t *testing.T) {
+		t.Parallel()
+		mock := &bucketsAPIMock{}
+		require.NoError(t, start(t, mock, `{"organization":"myorg","createBucket":true,"bucketRetention":"24h"}`))
+
+		created := mock.createdBucket()
+		require.NotNil(t, created)
+		assert.Equal(t, "mybucket", created["name"])
+		assert.Equal(t, "o1", created["orgID"])
+		assert.Equal(t, `[{"everySeconds":86400}]`, toJSON(t, created["retentionRules"]))
+		assert.NotContains(t, created, "schemaType")
+	})
+
+	t.Run("SchemaType", func(t *testing.T) {
+		t.Parallel()
+		mock := &bucketsAPIMock{}
+		require.NoError(t, start(t, mock,
+			`{"organization":"myorg","createBucket":true,"bucketRetention":"720h","bucketSchemaType":"explicit"}`))
+
+		created := mock.createdBucket()
+		require.NotNil(t, created)
+		assert.Equal(t, `[{"everySeconds":2592000}]`, toJSON(t, created["retentionRules"]))
+		assert.Equal(t, "explicit", created["schemaType"])
+	})
+
+	t.Run("InfiniteRetention", func(t *testing.T) {
+		t.Parallel()
+		mock := &bucketsAPIMock{}
+		require.NoError(t, start(t, mock, `{"organization":"myorg","createBucket":true}`))
+
+		created := mock.createdBucket()
+		require.NotNil(t, created)
+		assert.Equal(t, `[]`, toJSON(t, created["retentionRules"]))
+	})
+
+	t.Run("PermissionDenied", func(t *testing.T) {
+		t.Parallel()
+		err := start(t, &bucketsAPIMock{forbidden: true}, `{"organization":"myorg","createBucket":true}`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the token doesn't have the required permissions")
+		assert.Contains(t, err.Error(), "insufficient permissions")
+	})
+
+	t.Run("OrganizationRequired", func(t *testing.T) {
+		t.Parallel()
+		err := start(t, &bucketsAPIMock{}, `{"createBucket":true}`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Organization option is required")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		// the mock would fail all the requests
+		require.NoError(t, start(t, &bucketsAPIMock{forbidden: true}, `{"organization":"myorg"}`))
+	})
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestIsPermissionError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"unauthorized: unauthorized access":   true,
+		"forbidden: insufficient permissions": true,
+		"403 Forbidden: denied":               true,
+		"401 Unauthorized":                    true,
+		"not found: organization not found":   false,
+		"internal error: boom":                false,
+	}
+	for msg, exp := range tests {
+		assert.Equal(t, exp, isPermissionError(errors.New(msg)), msg)
+	}
+}
@@ -0,0 +1,71 @@
+package influxdb
+
+import (
+	"fmt"
+)
+
+// SetTestRunStopCallback receives from k6, before the output is started,
+// the function aborting the test, that is called when the write error budget is exceeded.
+func (o *Output) SetTestRunStopCallback(stop func(error)) {
+	o.testRunStop = stop
+}
+
+// checkWriteErrorBudget returns an error if the error budget's options aren't valid.
+func checkWriteErrorBudget(conf Config) error {
+	if conf.WriteErrorBudgetMinWrites.Int64 < 0 {
+		return fmt.Errorf("the WriteErrorBudgetMinWrites option can't be a negative number")
+	}
+	if !conf.MaxWriteErrorRate.Valid {
+		return nil
+	}
+	if rate := conf.MaxWriteErrorRate.Float64; rate < 0 || rate >= 1 {
+		return fmt.Errorf("the MaxWriteErrorRate option must be in the [0, 1) range, got %v", rate)
+	}
+	if conf.AsyncWrite.Bool {
+		return fmt.Errorf("the MaxWriteErrorRate option isn't supported with AsyncWrite, " +
+			"the async writer's outcomes aren't tracked")
+	}
+	return nil
+}
+
+// recordWriteOutcome counts the write request and checks the error budget,
+// it is checked only after WriteErrorBudgetMinWrites writes, so a few failures
+// at the start of the run don't exceed it. The first time it is exceeded,
+// the error is logged and the test is aborted, if k6 supports it.
+func (o *Output) recordWriteOutcome(failed bool) {
+	writes, failedWrites := o.stats.recordWrite(failed)
+	if writes < int(o.config.WriteErrorBudgetMinWrites.Int64) {
+		return
+	}
+	err := o.checkWriteErrorBudget(writes, failedWrites)
+	if err == nil || !o.stats.exceedWriteErrorBudget() {
+		return
+	}
+	o.logger.WithError(err).Error("The metrics sent to InfluxDB are incomplete, aborting the test")
+	if o.testRunStop != nil {
+		o.testRunStop(err)
+	}
+}
+
+// checkWriteErrorBudget returns an error if the rate of the failed writes exceeds the MaxWriteErrorRate.
+func (o *Output) checkWriteErrorBudget(writes, failedWrites int) error {
+	if !o.config.MaxWriteErrorRate.Valid || writes == 0 {
+		return nil
+	}
+	maxRate := o.config.MaxWriteErrorRate.Float64
+	if float64(failedWrites)/float64(writes) <= maxRate {
+		return nil
+	}
+	return fmt.Errorf("the InfluxDB write error budget has been exceeded: %d of %d writes have failed, "+
+		"more than the MaxWriteErrorRate (%v)", failedWrites, writes, maxRate)
+}
+
+// finalWriteErrorBudget checks the error budget over the whole run, when the output is stopped,
+// also if the writes are less than WriteErrorBudgetMinWrites.
+func (o *Output) finalWriteErrorBudget(stats Stats) error {
+	err := o.checkWriteErrorBudget(stats.Writes, stats.FailedWrites)
+	if err != nil && o.stats.exceedWriteErrorBudget() {
+		o.logger.WithError(err).Error("The metrics sent to InfluxDB are incomplete")
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputWriteErrorBudget(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		failed      int64
+		expExceeded bool
+	}{
+		// 3 failed writes of 4
+		"Exceeded": {failed: 3, expExceeded: true},
+		// 1 failed write of 4
+		"Within": {failed: 1, expExceeded: false},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int64
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+				n := requests.Add(1)
+				if n <= tc.failed {
+					rw.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			defer ts.Close()
+
+			o, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig: json.RawMessage(
+					`{"maxWriteErrorRate":0.5,"writeErrorBudgetMinWrites":4,"pushInterval":"1h"}`),
+			})
+			require.NoError(t, err)
+			var stopErrs []error
+			o.SetTestRunStopCallback(func(err error) { stopErrs = append(stopErrs, err) })
+			require.NoError(t, o.Start())
+
+			registry := metrics.NewRegistry()
+			metric, err := registry.NewMetric("test_counter", metrics.Counter)
+			require.NoError(t, err)
+			for i := 0; i < 5; i++ {
+				o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+					TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+					Time:       time.Now(),
+					Value:      1,
+				}})
+				_ = o.Flush()
+				if i == 3 {
+					// the budget is checked after the minimum number of writes
+					assert.Equal(t, tc.expExceeded, o.Stats().WriteErrorBudgetExceeded)
+				}
+			}
+
+			err = o.Stop()
+			stats := o.Stats()
+			assert.Equal(t, 5, stats.Writes)
+			assert.Equal(t, tc.expExceeded, stats.WriteErrorBudgetExceeded)
+			if !tc.expExceeded {
+				assert.NoError(t, err)
+				assert.Empty(t, stopErrs)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "the InfluxDB write error budget has been exceeded: 3 of 5 writes have failed")
+			// the test is aborted only once
+			require.Len(t, stopErrs, 1)
+			assert.Contains(t, stopErrs[0].Error(), "3 of 4 writes have failed")
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package influxdb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	influxdblog "github.com/influxdata/influxdb-client-go/v2/log"
+	"github.com/sirupsen/logrus"
+)
+
+// clientLog is the package-wide logger of influxdb-client-go. It discards the logs,
+// unless an output enabled them with EnableClientLog.
+var clientLog = &clientLogger{} //nolint:gochecknoglobals // the client's logger is package-wide
+
+// clientLogger routes the internal logs of influxdb-client-go to the logger of the last output
+// created with EnableClientLog, until it is stopped. The client's logger is global,
+// so it is shared by all the outputs.
+type clientLogger struct {
+	mu     sync.RWMutex
+	logger *logrus.Entry
+}
+
+// attach routes the client's logs to the logger, it returns the logger used for them.
+func (cl *clientLogger) attach(logger logrus.FieldLogger) *logrus.Entry {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.logger = logger.WithField("source", "influxdb-client-go")
+	return cl.logger
+}
+
+// detach discards the client's logs, if they are still routed to the logger returned by attach,
+// so the logs aren't detached from an output attached after it.
+func (cl *clientLogger) detach(logger *logrus.Entry) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.logger == logger {
+		cl.logger = nil
+	}
+}
+
+func (cl *clientLogger) log(level logrus.Level, msg string) {
+	cl.mu.RLock()
+	logger := cl.logger
+	cl.mu.RUnlock()
+	if logger == nil {
+		return
+	}
+	msg = strings.TrimSpace(msg)
+	switch level {
+	case logrus.ErrorLevel:
+		logger.Error(msg)
+	case logrus.WarnLevel:
+		logger.Warn(msg)
+	default:
+		logger.Debug(msg)
+	}
+}
+
+// Debugf logs at the debug level.
+func (cl *clientLogger) Debugf(format string, v ...interface{}) {
+	cl.log(logrus.DebugLevel, fmt.Sprintf(format, v...))
+}
+
+// Debug logs at the debug level.
+func (cl *clientLogger) Debug(msg string) {
+	cl.log(logrus.DebugLevel, msg)
+}
+
+// Infof logs at the debug level, the client logs each request at the info level.
+func (cl *clientLogger) Infof(format string, v ...interface{}) {
+	cl.log(logrus.DebugLevel, fmt.Sprintf(format, v...))
+}
+
+// Info logs at the debug level, the client logs each request at the info level.
+func (cl *clientLogger) Info(msg string) {
+	cl.log(logrus.DebugLevel, msg)
+}
+
+// Warnf logs at the warning level.
+func (cl *clientLogger) Warnf(format string, v ...interface{}) {
+	cl.log(logrus.WarnLevel, fmt.Sprintf(format, v...))
+}
+
+// Warn logs at the warning level.
+func (cl *clientLogger) Warn(msg string) {
+	cl.log(logrus.WarnLevel, msg)
+}
+
+// Errorf logs at the error level.
+func (cl *clientLogger) Errorf(format string, v ...interface{}) {
+	cl.log(logrus.ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+// Error logs at the error level.
+func (cl *clientLogger) Error(msg string) {
+	cl.log(logrus.ErrorLevel, msg)
+}
+
+// SetLogLevel is a no-op, the level is filtered by the output's logger.
+// The client sets it from its options, which are different for each output.
+func (cl *clientLogger) SetLogLevel(uint) {}
+
+// LogLevel returns the debug level when the logs are routed, so the client doesn't skip any of them.
+func (cl *clientLogger) LogLevel() uint {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+	if cl.logger == nil {
+		return influxdblog.ErrorLevel
+	}
+	return influxdblog.DebugLevel
+}
+
+// SetPrefix is a no-op, the logs are marked by a field.
+func (cl *clientLogger) SetPrefix(string) {}
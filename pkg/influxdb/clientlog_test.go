@@ -0,0 +1,114 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	influxdblog "github.com/influxdata/influxdb-client-go/v2/log"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestClientLogger(t *testing.T) {
+	t.Parallel()
+
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	cl := &clientLogger{}
+	cl.Error("discarded")
+	assert.Empty(t, hook.AllEntries())
+	assert.Equal(t, influxdblog.ErrorLevel, cl.LogLevel())
+
+	cl.attach(logger)
+	assert.Equal(t, influxdblog.DebugLevel, cl.LogLevel())
+	cl.Debugf("debug %d", 1)
+	cl.Info("info")
+	cl.Warnf("warn %d", 2)
+	cl.Errorf("point encoding error: %s\n", "invalid")
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 4)
+	expected := []struct {
+		level logrus.Level
+		msg   string
+	}{
+		{logrus.DebugLevel, "debug 1"},
+		{logrus.DebugLevel, "info"},
+		{logrus.WarnLevel, "warn 2"},
+		{logrus.ErrorLevel, "point encoding error: invalid"},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.level, entries[i].Level)
+		assert.Equal(t, e.msg, entries[i].Message)
+		assert.Equal(t, "influxdb-client-go", entries[i].Data["source"])
+	}
+}
+
+func TestClientLoggerDetach(t *testing.T) {
+	t.Parallel()
+
+	first, firstHook := logtest.NewNullLogger()
+	second, secondHook := logtest.NewNullLogger()
+
+	cl := &clientLogger{}
+	firstLogger := cl.attach(first)
+	secondLogger := cl.attach(second)
+	// the first output is stopped after the second one has been created
+	cl.detach(firstLogger)
+	cl.Error("routed")
+	assert.Empty(t, firstHook.AllEntries())
+	require.Len(t, secondHook.AllEntries(), 1)
+
+	cl.detach(secondLogger)
+	cl.Error("discarded")
+	assert.Len(t, secondHook.AllEntries(), 1)
+	assert.Equal(t, influxdblog.ErrorLevel, cl.LogLevel())
+}
+
+func TestOutputEnableClientLog(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"enableClientLog":true}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: metric,
+			Tags:   registry.RootTagSet().With("method", "GET"),
+		},
+		Time:  time.Now(),
+		Value: 1,
+	}})
+	require.NoError(t, o.Stop())
+	require.Len(t, lc.Lines(), 1)
+
+	var requests int
+	for _, e := range hook.AllEntries() {
+		if e.Data["source"] == "influxdb-client-go" && strings.HasPrefix(e.Message, "HTTP POST req to") {
+			requests++
+		}
+	}
+	assert.Positive(t, requests, "the client's logs are expected to be routed to the output's logger")
+}
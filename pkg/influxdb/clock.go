@@ -0,0 +1,91 @@
+package influxdb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// clock is the source of the time of the flushes, of the write pauses and of the written timestamps,
+// the tests replace the real clock with a fake one they can advance.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) ticker
+}
+
+// ticker is the clock's ticker, as time.Ticker.
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// tickerFlusher calls the flush callback on each tick of the clock's ticker,
+// as output.PeriodicFlusher it calls the callback a last time when it is stopped.
+type tickerFlusher struct {
+	ticker        ticker
+	flushCallback func()
+	stop          chan struct{}
+	stopped       chan struct{}
+	once          sync.Once
+}
+
+func newTickerFlusher(c clock, period time.Duration, flushCallback func()) (*tickerFlusher, error) {
+	if period <= 0 {
+		return nil, errors.New("the flush period must be a positive duration")
+	}
+	tf := &tickerFlusher{
+		// it is created before the goroutine, so the first tick is a period after the creation
+		ticker:        c.NewTicker(period),
+		flushCallback: flushCallback,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go tf.run()
+	return tf, nil
+}
+
+func (tf *tickerFlusher) run() {
+	defer close(tf.stopped)
+	defer tf.ticker.Stop()
+	for {
+		select {
+		case <-tf.ticker.C():
+			tf.flushCallback()
+		case <-tf.stop:
+			tf.flushCallback()
+			return
+		}
+	}
+}
+
+// Stop flushes the last time and waits for the flush's completion.
+func (tf *tickerFlusher) Stop() {
+	tf.once.Do(func() {
+		close(tf.stop)
+	})
+	<-tf.stopped
+}
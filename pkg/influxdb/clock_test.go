@@ -0,0 +1,272 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+// fakeClock is a clock advanced only by the test, the timers and the tickers
+// fire when the time reaches them.
+type fakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	c := &fakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.addWaiter(d, 0).ch
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	return &fakeTicker{clock: c, waiter: c.addWaiter(d, d)}
+}
+
+func (c *fakeClock) addWaiter(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+	return w
+}
+
+func (c *fakeClock) removeWaiter(w *fakeWaiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, cw := range c.waiters {
+		if cw == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Advance moves the time forward, firing the reached timers and tickers.
+// As time.Ticker, a ticker drops the ticks if its channel isn't drained.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.period > 0 {
+			for !w.at.After(c.now) {
+				w.at = w.at.Add(w.period)
+			}
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+}
+
+// BlockUntil waits until n timers or tickers are waiting.
+func (c *fakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// setClock replaces the output's clock, including the one pausing the writes.
+func setClock(o *Output, c clock) {
+	o.clock = c
+	o.backoff.clock = c
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.removeWaiter(t.waiter)
+}
+
+func TestTickerFlusher(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTickerFlusher(realClock{}, 0, func() {})
+	require.Error(t, err)
+
+	fc := newFakeClock(time.Unix(0, 0))
+	flushed := make(chan struct{}, 1)
+	tf, err := newTickerFlusher(fc, time.Minute, func() { flushed <- struct{}{} })
+	require.NoError(t, err)
+
+	fc.Advance(59 * time.Second)
+	select {
+	case <-flushed:
+		t.Fatal("the flush is expected only after the period")
+	default:
+	}
+	for i := 0; i < 3; i++ {
+		fc.Advance(time.Minute)
+		<-flushed
+	}
+
+	go tf.Stop()
+	// the last flush done by Stop
+	<-flushed
+	tf.Stop()
+	assert.Empty(t, fc.waiters)
+}
+
+func TestJitteredFlusherFakeClock(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeClock(time.Unix(0, 0))
+	var calls atomic.Int64
+	jf := newJitteredFlusher(fc, time.Minute, 10*time.Second, func() { calls.Add(1) })
+	for i := 1; i <= 3; i++ {
+		fc.BlockUntil(1)
+		// beyond the interval with the highest jitter
+		fc.Advance(71 * time.Second)
+		require.Eventually(t, func() bool { return calls.Load() == int64(i) }, time.Second, time.Millisecond)
+	}
+	jf.Stop()
+	assert.Equal(t, int64(4), calls.Load())
+}
+
+func TestOutputFlushFakeClock(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	logger, _ := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+		// a real flush would never happen during the test
+		JSONConfig: json.RawMessage(`{"pushInterval":"1h","emitLifecycleEvents":true}`),
+	})
+	require.NoError(t, err)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fc := newFakeClock(start)
+	setClock(o, fc)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("method", "GET")},
+		Time:       start,
+		Value:      1,
+	}})
+
+	// only the lifecycle's start event
+	assert.Len(t, lc.Lines(), 1)
+	fc.Advance(time.Hour)
+	require.Eventually(t, func() bool { return len(lc.Lines()) == 2 }, time.Second, time.Millisecond)
+	assert.Contains(t, lc.Lines()[1], "test_gauge,method=GET value=1")
+
+	fc.Advance(time.Minute)
+	require.NoError(t, o.Stop())
+	lines := lc.Lines()
+	require.Len(t, lines, 3)
+	// the stop event has the fake clock's time
+	assert.Contains(t, lines[2], " "+strconv.FormatInt(start.Add(time.Hour+time.Minute).UnixNano(), 10))
+}
+
+func TestOutputRetryAfterFakeClock(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if requests.Add(1) == 1 {
+			rw.Header().Set("Retry-After", "5")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	logger, _ := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+	})
+	require.NoError(t, err)
+	// the clock is in the past, so the pause would be already over for the real clock
+	fc := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	setClock(o, fc)
+	o.ctx = context.Background()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	samples := []metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+		Time:       time.Now(),
+		Value:      1,
+	}}
+	require.Error(t, o.writeSamples(samples))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- o.writeSamples(samples)
+	}()
+	// the write waits for the pause
+	fc.BlockUntil(1)
+	fc.Advance(4 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("the write hasn't waited for the pause: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, int64(1), requests.Load())
+
+	fc.Advance(time.Second)
+	require.NoError(t, <-done)
+	assert.Equal(t, int64(2), requests.Load())
+}
@@ -0,0 +1,138 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// checkAutoConcurrency returns an error if the bounds of AutoConcurrency are invalid
+// or they don't include ConcurrentWrites, the initial concurrency.
+func checkAutoConcurrency(c Config) error {
+	if !c.AutoConcurrency.Bool {
+		return nil
+	}
+	if c.AsyncWrite.Bool {
+		return fmt.Errorf("the AutoConcurrency option isn't supported with AsyncWrite, since the flushes don't write")
+	}
+	minWrites, maxWrites := c.MinConcurrentWrites.Int64, c.MaxConcurrentWrites.Int64
+	if minWrites <= 0 {
+		return fmt.Errorf("the MinConcurrentWrites option must be a positive number")
+	}
+	if maxWrites < minWrites {
+		return fmt.Errorf("the MaxConcurrentWrites option (%d) can't be lower than MinConcurrentWrites (%d)",
+			maxWrites, minWrites)
+	}
+	if n := c.ConcurrentWrites.Int64; n < minWrites || n > maxWrites {
+		return fmt.Errorf("the ConcurrentWrites option (%d) must be between MinConcurrentWrites (%d) "+
+			"and MaxConcurrentWrites (%d) when AutoConcurrency is enabled", n, minWrites, maxWrites)
+	}
+	return nil
+}
+
+// writeSlots is a semaphore of the concurrent flushes whose size can be changed
+// while the slots are taken. When it shrinks, the taken slots beyond the new size
+// are released by the running flushes, the new flushes wait until they are.
+type writeSlots struct {
+	mu   sync.Mutex
+	size int
+	used int
+	// changed is closed, and replaced, when a slot is released or the size grows
+	changed chan struct{}
+}
+
+func newWriteSlots(size int) *writeSlots {
+	return &writeSlots{size: size, changed: make(chan struct{})}
+}
+
+// acquire takes a slot, waiting for a free one until the context is done.
+func (s *writeSlots) acquire(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if s.used < s.size {
+			s.used++
+			s.mu.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release gives back a slot taken by acquire.
+func (s *writeSlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used--
+	s.notify()
+}
+
+// resize changes the number of the slots.
+func (s *writeSlots) resize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size = size
+	s.notify()
+}
+
+// limit returns the current number of the slots.
+func (s *writeSlots) limit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *writeSlots) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// concurrencyTuner adjusts the number of the write slots to the flushes' latency with AIMD:
+// the slots increase by one after a flush that keeps up with the push interval
+// and they are halved after a flush that falls behind, or that fails, within the bounds.
+type concurrencyTuner struct {
+	slots            *writeSlots
+	minSize, maxSize int
+
+	// mu serializes the adjustments of the concurrent flushes
+	mu sync.Mutex
+}
+
+// observe records a flush's outcome and returns the new number of the slots, if it has changed.
+func (ct *concurrencyTuner) observe(d, pushInterval time.Duration, failed bool) (int, bool) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	current := ct.slots.limit()
+	size := current
+	if failed || d > pushInterval {
+		size /= 2
+		if size < ct.minSize {
+			size = ct.minSize
+		}
+	} else if size < ct.maxSize {
+		size++
+	}
+	if size == current {
+		return size, false
+	}
+	ct.slots.resize(size)
+	return size, true
+}
+
+// tuneConcurrency adjusts the concurrent writes to the flush's latency, if AutoConcurrency is enabled.
+func (o *Output) tuneConcurrency(d time.Duration, failed bool) {
+	if o.concurrencyTuner == nil {
+		return
+	}
+	pushInterval := time.Duration(o.pushInterval.Load())
+	if size, changed := o.concurrencyTuner.observe(d, pushInterval, failed); changed {
+		o.logger.WithField("concurrent_writes", size).WithField("t", d).WithField("failed", failed).
+			Debug("The concurrent writes have been adjusted to the flushes' latency")
+	}
+}
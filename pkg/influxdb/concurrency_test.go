@@ -0,0 +1,121 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestWriteSlotsResize(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	slots := newWriteSlots(2)
+	require.NoError(t, slots.acquire(ctx))
+	require.NoError(t, slots.acquire(ctx))
+
+	acquired := make(chan struct{})
+	go func() {
+		defer close(acquired)
+		assert.NoError(t, slots.acquire(ctx))
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("all the slots are taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// a grown semaphore wakes up the waiting flush
+	slots.resize(3)
+	<-acquired
+
+	// the taken slots beyond the shrunk size are released by the running flushes
+	slots.resize(1)
+	slots.release()
+	slots.release()
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, slots.acquire(timeout), context.DeadlineExceeded)
+	slots.release()
+	require.NoError(t, slots.acquire(ctx))
+	assert.Equal(t, 1, slots.limit())
+}
+
+func TestOutputAutoConcurrency(t *testing.T) {
+	t.Parallel()
+
+	fc := newFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	// the latency of a write, on the fake clock
+	var latency atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fc.Advance(time.Duration(latency.Load()))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	logger, _ := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig: json.RawMessage(`{"pushInterval":"10s","concurrentWrites":8,` +
+			`"autoConcurrency":true,"minConcurrentWrites":1,"maxConcurrentWrites":10}`),
+	})
+	require.NoError(t, err)
+	setClock(o, fc)
+	require.NoError(t, o.Start())
+	defer func() {
+		require.NoError(t, o.Stop())
+	}()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	flush := func() {
+		samples := []metrics.SampleContainer{metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+			Time:       fc.Now(),
+			Value:      1,
+		}}
+		require.NoError(t, o.writeSamples(samples))
+	}
+
+	// the slow flushes fall behind the push interval, the concurrency is halved down to the min
+	latency.Store(int64(15 * time.Second))
+	for _, expected := range []int{4, 2, 1, 1} {
+		flush()
+		assert.Equal(t, expected, o.writeSlots.limit())
+	}
+
+	// the fast flushes keep up, the concurrency increases by one
+	latency.Store(int64(time.Second))
+	for _, expected := range []int{2, 3, 4} {
+		flush()
+		assert.Equal(t, expected, o.writeSlots.limit())
+	}
+}
+
+func TestConcurrencyTunerBounds(t *testing.T) {
+	t.Parallel()
+
+	ct := &concurrencyTuner{slots: newWriteSlots(3), minSize: 2, maxSize: 4}
+	size, changed := ct.observe(time.Second, time.Second, false)
+	assert.True(t, changed)
+	assert.Equal(t, 4, size)
+	_, changed = ct.observe(time.Second, time.Second, false)
+	assert.False(t, changed, "the max is reached")
+
+	// a failed flush falls behind
+	size, _ = ct.observe(time.Millisecond, time.Second, true)
+	assert.Equal(t, 2, size)
+	_, changed = ct.observe(2*time.Second, time.Second, false)
+	assert.False(t, changed, "the min is reached")
+}
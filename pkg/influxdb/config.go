@@ -1,11 +1,17 @@
 package influxdb
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 	"github.com/mstoykov/envconfig"
 	"go.k6.io/k6/lib/types"
 	"gopkg.in/guregu/null.v3"
@@ -21,40 +27,291 @@ type Config struct {
 	PushInterval          types.NullDuration `json:"pushInterval,omitempty" envconfig:"K6_INFLUXDB_PUSH_INTERVAL"`
 	ConcurrentWrites      null.Int           `json:"concurrentWrites,omitempty" envconfig:"K6_INFLUXDB_CONCURRENT_WRITES"`
 	Precision             types.NullDuration `json:"precision,omitempty" envconfig:"K6_INFLUXDB_PRECISION"`
-	TagsAsFields          []string           `json:"tagsAsFields,omitempty" envconfig:"K6_INFLUXDB_TAGS_AS_FIELDS"`
+
+	PrecisionUnit        null.String `json:"precisionUnit,omitempty" envconfig:"K6_INFLUXDB_PRECISION_UNIT"`
+	TagsAsFields         []string    `json:"tagsAsFields,omitempty" envconfig:"K6_INFLUXDB_TAGS_AS_FIELDS"`
+	KeepExtractedTags    null.Bool   `json:"keepExtractedTags,omitempty" envconfig:"K6_INFLUXDB_KEEP_EXTRACTED_TAGS"`
+	AddRunID             null.Bool   `json:"addRunID,omitempty" envconfig:"K6_INFLUXDB_ADD_RUN_ID"`
+	RunID                null.String `json:"runID,omitempty" envconfig:"K6_INFLUXDB_RUN_ID"`
+	RunIDTag             null.String `json:"runIDTag,omitempty" envconfig:"K6_INFLUXDB_RUN_ID_TAG"`
+	DisableTagCache      null.Bool   `json:"disableTagCache,omitempty" envconfig:"K6_INFLUXDB_DISABLE_TAG_CACHE"`
+	MeasurementPrefix    null.String `json:"measurementPrefix,omitempty" envconfig:"K6_INFLUXDB_MEASUREMENT_PREFIX"`
+	MeasurementSeparator null.String `json:"measurementSeparator,omitempty" envconfig:"K6_INFLUXDB_MEASUREMENT_SEPARATOR"`
+
+	Consistency     null.String        `json:"consistency,omitempty" envconfig:"K6_INFLUXDB_CONSISTENCY"`
+	CreateBucket    null.Bool          `json:"createBucket,omitempty" envconfig:"K6_INFLUXDB_CREATE_BUCKET"`
+	BucketRetention types.NullDuration `json:"bucketRetention,omitempty" envconfig:"K6_INFLUXDB_BUCKET_RETENTION"`
+	TimestampOffset types.NullDuration `json:"timestampOffset,omitempty" envconfig:"K6_INFLUXDB_TIMESTAMP_OFFSET"`
+	ErrorLogWindow  types.NullDuration `json:"errorLogWindow,omitempty" envconfig:"K6_INFLUXDB_ERROR_LOG_WINDOW"`
+
+	KeepTags              []string    `json:"keepTags,omitempty" envconfig:"K6_INFLUXDB_KEEP_TAGS"`
+	DropTags              []string    `json:"dropTags,omitempty" envconfig:"K6_INFLUXDB_DROP_TAGS"`
+	SingleMeasurement     null.Bool   `json:"singleMeasurement,omitempty" envconfig:"K6_INFLUXDB_SINGLE_MEASUREMENT"`
+	SingleMeasurementName null.String `json:"singleMeasurementName,omitempty" envconfig:"K6_INFLUXDB_SINGLE_MEASUREMENT_NAME"` //nolint:lll
+	MaxPointsPerSecond    null.Int    `json:"maxPointsPerSecond,omitempty" envconfig:"K6_INFLUXDB_MAX_PPS"`
+	AsyncWrite            null.Bool   `json:"asyncWrite,omitempty" envconfig:"K6_INFLUXDB_ASYNC_WRITE"`
+	SanitizeKeys          null.Bool   `json:"sanitizeKeys,omitempty" envconfig:"K6_INFLUXDB_SANITIZE_KEYS"`
+	SanitizeReplacement   null.String `json:"sanitizeReplacement,omitempty" envconfig:"K6_INFLUXDB_SANITIZE_REPLACEMENT"`
+
+	MaxIdleConns    null.Int           `json:"maxIdleConns,omitempty" envconfig:"K6_INFLUXDB_MAX_IDLE_CONNS"`
+	IdleConnTimeout types.NullDuration `json:"idleConnTimeout,omitempty" envconfig:"K6_INFLUXDB_IDLE_CONN_TIMEOUT"`
+
+	IncludedMetricTypes []string `json:"includedMetricTypes,omitempty" envconfig:"K6_INFLUXDB_INCLUDED_METRIC_TYPES"`
+	ExcludedMetricTypes []string `json:"excludedMetricTypes,omitempty" envconfig:"K6_INFLUXDB_EXCLUDED_METRIC_TYPES"`
+
+	Flavor         null.String        `json:"flavor,omitempty" envconfig:"K6_INFLUXDB_FLAVOR"`
+	FlushThreshold null.Int           `json:"flushThreshold,omitempty" envconfig:"K6_INFLUXDB_FLUSH_THRESHOLD"`
+	MaxRetryAfter  types.NullDuration `json:"maxRetryAfter,omitempty" envconfig:"K6_INFLUXDB_MAX_RETRY_AFTER"`
+	AddMetricType  null.Bool          `json:"addMetricType,omitempty" envconfig:"K6_INFLUXDB_ADD_METRIC_TYPE"`
+	MetricTypeKey  null.String        `json:"metricTypeKey,omitempty" envconfig:"K6_INFLUXDB_METRIC_TYPE_KEY"`
+
+	MetricTypeAsField          null.Bool `json:"metricTypeAsField,omitempty" envconfig:"K6_INFLUXDB_METRIC_TYPE_AS_FIELD"`
+	InsecureSkipTLSVerifyHosts []string  `json:"insecureSkipTLSVerifyHosts,omitempty" envconfig:"K6_INFLUXDB_INSECURE_HOSTS"` //nolint:lll
+
+	AutoFieldNumericTags null.Bool `json:"autoFieldNumericTags,omitempty" envconfig:"K6_INFLUXDB_AUTO_FIELD_NUMERIC_TAGS"`
+
+	PushIntervalJitter        types.NullDuration `json:"pushIntervalJitter,omitempty" envconfig:"K6_INFLUXDB_PUSH_INTERVAL_JITTER"`                 //nolint:lll
+	PushIntervalJitterPerTick null.Bool          `json:"pushIntervalJitterPerTick,omitempty" envconfig:"K6_INFLUXDB_PUSH_INTERVAL_JITTER_PER_TICK"` //nolint:lll
+
+	EmitLifecycleEvents null.Bool `json:"emitLifecycleEvents,omitempty" envconfig:"K6_INFLUXDB_EMIT_LIFECYCLE_EVENTS"`
+
+	LifecycleEventsMeasurement null.String `json:"lifecycleEventsMeasurement,omitempty" envconfig:"K6_INFLUXDB_LIFECYCLE_EVENTS_MEASUREMENT"` //nolint:lll
+
+	WriteSlotTimeout types.NullDuration `json:"writeSlotTimeout,omitempty" envconfig:"K6_INFLUXDB_WRITE_SLOT_TIMEOUT"`
+
+	Gzip                       null.Bool   `json:"gzip,omitempty" envconfig:"K6_INFLUXDB_GZIP"`
+	MaxBatchSize               null.Int    `json:"maxBatchSize,omitempty" envconfig:"K6_INFLUXDB_MAX_BATCH_SIZE"`
+	WriteProfile               null.String `json:"writeProfile,omitempty" envconfig:"K6_INFLUXDB_WRITE_PROFILE"`
+	OmitValueField             null.Bool   `json:"omitValueField,omitempty" envconfig:"K6_INFLUXDB_OMIT_VALUE_FIELD"`
+	DropZeroValues             null.Bool   `json:"dropZeroValues,omitempty" envconfig:"K6_INFLUXDB_DROP_ZERO_VALUES"`
+	MinValue                   null.Float  `json:"minValue,omitempty" envconfig:"K6_INFLUXDB_MIN_VALUE"`
+	ValueFilterExcludedMetrics []string    `json:"valueFilterExcludedMetrics,omitempty" envconfig:"K6_INFLUXDB_VALUE_FILTER_EXCLUDED_METRICS"` //nolint:lll
+
+	CheckAsBool        null.Bool         `json:"checkAsBool,omitempty" envconfig:"K6_INFLUXDB_CHECK_AS_BOOL"`
+	SortByTime         null.Bool         `json:"sortByTime,omitempty" envconfig:"K6_INFLUXDB_SORT_BY_TIME"`
+	DeadLetterFile     null.String       `json:"deadLetterFile,omitempty" envconfig:"K6_INFLUXDB_DEAD_LETTER_FILE"`
+	DeadLetterMaxSize  null.Int          `json:"deadLetterMaxSize,omitempty" envconfig:"K6_INFLUXDB_DEAD_LETTER_MAX_SIZE"`
+	MaxBatchBytes      null.Int          `json:"maxBatchBytes,omitempty" envconfig:"K6_INFLUXDB_MAX_BATCH_BYTES"`
+	DefaultScenarioTag null.String       `json:"defaultScenarioTag,omitempty" envconfig:"K6_INFLUXDB_DEFAULT_SCENARIO_TAG"`
+	GlobalTags         map[string]string `json:"globalTags,omitempty" envconfig:"K6_INFLUXDB_GLOBAL_TAGS"`
+
+	ImportOTelResourceAttrs null.Bool   `json:"importOTelResourceAttrs,omitempty" envconfig:"K6_INFLUXDB_IMPORT_OTEL_RESOURCE_ATTRS"` //nolint:lll
+	MaxFieldsPerPoint       null.Int    `json:"maxFieldsPerPoint,omitempty" envconfig:"K6_INFLUXDB_MAX_FIELDS_PER_POINT"`
+	FieldsOverflow          null.String `json:"fieldsOverflow,omitempty" envconfig:"K6_INFLUXDB_FIELDS_OVERFLOW"`
+	OnFieldParseError       null.String `json:"onFieldParseError,omitempty" envconfig:"K6_INFLUXDB_ON_FIELD_PARSE_ERROR"`
+	EmitThresholds          null.Bool   `json:"emitThresholds,omitempty" envconfig:"K6_INFLUXDB_EMIT_THRESHOLDS"`
+	ThresholdsMeasurement   null.String `json:"thresholdsMeasurement,omitempty" envconfig:"K6_INFLUXDB_THRESHOLDS_MEASUREMENT"` //nolint:lll
+	TrendSampling           null.String `json:"trendSampling,omitempty" envconfig:"K6_INFLUXDB_TREND_SAMPLING"`
+	TrendSamplingRate       null.Float  `json:"trendSamplingRate,omitempty" envconfig:"K6_INFLUXDB_TREND_SAMPLING_RATE"`
+	TrendReservoirSize      null.Int    `json:"trendReservoirSize,omitempty" envconfig:"K6_INFLUXDB_TREND_RESERVOIR_SIZE"`
+	ForceHTTP2              null.Bool   `json:"forceHTTP2,omitempty" envconfig:"K6_INFLUXDB_FORCE_HTTP2"`
+	LogTopMetrics           null.Int    `json:"logTopMetrics,omitempty" envconfig:"K6_INFLUXDB_LOG_TOP_METRICS"`
+	ReconnectAfterFailures  null.Int    `json:"reconnectAfterFailures,omitempty" envconfig:"K6_INFLUXDB_RECONNECT_AFTER_FAILURES"` //nolint:lll
+
+	ReconnectCooldown  types.NullDuration `json:"reconnectCooldown,omitempty" envconfig:"K6_INFLUXDB_RECONNECT_COOLDOWN"`
+	SummaryOnly        null.Bool          `json:"summaryOnly,omitempty" envconfig:"K6_INFLUXDB_SUMMARY_ONLY"`
+	SummaryMeasurement null.String        `json:"summaryMeasurement,omitempty" envconfig:"K6_INFLUXDB_SUMMARY_MEASUREMENT"`
+
+	LogThroughput         null.Bool          `json:"logThroughput,omitempty" envconfig:"K6_INFLUXDB_LOG_THROUGHPUT"`
+	LogThroughputInterval types.NullDuration `json:"logThroughputInterval,omitempty" envconfig:"K6_INFLUXDB_LOG_THROUGHPUT_INTERVAL"` //nolint:lll
+
+	BucketSchemaType     null.String `json:"bucketSchemaType,omitempty" envconfig:"K6_INFLUXDB_BUCKET_SCHEMA_TYPE"`
+	TagKeyPrefix         null.String `json:"tagKeyPrefix,omitempty" envconfig:"K6_INFLUXDB_TAG_KEY_PREFIX"`
+	PrefixFieldKeys      null.Bool   `json:"prefixFieldKeys,omitempty" envconfig:"K6_INFLUXDB_PREFIX_FIELD_KEYS"`
+	StartupHealthCheck   null.Bool   `json:"startupHealthCheck,omitempty" envconfig:"K6_INFLUXDB_STARTUP_HEALTH_CHECK"`
+	MaxInFlightPoints    null.Int    `json:"maxInFlightPoints,omitempty" envconfig:"K6_INFLUXDB_MAX_IN_FLIGHT_POINTS"`
+	DropEmptyTags        null.Bool   `json:"dropEmptyTags,omitempty" envconfig:"K6_INFLUXDB_DROP_EMPTY_TAGS"`
+	EmitHeartbeat        null.Bool   `json:"emitHeartbeat,omitempty" envconfig:"K6_INFLUXDB_EMIT_HEARTBEAT"`
+	HeartbeatMeasurement null.String `json:"heartbeatMeasurement,omitempty" envconfig:"K6_INFLUXDB_HEARTBEAT_MEASUREMENT"`
+
+	HeartbeatField            null.String `json:"heartbeatField,omitempty" envconfig:"K6_INFLUXDB_HEARTBEAT_FIELD"`
+	OrgFromTag                null.String `json:"orgFromTag,omitempty" envconfig:"K6_INFLUXDB_ORG_FROM_TAG"`
+	MaxWriteErrorRate         null.Float  `json:"maxWriteErrorRate,omitempty" envconfig:"K6_INFLUXDB_MAX_WRITE_ERROR_RATE"`
+	WriteErrorBudgetMinWrites null.Int    `json:"writeErrorBudgetMinWrites,omitempty" envconfig:"K6_INFLUXDB_WRITE_ERROR_BUDGET_MIN_WRITES"` //nolint:lll
+
+	DisambiguateTimestamps null.Bool           `json:"disambiguateTimestamps,omitempty" envconfig:"K6_INFLUXDB_DISAMBIGUATE_TIMESTAMPS"` //nolint:lll
+	StopTimeout            types.NullDuration  `json:"stopTimeout,omitempty" envconfig:"K6_INFLUXDB_STOP_TIMEOUT"`
+	EnableClientLog        null.Bool           `json:"enableClientLog,omitempty" envconfig:"K6_INFLUXDB_CLIENT_LOG"`
+	FluxSchema             null.Bool           `json:"fluxSchema,omitempty" envconfig:"K6_INFLUXDB_FLUX_SCHEMA"`
+	TimeWindowStart        null.String         `json:"timeWindowStart,omitempty" envconfig:"K6_INFLUXDB_TIME_WINDOW_START"`
+	TimeWindowEnd          null.String         `json:"timeWindowEnd,omitempty" envconfig:"K6_INFLUXDB_TIME_WINDOW_END"`
+	MetricTagDrops         map[string][]string `json:"metricTagDrops,omitempty" envconfig:"K6_INFLUXDB_METRIC_TAG_DROPS"`
+
+	AWSSigV4            null.Bool   `json:"awsSigV4,omitempty" envconfig:"K6_INFLUXDB_AWS_SIGV4"`
+	AWSSigV4Region      null.String `json:"awsSigV4Region,omitempty" envconfig:"K6_INFLUXDB_AWS_SIGV4_REGION"`
+	AWSSigV4Service     null.String `json:"awsSigV4Service,omitempty" envconfig:"K6_INFLUXDB_AWS_SIGV4_SERVICE"`
+	AWSSigV4AccessKeyID null.String `json:"awsSigV4AccessKeyID,omitempty" envconfig:"K6_INFLUXDB_AWS_SIGV4_ACCESS_KEY_ID"`
+
+	AWSSigV4SecretAccessKey null.String `json:"awsSigV4SecretAccessKey,omitempty" envconfig:"K6_INFLUXDB_AWS_SIGV4_SECRET_ACCESS_KEY"` //nolint:lll
+	AWSSigV4SessionToken    null.String `json:"awsSigV4SessionToken,omitempty" envconfig:"K6_INFLUXDB_AWS_SIGV4_SESSION_TOKEN"`        //nolint:lll
+	SkipEmptyFlushLogging   null.Bool   `json:"skipEmptyFlushLogging,omitempty" envconfig:"K6_INFLUXDB_SKIP_EMPTY_FLUSH_LOGGING"`      //nolint:lll
+
+	InferOrganization  null.Bool         `json:"inferOrganization,omitempty" envconfig:"K6_INFLUXDB_INFER_ORGANIZATION"`
+	StreamLineProtocol null.Bool         `json:"streamLineProtocol,omitempty" envconfig:"K6_INFLUXDB_STREAM_LINE_PROTOCOL"`
+	ValueFieldByType   map[string]string `json:"valueFieldByType,omitempty" envconfig:"K6_INFLUXDB_VALUE_FIELD_BY_TYPE"`
+	MaxTagValueLength  null.Int          `json:"maxTagValueLength,omitempty" envconfig:"K6_INFLUXDB_MAX_TAG_VALUE_LENGTH"`
+	TagValueTruncation null.String       `json:"tagValueTruncation,omitempty" envconfig:"K6_INFLUXDB_TAG_VALUE_TRUNCATION"`
+
+	MergeTolerance types.NullDuration `json:"mergeTolerance,omitempty" envconfig:"K6_INFLUXDB_MERGE_TOLERANCE"`
+
+	MirrorBuckets          []string    `json:"mirrorBuckets,omitempty" envconfig:"K6_INFLUXDB_MIRROR_BUCKETS"`
+	NonFiniteMode          null.String `json:"nonFiniteMode,omitempty" envconfig:"K6_INFLUXDB_NON_FINITE_MODE"`
+	BasePath               null.String `json:"basePath,omitempty" envconfig:"K6_INFLUXDB_BASE_PATH"`
+	WALDir                 null.String `json:"walDir,omitempty" envconfig:"K6_INFLUXDB_WAL_DIR"`
+	MeasurementFromTag     null.String `json:"measurementFromTag,omitempty" envconfig:"K6_INFLUXDB_MEASUREMENT_FROM_TAG"`
+	MetricNameTag          null.String `json:"metricNameTag,omitempty" envconfig:"K6_INFLUXDB_METRIC_NAME_TAG"`
+	AutoConcurrency        null.Bool   `json:"autoConcurrency,omitempty" envconfig:"K6_INFLUXDB_AUTO_CONCURRENCY"`
+	MinConcurrentWrites    null.Int    `json:"minConcurrentWrites,omitempty" envconfig:"K6_INFLUXDB_MIN_CONCURRENT_WRITES"`
+	MaxConcurrentWrites    null.Int    `json:"maxConcurrentWrites,omitempty" envconfig:"K6_INFLUXDB_MAX_CONCURRENT_WRITES"`
+	EmitRunMetadata        null.Bool   `json:"emitRunMetadata,omitempty" envconfig:"K6_INFLUXDB_EMIT_RUN_METADATA"`
+	RunMetadataMeasurement null.String `json:"runMetadataMeasurement,omitempty" envconfig:"K6_INFLUXDB_RUN_METADATA_MEASUREMENT"` //nolint:lll
 }
 
 // NewConfig creates a new InfluxDB output config with some default values.
 func NewConfig() Config {
 	c := Config{
-		Addr:             null.NewString("http://localhost:8086", false),
-		TagsAsFields:     []string{"vu:int", "iter:int", "url"},
-		ConcurrentWrites: null.NewInt(4, false),
-		PushInterval:     types.NewNullDuration(time.Second, false),
+		Addr:                       null.NewString("http://localhost:8086", false),
+		TagsAsFields:               []string{"vu:int", "iter:int", "url"},
+		ConcurrentWrites:           null.NewInt(4, false),
+		PushInterval:               types.NewNullDuration(time.Second, false),
+		RunIDTag:                   null.NewString("run_id", false),
+		MeasurementSeparator:       null.NewString("_", false),
+		ErrorLogWindow:             types.NewNullDuration(10*time.Second, false),
+		SingleMeasurementName:      null.NewString("k6", false),
+		SanitizeReplacement:        null.NewString("_", false),
+		TagValueTruncation:         null.NewString(TagTruncationEllipsis, false),
+		NonFiniteMode:              null.NewString(NonFiniteDrop, false),
+		MetricNameTag:              null.NewString("metric", false),
+		MinConcurrentWrites:        null.NewInt(1, false),
+		MaxConcurrentWrites:        null.NewInt(16, false),
+		RunMetadataMeasurement:     null.NewString("k6_run_metadata", false),
+		IdleConnTimeout:            types.NewNullDuration(90*time.Second, false),
+		Flavor:                     null.NewString(FlavorV2, false),
+		InferOrganization:          null.NewBool(true, false),
+		MaxRetryAfter:              types.NewNullDuration(time.Minute, false),
+		MetricTypeKey:              null.NewString("metric_type", false),
+		LifecycleEventsMeasurement: null.NewString("k6_events", false),
+		ValueFilterExcludedMetrics: []string{"vus", "vus_max"},
+		FieldsOverflow:             null.NewString(FieldsOverflowTruncate, false),
+		OnFieldParseError:          null.NewString(OnFieldParseErrorFallbackString, false),
+		ThresholdsMeasurement:      null.NewString("k6_thresholds", false),
+		ReconnectCooldown:          types.NewNullDuration(time.Minute, false),
+		SummaryMeasurement:         null.NewString("k6_summary", false),
+		LogThroughputInterval:      types.NewNullDuration(10*time.Second, false),
+		DropEmptyTags:              null.NewBool(true, false),
+		HeartbeatMeasurement:       null.NewString("k6_heartbeat", false),
+		HeartbeatField:             null.NewString("value", false),
+		WriteErrorBudgetMinWrites:  null.NewInt(10, false),
 	}
 	return c
 }
 
 // Apply overrides internal configuration with received values.
 func (c Config) Apply(cfg Config) Config {
+	c = c.applyConnection(cfg)
+	c = c.applyDestination(cfg)
+	c = c.applyWrites(cfg)
+	c = c.applyFailures(cfg)
+	c = c.applyTags(cfg)
+	c = c.applyPoints(cfg)
+	c = c.applySampleFilters(cfg)
+	c = c.applyReports(cfg)
+	return c
+}
+
+// applyConnection overrides the options of the connection to InfluxDB.
+func (c Config) applyConnection(cfg Config) Config {
 	if cfg.Addr.Valid {
 		c.Addr = cfg.Addr
 	}
+	if cfg.Token.Valid {
+		c.Token = cfg.Token
+	}
+	if cfg.InsecureSkipTLSVerify.Valid {
+		c.InsecureSkipTLSVerify = cfg.InsecureSkipTLSVerify
+	}
+	if cfg.MaxIdleConns.Valid {
+		c.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.IdleConnTimeout.Valid {
+		c.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.Flavor.Valid {
+		c.Flavor = cfg.Flavor
+	}
+	if len(cfg.InsecureSkipTLSVerifyHosts) > 0 {
+		c.InsecureSkipTLSVerifyHosts = cfg.InsecureSkipTLSVerifyHosts
+	}
+	if cfg.Gzip.Valid {
+		c.Gzip = cfg.Gzip
+	}
+	if cfg.ForceHTTP2.Valid {
+		c.ForceHTTP2 = cfg.ForceHTTP2
+	}
+	if cfg.StartupHealthCheck.Valid {
+		c.StartupHealthCheck = cfg.StartupHealthCheck
+	}
+	if cfg.EnableClientLog.Valid {
+		c.EnableClientLog = cfg.EnableClientLog
+	}
+	if cfg.AWSSigV4.Valid {
+		c.AWSSigV4 = cfg.AWSSigV4
+	}
+	if cfg.AWSSigV4Region.Valid {
+		c.AWSSigV4Region = cfg.AWSSigV4Region
+	}
+	if cfg.AWSSigV4Service.Valid {
+		c.AWSSigV4Service = cfg.AWSSigV4Service
+	}
+	if cfg.AWSSigV4AccessKeyID.Valid {
+		c.AWSSigV4AccessKeyID = cfg.AWSSigV4AccessKeyID
+	}
+	if cfg.AWSSigV4SecretAccessKey.Valid {
+		c.AWSSigV4SecretAccessKey = cfg.AWSSigV4SecretAccessKey
+	}
+	if cfg.AWSSigV4SessionToken.Valid {
+		c.AWSSigV4SessionToken = cfg.AWSSigV4SessionToken
+	}
+	if cfg.BasePath.Valid {
+		c.BasePath = cfg.BasePath
+	}
+	return c
+}
+
+// applyDestination overrides the options of the organizations and the buckets written.
+func (c Config) applyDestination(cfg Config) Config {
 	if cfg.Organization.Valid {
 		c.Organization = cfg.Organization
 	}
 	if cfg.Bucket.Valid {
 		c.Bucket = cfg.Bucket
 	}
-	if cfg.Token.Valid {
-		c.Token = cfg.Token
+	if cfg.Consistency.Valid {
+		c.Consistency = cfg.Consistency
 	}
-	if cfg.InsecureSkipTLSVerify.Valid {
-		c.InsecureSkipTLSVerify = cfg.InsecureSkipTLSVerify
+	if cfg.CreateBucket.Valid {
+		c.CreateBucket = cfg.CreateBucket
 	}
-	if len(cfg.TagsAsFields) > 0 {
-		c.TagsAsFields = cfg.TagsAsFields
+	if cfg.BucketRetention.Valid {
+		c.BucketRetention = cfg.BucketRetention
+	}
+	if cfg.BucketSchemaType.Valid {
+		c.BucketSchemaType = cfg.BucketSchemaType
+	}
+	if cfg.OrgFromTag.Valid {
+		c.OrgFromTag = cfg.OrgFromTag
+	}
+	if cfg.InferOrganization.Valid {
+		c.InferOrganization = cfg.InferOrganization
 	}
+	if len(cfg.MirrorBuckets) > 0 {
+		c.MirrorBuckets = cfg.MirrorBuckets
+	}
+	return c
+}
+
+// applyWrites overrides the options of the flushes and of their writes.
+func (c Config) applyWrites(cfg Config) Config {
 	if cfg.PushInterval.Valid {
 		c.PushInterval = cfg.PushInterval
 	}
@@ -64,34 +321,764 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.Precision.Valid {
 		c.Precision = cfg.Precision
 	}
+	if cfg.PrecisionUnit.Valid {
+		c.PrecisionUnit = cfg.PrecisionUnit
+	}
+	if cfg.MaxPointsPerSecond.Valid {
+		c.MaxPointsPerSecond = cfg.MaxPointsPerSecond
+	}
+	if cfg.AsyncWrite.Valid {
+		c.AsyncWrite = cfg.AsyncWrite
+	}
+	if cfg.FlushThreshold.Valid {
+		c.FlushThreshold = cfg.FlushThreshold
+	}
+	if cfg.PushIntervalJitter.Valid {
+		c.PushIntervalJitter = cfg.PushIntervalJitter
+	}
+	if cfg.PushIntervalJitterPerTick.Valid {
+		c.PushIntervalJitterPerTick = cfg.PushIntervalJitterPerTick
+	}
+	if cfg.WriteSlotTimeout.Valid {
+		c.WriteSlotTimeout = cfg.WriteSlotTimeout
+	}
+	if cfg.MaxBatchSize.Valid {
+		c.MaxBatchSize = cfg.MaxBatchSize
+	}
+	if cfg.WriteProfile.Valid {
+		c.WriteProfile = cfg.WriteProfile
+	}
+	if cfg.SortByTime.Valid {
+		c.SortByTime = cfg.SortByTime
+	}
+	if cfg.MaxBatchBytes.Valid {
+		c.MaxBatchBytes = cfg.MaxBatchBytes
+	}
+	if cfg.MaxInFlightPoints.Valid {
+		c.MaxInFlightPoints = cfg.MaxInFlightPoints
+	}
+	if cfg.StopTimeout.Valid {
+		c.StopTimeout = cfg.StopTimeout
+	}
+	if cfg.StreamLineProtocol.Valid {
+		c.StreamLineProtocol = cfg.StreamLineProtocol
+	}
+	if cfg.AutoConcurrency.Valid {
+		c.AutoConcurrency = cfg.AutoConcurrency
+	}
+	if cfg.MinConcurrentWrites.Valid {
+		c.MinConcurrentWrites = cfg.MinConcurrentWrites
+	}
+	if cfg.MaxConcurrentWrites.Valid {
+		c.MaxConcurrentWrites = cfg.MaxConcurrentWrites
+	}
+	return c
+}
+
+// applyFailures overrides the options handling the failed writes.
+func (c Config) applyFailures(cfg Config) Config {
+	if cfg.ReconnectAfterFailures.Valid {
+		c.ReconnectAfterFailures = cfg.ReconnectAfterFailures
+	}
+	if cfg.ReconnectCooldown.Valid {
+		c.ReconnectCooldown = cfg.ReconnectCooldown
+	}
+	if cfg.MaxRetryAfter.Valid {
+		c.MaxRetryAfter = cfg.MaxRetryAfter
+	}
+	if cfg.MaxWriteErrorRate.Valid {
+		c.MaxWriteErrorRate = cfg.MaxWriteErrorRate
+	}
+	if cfg.WriteErrorBudgetMinWrites.Valid {
+		c.WriteErrorBudgetMinWrites = cfg.WriteErrorBudgetMinWrites
+	}
+	if cfg.DeadLetterFile.Valid {
+		c.DeadLetterFile = cfg.DeadLetterFile
+	}
+	if cfg.DeadLetterMaxSize.Valid {
+		c.DeadLetterMaxSize = cfg.DeadLetterMaxSize
+	}
+	if cfg.WALDir.Valid {
+		c.WALDir = cfg.WALDir
+	}
+	return c
+}
+
+// applyTags overrides the options of the points' tags.
+func (c Config) applyTags(cfg Config) Config {
+	if len(cfg.TagsAsFields) > 0 {
+		c.TagsAsFields = cfg.TagsAsFields
+	}
+	if cfg.KeepExtractedTags.Valid {
+		c.KeepExtractedTags = cfg.KeepExtractedTags
+	}
+	if cfg.AddRunID.Valid {
+		c.AddRunID = cfg.AddRunID
+	}
+	if cfg.RunID.Valid {
+		c.RunID = cfg.RunID
+	}
+	if cfg.RunIDTag.Valid {
+		c.RunIDTag = cfg.RunIDTag
+	}
+	if cfg.DisableTagCache.Valid {
+		c.DisableTagCache = cfg.DisableTagCache
+	}
+	if len(cfg.KeepTags) > 0 {
+		c.KeepTags = cfg.KeepTags
+	}
+	if len(cfg.DropTags) > 0 {
+		c.DropTags = cfg.DropTags
+	}
+	if cfg.SanitizeKeys.Valid {
+		c.SanitizeKeys = cfg.SanitizeKeys
+	}
+	if cfg.SanitizeReplacement.Valid {
+		c.SanitizeReplacement = cfg.SanitizeReplacement
+	}
+	if cfg.AddMetricType.Valid {
+		c.AddMetricType = cfg.AddMetricType
+	}
+	if cfg.MetricTypeKey.Valid {
+		c.MetricTypeKey = cfg.MetricTypeKey
+	}
+	if cfg.MetricTypeAsField.Valid {
+		c.MetricTypeAsField = cfg.MetricTypeAsField
+	}
+	if cfg.AutoFieldNumericTags.Valid {
+		c.AutoFieldNumericTags = cfg.AutoFieldNumericTags
+	}
+	if cfg.DefaultScenarioTag.Valid {
+		c.DefaultScenarioTag = cfg.DefaultScenarioTag
+	}
+	if len(cfg.GlobalTags) > 0 {
+		c.GlobalTags = cfg.GlobalTags
+	}
+	if cfg.ImportOTelResourceAttrs.Valid {
+		c.ImportOTelResourceAttrs = cfg.ImportOTelResourceAttrs
+	}
+	if cfg.TagKeyPrefix.Valid {
+		c.TagKeyPrefix = cfg.TagKeyPrefix
+	}
+	if cfg.DropEmptyTags.Valid {
+		c.DropEmptyTags = cfg.DropEmptyTags
+	}
+	if len(cfg.MetricTagDrops) > 0 {
+		c.MetricTagDrops = cfg.MetricTagDrops
+	}
+	if cfg.MaxTagValueLength.Valid {
+		c.MaxTagValueLength = cfg.MaxTagValueLength
+	}
+	if cfg.TagValueTruncation.Valid {
+		c.TagValueTruncation = cfg.TagValueTruncation
+	}
+	return c
+}
+
+// applyPoints overrides the options of the points' measurements, fields and timestamps.
+func (c Config) applyPoints(cfg Config) Config {
+	if cfg.MeasurementPrefix.Valid {
+		c.MeasurementPrefix = cfg.MeasurementPrefix
+	}
+	if cfg.MeasurementSeparator.Valid {
+		c.MeasurementSeparator = cfg.MeasurementSeparator
+	}
+	if cfg.TimestampOffset.Valid {
+		c.TimestampOffset = cfg.TimestampOffset
+	}
+	if cfg.SingleMeasurement.Valid {
+		c.SingleMeasurement = cfg.SingleMeasurement
+	}
+	if cfg.SingleMeasurementName.Valid {
+		c.SingleMeasurementName = cfg.SingleMeasurementName
+	}
+	if cfg.OmitValueField.Valid {
+		c.OmitValueField = cfg.OmitValueField
+	}
+	if cfg.CheckAsBool.Valid {
+		c.CheckAsBool = cfg.CheckAsBool
+	}
+	if cfg.MaxFieldsPerPoint.Valid {
+		c.MaxFieldsPerPoint = cfg.MaxFieldsPerPoint
+	}
+	if cfg.FieldsOverflow.Valid {
+		c.FieldsOverflow = cfg.FieldsOverflow
+	}
+	if cfg.OnFieldParseError.Valid {
+		c.OnFieldParseError = cfg.OnFieldParseError
+	}
+	if cfg.PrefixFieldKeys.Valid {
+		c.PrefixFieldKeys = cfg.PrefixFieldKeys
+	}
+	if cfg.DisambiguateTimestamps.Valid {
+		c.DisambiguateTimestamps = cfg.DisambiguateTimestamps
+	}
+	if cfg.FluxSchema.Valid {
+		c.FluxSchema = cfg.FluxSchema
+	}
+	if len(cfg.ValueFieldByType) > 0 {
+		c.ValueFieldByType = cfg.ValueFieldByType
+	}
+	if cfg.MergeTolerance.Valid {
+		c.MergeTolerance = cfg.MergeTolerance
+	}
+	if cfg.NonFiniteMode.Valid {
+		c.NonFiniteMode = cfg.NonFiniteMode
+	}
+	if cfg.MeasurementFromTag.Valid {
+		c.MeasurementFromTag = cfg.MeasurementFromTag
+	}
+	if cfg.MetricNameTag.Valid {
+		c.MetricNameTag = cfg.MetricNameTag
+	}
+	return c
+}
+
+// applySampleFilters overrides the options filtering and sampling the written samples.
+func (c Config) applySampleFilters(cfg Config) Config {
+	if len(cfg.IncludedMetricTypes) > 0 {
+		c.IncludedMetricTypes = cfg.IncludedMetricTypes
+	}
+	if len(cfg.ExcludedMetricTypes) > 0 {
+		c.ExcludedMetricTypes = cfg.ExcludedMetricTypes
+	}
+	if cfg.DropZeroValues.Valid {
+		c.DropZeroValues = cfg.DropZeroValues
+	}
+	if cfg.MinValue.Valid {
+		c.MinValue = cfg.MinValue
+	}
+	if len(cfg.ValueFilterExcludedMetrics) > 0 {
+		c.ValueFilterExcludedMetrics = cfg.ValueFilterExcludedMetrics
+	}
+	if cfg.TrendSampling.Valid {
+		c.TrendSampling = cfg.TrendSampling
+	}
+	if cfg.TrendSamplingRate.Valid {
+		c.TrendSamplingRate = cfg.TrendSamplingRate
+	}
+	if cfg.TrendReservoirSize.Valid {
+		c.TrendReservoirSize = cfg.TrendReservoirSize
+	}
+	if cfg.TimeWindowStart.Valid {
+		c.TimeWindowStart = cfg.TimeWindowStart
+	}
+	if cfg.TimeWindowEnd.Valid {
+		c.TimeWindowEnd = cfg.TimeWindowEnd
+	}
+	return c
+}
+
+// applyReports overrides the options of the additional points and of the logs.
+func (c Config) applyReports(cfg Config) Config {
+	if cfg.ErrorLogWindow.Valid {
+		c.ErrorLogWindow = cfg.ErrorLogWindow
+	}
+	if cfg.EmitLifecycleEvents.Valid {
+		c.EmitLifecycleEvents = cfg.EmitLifecycleEvents
+	}
+	if cfg.LifecycleEventsMeasurement.Valid {
+		c.LifecycleEventsMeasurement = cfg.LifecycleEventsMeasurement
+	}
+	if cfg.EmitThresholds.Valid {
+		c.EmitThresholds = cfg.EmitThresholds
+	}
+	if cfg.ThresholdsMeasurement.Valid {
+		c.ThresholdsMeasurement = cfg.ThresholdsMeasurement
+	}
+	if cfg.LogTopMetrics.Valid {
+		c.LogTopMetrics = cfg.LogTopMetrics
+	}
+	if cfg.SummaryOnly.Valid {
+		c.SummaryOnly = cfg.SummaryOnly
+	}
+	if cfg.SummaryMeasurement.Valid {
+		c.SummaryMeasurement = cfg.SummaryMeasurement
+	}
+	if cfg.LogThroughput.Valid {
+		c.LogThroughput = cfg.LogThroughput
+	}
+	if cfg.LogThroughputInterval.Valid {
+		c.LogThroughputInterval = cfg.LogThroughputInterval
+	}
+	if cfg.EmitHeartbeat.Valid {
+		c.EmitHeartbeat = cfg.EmitHeartbeat
+	}
+	if cfg.HeartbeatMeasurement.Valid {
+		c.HeartbeatMeasurement = cfg.HeartbeatMeasurement
+	}
+	if cfg.HeartbeatField.Valid {
+		c.HeartbeatField = cfg.HeartbeatField
+	}
+	if cfg.SkipEmptyFlushLogging.Valid {
+		c.SkipEmptyFlushLogging = cfg.SkipEmptyFlushLogging
+	}
+	if cfg.EmitRunMetadata.Valid {
+		c.EmitRunMetadata = cfg.EmitRunMetadata
+	}
+	if cfg.RunMetadataMeasurement.Valid {
+		c.RunMetadataMeasurement = cfg.RunMetadataMeasurement
+	}
+	return c
+}
+
+// Validate returns the first error of the config's invariants, e.g. the required
+// or mutually exclusive options, so an invalid config fails before the output is created.
+// It expects a consolidated config, with the defaults of NewConfig.
+func (c Config) Validate() error {
+	checks := []func(Config) error{
+		checkDestination,
+		checkFlavor,
+		checkBucket,
+		checkOrganization,
+		checkPrecision,
+		checkWriteOptions,
+		checkAsyncWrite,
+		checkFieldsOverflow,
+		checkTrendSampling,
+		checkDurations,
+		checkLimits,
+		checkWriteErrorBudget,
+		checkEmittedPoints,
+		checkPointOptions,
+		checkEnumerations,
+		checkAWSSigV4,
+		checkStreamLineProtocol,
+		checkTagValueTruncation,
+		checkMirrorBuckets,
+		checkBasePath,
+		checkWAL,
+		checkMeasurementFromTag,
+		checkAutoConcurrency,
+		checkParsedOptions,
+	}
+	for _, check := range checks {
+		if err := check(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPrecision returns an error if the write precision is invalid or unsupported by the flavor.
+func checkPrecision(c Config) error {
+	precision, err := c.writePrecision()
+	if err != nil {
+		return err
+	}
+	if precision <= 0 {
+		return fmt.Errorf("the Precision option must be a positive duration")
+	}
+	if c.Flavor.String == FlavorV3 {
+		if _, err := v3Precision(precision); err != nil {
+			return err
+		}
+	}
+	if c.DisambiguateTimestamps.Bool && precision >= time.Second {
+		return fmt.Errorf("the DisambiguateTimestamps option requires a precision lower than 1s, got %s", precision)
+	}
+	return nil
+}
+
+// checkWriteOptions returns an error if the options of the flushes and of their batches are invalid.
+func checkWriteOptions(c Config) error {
+	if c.ConcurrentWrites.Int64 <= 0 {
+		return fmt.Errorf("the ConcurrentWrites option must be a positive number")
+	}
+	if c.FlushThreshold.Int64 < 0 {
+		return fmt.Errorf("the FlushThreshold option can't be a negative number")
+	}
+	if jitter := c.PushIntervalJitter.Duration; jitter < 0 || jitter >= c.PushInterval.Duration {
+		return fmt.Errorf("the PushIntervalJitter option must be a non-negative duration lower than PushInterval")
+	}
+	if c.DeadLetterMaxSize.Int64 < 0 {
+		return fmt.Errorf("the DeadLetterMaxSize option can't be a negative number")
+	}
+	if c.MaxBatchSize.Int64 < 0 {
+		return fmt.Errorf("the MaxBatchSize option can't be a negative number")
+	}
+	if c.MaxBatchBytes.Int64 < 0 {
+		return fmt.Errorf("the MaxBatchBytes option can't be a negative number")
+	}
+	return nil
+}
+
+// checkAsyncWrite returns an error if AsyncWrite is set with an option
+// that depends on the outcome of the flushes' writes.
+func checkAsyncWrite(c Config) error {
+	if !c.AsyncWrite.Bool {
+		return nil
+	}
+	if c.DeadLetterFile.String != "" {
+		return fmt.Errorf("the DeadLetterFile option isn't supported with AsyncWrite, " +
+			"the failed points aren't available")
+	}
+	if c.MaxBatchBytes.Int64 > 0 {
+		return fmt.Errorf("the MaxBatchBytes option isn't supported with AsyncWrite, " +
+			"the async writer's batches are limited only by MaxBatchSize")
+	}
+	if c.ReconnectAfterFailures.Int64 > 0 {
+		return fmt.Errorf("the ReconnectAfterFailures option isn't supported with AsyncWrite, " +
+			"the async writer's failures aren't tracked by the flushes")
+	}
+	if c.OrgFromTag.String != "" {
+		return fmt.Errorf("the OrgFromTag option isn't supported with AsyncWrite, " +
+			"the async writer writes to a single organization")
+	}
+	return nil
+}
+
+// checkDurations returns an error if a duration option is negative or the time window is invalid.
+func checkDurations(c Config) error {
+	if c.WriteSlotTimeout.Duration < 0 {
+		return fmt.Errorf("the WriteSlotTimeout option can't be a negative duration")
+	}
+	if _, err := c.timeWindow(); err != nil {
+		return err
+	}
+	if c.StopTimeout.Duration < 0 {
+		return fmt.Errorf("the StopTimeout option can't be a negative duration")
+	}
+	if c.ReconnectCooldown.Duration < 0 {
+		return fmt.Errorf("the ReconnectCooldown option can't be a negative duration")
+	}
+	if c.MergeTolerance.Duration < 0 {
+		return fmt.Errorf("the MergeTolerance option can't be a negative duration")
+	}
+	return nil
+}
+
+// checkLimits returns an error if a limit option is a negative number.
+func checkLimits(c Config) error {
+	if c.MaxInFlightPoints.Int64 < 0 {
+		return fmt.Errorf("the MaxInFlightPoints option can't be a negative number")
+	}
+	if c.MaxIdleConns.Int64 < 0 {
+		return fmt.Errorf("the MaxIdleConns option can't be a negative number")
+	}
+	if c.MaxPointsPerSecond.Int64 < 0 {
+		return fmt.Errorf("the MaxPointsPerSecond option can't be a negative number")
+	}
+	if c.ReconnectAfterFailures.Int64 < 0 {
+		return fmt.Errorf("the ReconnectAfterFailures option can't be a negative number")
+	}
+	if c.LogTopMetrics.Int64 < 0 {
+		return fmt.Errorf("the LogTopMetrics option can't be a negative number")
+	}
+	return nil
+}
+
+// checkEmittedPoints returns an error if the measurement of an enabled
+// additional point, e.g. the lifecycle events, is empty.
+func checkEmittedPoints(c Config) error {
+	if c.LogThroughput.Bool && c.LogThroughputInterval.Duration <= 0 {
+		return fmt.Errorf("the LogThroughputInterval option must be a positive duration when LogThroughput is enabled")
+	}
+	if c.EmitLifecycleEvents.Bool && c.LifecycleEventsMeasurement.String == "" {
+		return fmt.Errorf("the LifecycleEventsMeasurement option can't be empty when EmitLifecycleEvents is enabled")
+	}
+	if c.EmitRunMetadata.Bool && c.RunMetadataMeasurement.String == "" {
+		return fmt.Errorf("the RunMetadataMeasurement option can't be empty when EmitRunMetadata is enabled")
+	}
+	if c.EmitThresholds.Bool && c.ThresholdsMeasurement.String == "" {
+		return fmt.Errorf("the ThresholdsMeasurement option can't be empty when EmitThresholds is enabled")
+	}
+	if c.SummaryOnly.Bool && c.SummaryMeasurement.String == "" {
+		return fmt.Errorf("the SummaryMeasurement option can't be empty when SummaryOnly is enabled")
+	}
+	if c.EmitHeartbeat.Bool && (c.HeartbeatMeasurement.String == "" || c.HeartbeatField.String == "") {
+		return fmt.Errorf("the HeartbeatMeasurement and HeartbeatField options can't be empty when EmitHeartbeat is enabled")
+	}
+	return nil
+}
+
+// checkPointOptions returns an error if the options of the metrics' points are empty or mutually exclusive.
+func checkPointOptions(c Config) error {
+	if c.AddMetricType.Bool {
+		if c.MetricTypeKey.String == "" {
+			return fmt.Errorf("the MetricTypeKey option can't be empty when AddMetricType is enabled")
+		}
+		if c.SingleMeasurement.Bool && c.MetricTypeAsField.Bool {
+			return fmt.Errorf("the MetricTypeAsField option can't be used with SingleMeasurement, " +
+				"the combined points have a field for each metric")
+		}
+	}
+	if c.SingleMeasurement.Bool && c.SingleMeasurementName.String == "" {
+		return fmt.Errorf("the SingleMeasurementName option can't be empty when SingleMeasurement is enabled")
+	}
+	if len(c.ValueFieldByType) > 0 && c.SingleMeasurement.Bool {
+		return fmt.Errorf("the ValueFieldByType option isn't supported with SingleMeasurement")
+	}
+	if c.AddRunID.Bool && c.RunIDTag.String == "" {
+		return fmt.Errorf("the RunIDTag option can't be empty when AddRunID is enabled")
+	}
+	return nil
+}
+
+// checkEnumerations returns an error if an option has a value out of its allowed ones.
+func checkEnumerations(c Config) error {
+	if err := checkOnFieldParseError(c.OnFieldParseError.String); err != nil {
+		return err
+	}
+	if err := checkConsistency(c.Consistency.String); err != nil {
+		return err
+	}
+	if err := checkHostPatterns(c.InsecureSkipTLSVerifyHosts); err != nil {
+		return err
+	}
+	return checkNonFiniteMode(c.NonFiniteMode.String)
+}
+
+// checkParsedOptions returns an error if an option parsed by New, e.g. the TagsAsFields' kinds, is invalid.
+func checkParsedOptions(c Config) error {
+	if _, err := makeFieldKinds(c); err != nil {
+		return err
+	}
+	if _, err := makeFieldMetrics(c); err != nil {
+		return err
+	}
+	if _, err := makeMetricTypeSet(c.IncludedMetricTypes); err != nil {
+		return err
+	}
+	if _, err := makeMetricTypeSet(c.ExcludedMetricTypes); err != nil {
+		return err
+	}
+	if _, err := makeMetricTagDrops(c); err != nil {
+		return err
+	}
+	_, err := makeValueFields(c)
+	return err
+}
+
+// writePrecision returns the timestamps' precision,
+// the unit wins over the duration when both are set.
+func (c Config) writePrecision() (time.Duration, error) {
+	unit, err := parsePrecisionUnit(c.PrecisionUnit.String)
+	if err != nil {
+		return 0, err
+	}
+	if unit > 0 {
+		return unit, nil
+	}
+	if c.Precision.Valid {
+		return time.Duration(c.Precision.Duration), nil
+	}
+	return time.Nanosecond, nil
+}
+
+// timeWindow returns the window of the written samples' times.
+func (c Config) timeWindow() (timeWindow, error) {
+	return parseTimeWindow(c.TimeWindowStart.String, c.TimeWindowEnd.String)
+}
+
+// redactedValue replaces the sensitive values.
+const redactedValue = "[redacted]"
+
+// Redacted returns a copy of the config where the credentials are masked,
+// so it can be safely logged.
+func (c Config) Redacted() Config {
+	for _, secret := range c.secretOptions() {
+		if secret.String != "" {
+			*secret = null.NewString(redactedValue, secret.Valid)
+		}
+	}
+	c.Addr = null.NewString(redactURL(c.Addr.String), c.Addr.Valid)
 	return c
 }
 
-// parseJSON parses the supplied JSON into a Config.
+// secretOptions returns the options holding the credentials by their JSON names,
+// they are masked by Redacted and they can't be set in the URL's query.
+// The token can be a username:password pair with the v1.8+ compatibility API.
+func (c *Config) secretOptions() map[string]*null.String {
+	return map[string]*null.String{
+		"token":                   &c.Token,
+		"awsSigV4SecretAccessKey": &c.AWSSigV4SecretAccessKey,
+		"awsSigV4SessionToken":    &c.AWSSigV4SessionToken,
+	}
+}
+
+// String returns the JSON encoding of the redacted config.
+func (c Config) String() string {
+	b, err := json.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("invalid config: %s", err)
+	}
+	return string(b)
+}
+
+// redactURL masks the password of the URL's user info, if any.
+func redactURL(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		// it could contain the credentials in an unexpected format
+		return redactedValue
+	}
+	// an address without the scheme is parsed as opaque, with the credentials not in User
+	if strings.Contains(u.Opaque, "@") {
+		return redactedValue
+	}
+	return u.Redacted()
+}
+
+// checkAddr returns an error if the address isn't a full http(s) URL or a Unix domain socket's URL.
+func checkAddr(addr string) error {
+	u, err := url.Parse(addr)
+	if err == nil && u.Scheme == unixScheme {
+		if u.Host != "" || u.Path == "" {
+			return fmt.Errorf("addr must be a Unix domain socket's absolute path as unix:///path/to/influxd.sock, got %q",
+				redactURL(addr))
+		}
+		return nil
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("addr must be a full URL with scheme (http or https), got %q", redactURL(addr))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("addr must be a full URL with host, got %q", redactURL(addr))
+	}
+	return nil
+}
+
+// checkConsistency returns an error if the write consistency
+// isn't one of the values supported by InfluxDB Enterprise.
+func checkConsistency(consistency string) error {
+	switch write.Consistency(consistency) {
+	case "", write.ConsistencyAny, write.ConsistencyOne, write.ConsistencyQuorum, write.ConsistencyAll:
+		return nil
+	default:
+		return fmt.Errorf("an invalid write consistency (%s) is specified, the allowed values are: %s, %s, %s and %s",
+			consistency, write.ConsistencyAny, write.ConsistencyOne, write.ConsistencyQuorum, write.ConsistencyAll)
+	}
+}
+
+// precisionUnits maps the InfluxDB's precision levels to the write API's precision.
+var precisionUnits = map[string]time.Duration{ //nolint:gochecknoglobals // it is read-only
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// parsePrecisionUnit returns the precision of the unit, or zero if the unit is empty.
+func parsePrecisionUnit(unit string) (time.Duration, error) {
+	if unit == "" {
+		return 0, nil
+	}
+	p, ok := precisionUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("an invalid precision unit (%s) is specified, the allowed values are: ns, us, ms and s", unit)
+	}
+	return p, nil
+}
+
+// parseJSON parses the supplied JSON into a Config. It can be an array of JSON objects,
+// e.g. a base config and its overrides, they are applied in order, so the later ones take precedence.
 func parseJSON(data json.RawMessage) (Config, error) {
 	conf := Config{}
-	err := json.Unmarshal(data, &conf)
-	return conf, err
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		err := json.Unmarshal(data, &conf)
+		return conf, err
+	}
+	var blobs []json.RawMessage
+	if err := json.Unmarshal(trimmed, &blobs); err != nil {
+		return conf, err
+	}
+	for i, blob := range blobs {
+		blobConf := Config{}
+		if err := json.Unmarshal(blob, &blobConf); err != nil {
+			return conf, fmt.Errorf("the JSON config at index %d can't be parsed: %w", i, err)
+		}
+		conf = conf.Apply(blobConf)
+	}
+	return conf, nil
 }
 
-// parseURL parses the supplied URL into a Config.
-func parseURL(text string) (Config, error) {
+// parseURL parses the supplied URL into a Config. The URL's path is the bucket,
+// after the base path when InfluxDB is mounted at a subpath, e.g. https://gw.example.com/influx/k6
+// with /influx as the base path. The base path set in the URL's query takes precedence.
+func parseURL(text, basePath string) (Config, error) {
 	c := Config{}
 	u, err := url.Parse(text)
 	if err != nil {
+		// the url.Error contains the URL, that could contain the credentials
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return c, fmt.Errorf("invalid URL: %w", uerr.Err)
+		}
 		return c, err
 	}
+	// e.g. localhost:8086, parsed with localhost as the scheme
+	if u.Opaque != "" {
+		return c, fmt.Errorf("the URL must be a full URL with scheme, got %q", redactURL(text))
+	}
+	if u.RawQuery != "" {
+		if c, err = parseURLQuery(u.Query()); err != nil {
+			return c, err
+		}
+	}
+	// the whole path is the socket's path, so the bucket can't be set
+	if u.Scheme == unixScheme {
+		c.Addr = null.StringFrom(u.Scheme + "://" + u.Host + u.Path)
+		return c, nil
+	}
 	if u.Host != "" {
 		c.Addr = null.StringFrom(u.Scheme + "://" + u.Host)
 	}
-	if bucket := strings.TrimPrefix(u.Path, "/"); bucket != "" {
+	if c.BasePath.Valid {
+		basePath = c.BasePath.String
+	}
+	bucket, err := splitBasePath(u.Path, basePath)
+	if err != nil {
+		return c, err
+	}
+	if bucket != "" {
 		c.Bucket = null.StringFrom(bucket)
 	}
 	return c, err
 }
 
+// parseURLQuery parses the options set in the URL's query by their JSON names,
+// e.g. ?concurrentWrites=8&pushInterval=2s, their values have the environment variables' format.
+// The token isn't accepted, since the URL can be logged.
+func parseURLQuery(query url.Values) (Config, error) {
+	c := Config{}
+	envKeys := make(map[string]string)
+	t := reflect.TypeOf(c)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		envKeys[name] = f.Tag.Get("envconfig")
+	}
+	secrets := c.secretOptions()
+	env := make(map[string]string, len(query))
+	for name, values := range query {
+		envKey, ok := envKeys[name]
+		_, secret := secrets[name]
+		switch {
+		case !ok:
+			return c, fmt.Errorf("an unknown option (%s) is set in the URL's query", name)
+		case secret:
+			// the URL can be logged
+			return c, fmt.Errorf("the %s option can't be set in the URL's query, use %s", name, envKey)
+		case len(values) > 1:
+			return c, fmt.Errorf("the %s option is set more than once in the URL's query", name)
+		}
+		env[envKey] = values[0]
+	}
+	err := envconfig.Process("", &c, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		return c, fmt.Errorf("the URL's query can't be parsed: %w", err)
+	}
+	return c, nil
+}
+
 // GetConsolidatedConfig combines {default config values + JSON config +
 // environment vars + URL config values}, and returns the final result.
+// The JSON config can be an array of JSON objects, applied in order.
 func GetConsolidatedConfig(
 	jsonRawConf json.RawMessage, env map[string]string, url string,
 ) (Config, error) {
@@ -114,12 +1101,60 @@ func GetConsolidatedConfig(
 	result = result.Apply(envConfig)
 
 	if url != "" {
-		urlConf, err := parseURL(url)
+		url, err := expandEnv(url, env)
+		if err != nil {
+			return result, fmt.Errorf("the URL can't be expanded: %w", err)
+		}
+		urlConf, err := parseURL(url, result.BasePath.String)
 		if err != nil {
 			return result, err
 		}
 		result = result.Apply(urlConf)
 	}
 
-	return result, nil
+	if result.Token.Valid {
+		token, err := expandEnv(result.Token.String, env)
+		if err != nil {
+			return result, fmt.Errorf("the Token can't be expanded: %w", err)
+		}
+		result.Token = null.NewString(token, result.Token.Valid)
+	}
+	if result.Organization.Valid {
+		org, err := expandEnv(result.Organization.String, env)
+		if err != nil {
+			return result, fmt.Errorf("the Organization can't be expanded: %w", err)
+		}
+		result.Organization = null.NewString(org, result.Organization.Valid)
+	}
+	if result.ImportOTelResourceAttrs.Bool {
+		attrs, err := parseOTelResourceAttrs(env[otelResourceAttrsEnv])
+		if err != nil {
+			return result, fmt.Errorf("the %s can't be parsed: %w", otelResourceAttrsEnv, err)
+		}
+		result.GlobalTags = mergeGlobalTags(attrs, result.GlobalTags)
+	}
+
+	return applyWriteProfile(result)
+}
+
+var envVarRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references with the values from the env map.
+// A reference to a variable not defined in the env map is an error,
+// a variable defined with an empty value is replaced with the empty string.
+// Only the braced form is expanded, so a plain $ is kept as is.
+func expandEnv(s string, env map[string]string) (string, error) {
+	var missing []string
+	expanded := envVarRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envVarRef.FindStringSubmatch(ref)[1]
+		v, ok := env[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("the environment variables %s are not defined", strings.Join(missing, ", "))
+	}
+	return expanded, nil
 }
@@ -0,0 +1,126 @@
+package influxdb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// writeDeadLetter appends the failed points to the dead letter file, if it is set.
+// For a partial write reporting the rejected lines, only the rejected points are appended.
+func (o *Output) writeDeadLetter(err error, batch []*write.Point) {
+	if o.deadLetter == nil {
+		return
+	}
+	failed := batch
+	if pw, ok := parsePartialWrite(err); ok && len(pw.lines) > 0 {
+		failed = make([]*write.Point, 0, len(pw.lines))
+		for _, n := range pw.lines {
+			if n >= 1 && n <= len(batch) {
+				failed = append(failed, batch[n-1])
+			}
+		}
+	}
+	if werr := o.deadLetter.writePoints(failed); werr != nil {
+		o.logger.WithError(werr).WithField("points", len(failed)).
+			Error("Couldn't append the failed points to the dead letter file")
+	}
+}
+
+// deadLetterFile appends the line protocol of the points that failed to be written,
+// so they can be inspected or replayed. The writes are buffered, the buffer is flushed on Close.
+// The lines appended after each opening are preceded by the precision's marker, so ReplayFile
+// writes them with their precision, even if the runs appending to the file have different ones.
+// When the file exceeds the max size, it is rotated to the path with the .1 suffix,
+// replacing the previous rotated file.
+type deadLetterFile struct {
+	path      string
+	maxSize   int64
+	precision time.Duration
+
+	mu   sync.Mutex
+	file *os.File //nolint:forbidigo // the output has no access to the k6's file system
+	w    *bufio.Writer
+	size int64
+	// marked is true when the precision's marker has been written since the opening
+	marked bool
+}
+
+// open opens the file, creating it if it doesn't exist.
+func (dl *deadLetterFile) open() error {
+	f, err := os.OpenFile(dl.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600) //nolint:forbidigo
+	if err != nil {
+		return fmt.Errorf("the dead letter file can't be opened: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("the dead letter file can't be opened: %w", err)
+	}
+	dl.file = f
+	dl.w = bufio.NewWriter(f)
+	dl.size = info.Size()
+	dl.marked = false
+	return nil
+}
+
+// writePoints appends the points' lines, the file is rotated before a write exceeding its max size.
+func (dl *deadLetterFile) writePoints(points []*write.Point) error {
+	lines, err := encodeLines(points, dl.precision)
+	if err != nil {
+		return err
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	for _, line := range lines {
+		if dl.maxSize > 0 && dl.size > 0 && dl.size+int64(len(line)) > dl.maxSize {
+			if err := dl.rotate(); err != nil {
+				return err
+			}
+		}
+		if !dl.marked {
+			n, err := dl.w.WriteString(precisionMarker(dl.precision))
+			dl.size += int64(n)
+			if err != nil {
+				return err
+			}
+			dl.marked = true
+		}
+		n, err := dl.w.Write(line)
+		dl.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dl *deadLetterFile) rotate() error {
+	if err := dl.closeFile(); err != nil {
+		return err
+	}
+	if err := os.Rename(dl.path, dl.path+".1"); err != nil { //nolint:forbidigo
+		return fmt.Errorf("the dead letter file can't be rotated: %w", err)
+	}
+	return dl.open()
+}
+
+func (dl *deadLetterFile) closeFile() error {
+	if err := dl.w.Flush(); err != nil {
+		_ = dl.file.Close()
+		return err
+	}
+	return dl.file.Close()
+}
+
+// Close flushes the buffered lines and closes the file.
+func (dl *deadLetterFile) Close() error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.closeFile()
+}
@@ -0,0 +1,201 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestOutputDeadLetterFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		message  string
+		expLines []string
+	}{
+		"Rejected": {
+			message: `failure writing points to database: field type conflict`,
+			expLines: []string{
+				"# precision=ns",
+				"test_counter value=1 1000000000",
+				"test_counter value=2 2000000000",
+				"test_counter value=3 3000000000",
+			},
+		},
+		"PartialWrite": {
+			message:  `partial write has occurred, errors encountered on line(s): line 2: field type conflict`,
+			expLines: []string{"# precision=ns", "test_counter value=2 2000000000"},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(http.StatusBadRequest)
+				_, _ = rw.Write([]byte(`{"code":"invalid","message":"` + tc.message + `"}`))
+			}))
+			t.Cleanup(ts.Close)
+
+			path := filepath.Join(t.TempDir(), "dead-letter.lp")
+			o, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     json.RawMessage(`{"deadLetterFile":"` + path + `","pushInterval":"1h"}`),
+			})
+			require.NoError(t, err)
+			require.NoError(t, o.Start())
+
+			registry := metrics.NewRegistry()
+			metric, err := registry.NewMetric("test_counter", metrics.Counter)
+			require.NoError(t, err)
+			var samples metrics.Samples
+			for i := 1; i <= 3; i++ {
+				samples = append(samples, metrics.Sample{
+					TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+					Time:       time.Unix(int64(i), 0),
+					Value:      float64(i),
+				})
+			}
+			o.AddMetricSamples([]metrics.SampleContainer{samples})
+			require.Error(t, o.Flush())
+			require.NoError(t, o.Stop())
+
+			b, err := os.ReadFile(path) //nolint:forbidigo
+			require.NoError(t, err)
+			assert.Equal(t, strings.Join(tc.expLines, "\n")+"\n", string(b))
+		})
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files") //nolint:gochecknoglobals
+
+// TestDeadLetterFileGolden checks the lines are the same across the runs, the keys added
+// by a point mutator are appended in the order of a map's iteration, that changes on each run.
+func TestDeadLetterFileGolden(t *testing.T) {
+	t.Parallel()
+
+	golden := filepath.Join("testdata", "dead-letter.golden")
+	points := func() []*write.Point {
+		added := map[string]string{"zone": "eu", "instance": "k6-1", "build": "42"}
+		p := influxdbclient.NewPoint("http_req_duration",
+			map[string]string{"status": "200", "method": "GET", "url": "http://test.k6.io"},
+			map[string]interface{}{"value": 1.5, "vu": int64(1), "expected_response": true},
+			time.Unix(1, 0))
+		for k, v := range added {
+			p.AddTag(k, v)
+			p.AddField(k+"_field", v)
+		}
+		return []*write.Point{
+			p,
+			influxdbclient.NewPoint("vus", nil, map[string]interface{}{"value": 10.0}, time.Unix(2, 0)),
+		}
+	}
+
+	var first []byte
+	for run := 0; run < 10; run++ {
+		path := filepath.Join(t.TempDir(), "dead-letter.lp")
+		dl := &deadLetterFile{path: path, precision: time.Nanosecond}
+		require.NoError(t, dl.open())
+		require.NoError(t, dl.writePoints(points()))
+		require.NoError(t, dl.Close())
+		got, err := os.ReadFile(path) //nolint:forbidigo
+		require.NoError(t, err)
+		if run == 0 {
+			first = got
+			continue
+		}
+		require.Equal(t, string(first), string(got), "run %d", run)
+	}
+
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, first, 0o600)) //nolint:forbidigo
+	}
+	expected, err := os.ReadFile(golden) //nolint:forbidigo
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(first))
+}
+
+func TestDeadLetterFileRotation(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dead-letter.lp")
+	// each line is 24 bytes and the marker is 14 bytes, so only 2 lines fit
+	dl := &deadLetterFile{path: path, maxSize: 80, precision: time.Second}
+	require.NoError(t, dl.open())
+
+	var points []*write.Point
+	for i := 1; i <= 5; i++ {
+		points = append(points, influxdbclient.NewPoint("test_counter",
+			nil, map[string]interface{}{"value": i}, time.Unix(int64(i), 0)))
+	}
+	require.NoError(t, dl.writePoints(points[:3]))
+	require.NoError(t, dl.writePoints(points[3:]))
+	require.NoError(t, dl.Close())
+
+	rotated, err := os.ReadFile(path + ".1") //nolint:forbidigo
+	require.NoError(t, err)
+	assert.Equal(t, "# precision=s\ntest_counter value=3i 3\ntest_counter value=4i 4\n", string(rotated))
+	current, err := os.ReadFile(path) //nolint:forbidigo
+	require.NoError(t, err)
+	assert.Equal(t, "# precision=s\ntest_counter value=5i 5\n", string(current))
+}
+
+func TestDeadLetterFileReplayPrecision(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dead-letter.lp")
+	// two runs, with different precisions, append to the same file
+	for i, precision := range []time.Duration{time.Second, time.Nanosecond} {
+		dl := &deadLetterFile{path: path, precision: precision}
+		require.NoError(t, dl.open())
+		require.NoError(t, dl.writePoints([]*write.Point{influxdbclient.NewPoint("test_counter",
+			nil, map[string]interface{}{"value": i}, time.Unix(int64(i+1), 0))}))
+		require.NoError(t, dl.Close())
+	}
+
+	pc := &precisionCollector{}
+	ts := httptest.NewServer(pc)
+	t.Cleanup(ts.Close)
+	err := ReplayFile(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"s: test_counter value=0i 1",
+		"ns: test_counter value=1i 2000000000",
+	}, pc.Writes())
+}
+
+func TestNewDeadLetterFileAsyncWrite(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "http://localhost:8086/testbucket",
+		JSONConfig:     json.RawMessage(`{"deadLetterFile":"dead-letter.lp","asyncWrite":true}`),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AsyncWrite")
+}
@@ -0,0 +1,128 @@
+package influxdb
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/sirupsen/logrus"
+)
+
+// maxAggregatedErrors is the maximum number of the distinct errors tracked by the aggregator,
+// a new error beyond it is logged without being aggregated.
+const maxAggregatedErrors = 100
+
+// errorAggregator throttles the logging of identical errors,
+// reporting how many times an error occurred at most once per window.
+// The server's errors are identified by their status and code, since their messages
+// contain the details of each request, e.g. the rejected lines.
+type errorAggregator struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*aggregatedError
+}
+
+type aggregatedError struct {
+	// message is the last error's message
+	message     string
+	lastLogged  time.Time
+	occurrences int
+}
+
+func newErrorAggregator(window time.Duration) *errorAggregator {
+	return &errorAggregator{
+		window:  window,
+		entries: make(map[string]*aggregatedError),
+	}
+}
+
+// record registers an occurrence of the error. It reports if the error
+// should be logged, and in that case the number of its occurrences
+// and the time elapsed since it was logged the last time.
+func (a *errorAggregator) record(err error, now time.Time) (bool, int, time.Duration) {
+	if a.window <= 0 {
+		return true, 1, 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := errorKey(err)
+	e, ok := a.entries[key]
+	if !ok {
+		if len(a.entries) >= maxAggregatedErrors {
+			a.evict(now)
+		}
+		if len(a.entries) < maxAggregatedErrors {
+			a.entries[key] = &aggregatedError{message: err.Error(), lastLogged: now}
+		}
+		return true, 1, 0
+	}
+
+	e.message = err.Error()
+	e.occurrences++
+	elapsed := now.Sub(e.lastLogged)
+	if elapsed < a.window {
+		return false, 0, 0
+	}
+	occurrences := e.occurrences
+	e.occurrences = 0
+	e.lastLogged = now
+	return true, occurrences, elapsed
+}
+
+// evict removes the errors logged out of the window, without occurrences to report.
+func (a *errorAggregator) evict(now time.Time) {
+	for key, e := range a.entries {
+		if e.occurrences == 0 && now.Sub(e.lastLogged) >= a.window {
+			delete(a.entries, key)
+		}
+	}
+}
+
+// drain returns the errors not yet logged with the number of their occurrences,
+// and it resets the state.
+func (a *errorAggregator) drain() map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pending := make(map[string]int)
+	for _, e := range a.entries {
+		if e.occurrences > 0 {
+			pending[e.message] += e.occurrences
+		}
+	}
+	a.entries = make(map[string]*aggregatedError)
+	return pending
+}
+
+// errorKey returns the key identifying the error, the server's status and error code
+// for a server's error, otherwise its message.
+func errorKey(err error) string {
+	var serr *http2.Error
+	if errors.As(err, &serr) && serr.StatusCode != 0 {
+		return strconv.Itoa(serr.StatusCode) + " " + serr.Code
+	}
+	return err.Error()
+}
+
+// serverErrorFields returns the details of the InfluxDB server's error response
+// as log fields, so the actual cause (e.g. a field type conflict) is always visible.
+// It returns nil if the error isn't from a server's response.
+func serverErrorFields(err error) logrus.Fields {
+	var serr *http2.Error
+	if !errors.As(err, &serr) || serr.StatusCode == 0 {
+		return nil
+	}
+	fields := logrus.Fields{"status": serr.StatusCode}
+	if serr.Code != "" {
+		fields["code"] = serr.Code
+	}
+	if serr.Message != "" {
+		fields["response"] = serr.Message
+	}
+	return fields
+}
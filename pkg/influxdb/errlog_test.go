@@ -0,0 +1,125 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorAggregator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Throttled", func(t *testing.T) {
+		t.Parallel()
+
+		a := newErrorAggregator(10 * time.Second)
+		errA, errB := errors.New("a"), errors.New("b")
+		now := time.Now()
+
+		ok, n, _ := a.record(errA, now)
+		assert.True(t, ok)
+		assert.Equal(t, 1, n)
+
+		// a different error isn't throttled by the first one
+		ok, n, _ = a.record(errB, now)
+		assert.True(t, ok)
+		assert.Equal(t, 1, n)
+
+		for i := 1; i < 5; i++ {
+			ok, _, _ = a.record(errA, now.Add(time.Duration(i)*time.Second))
+			assert.False(t, ok)
+		}
+
+		ok, n, since := a.record(errA, now.Add(10*time.Second))
+		assert.True(t, ok)
+		assert.Equal(t, 5, n)
+		assert.Equal(t, 10*time.Second, since)
+
+		ok, _, _ = a.record(errB, now.Add(11*time.Second))
+		assert.True(t, ok)
+		ok, _, _ = a.record(errA, now.Add(11*time.Second))
+		assert.False(t, ok)
+
+		assert.Equal(t, map[string]int{"a": 1}, a.drain())
+		assert.Empty(t, a.drain())
+	})
+
+	t.Run("ServerErrors", func(t *testing.T) {
+		t.Parallel()
+
+		a := newErrorAggregator(10 * time.Second)
+		now := time.Now()
+		partial := func(line int) error {
+			return fmt.Errorf("write failed: %w", &http2.Error{
+				StatusCode: 400, Code: "invalid",
+				Message: fmt.Sprintf("partial write has occurred, errors encountered on line(s): line %d: "+
+					"field type conflict", line),
+			})
+		}
+
+		// the errors of the requests are identified by their status and code, not by their details
+		ok, _, _ := a.record(partial(1), now)
+		assert.True(t, ok)
+		for i := 2; i <= 4; i++ {
+			ok, _, _ = a.record(partial(i), now.Add(time.Second))
+			assert.False(t, ok)
+		}
+		ok, _, _ = a.record(&http2.Error{StatusCode: 500, Code: "internal error"}, now)
+		assert.True(t, ok)
+
+		assert.Equal(t, map[string]int{partial(4).Error(): 3}, a.drain())
+	})
+
+	t.Run("Bounded", func(t *testing.T) {
+		t.Parallel()
+
+		a := newErrorAggregator(10 * time.Second)
+		now := time.Now()
+		for i := 0; i < maxAggregatedErrors; i++ {
+			a.record(fmt.Errorf("error %d", i), now)
+		}
+		// the new errors beyond the max are logged without being tracked
+		ok, n, _ := a.record(errors.New("untracked"), now.Add(time.Second))
+		assert.True(t, ok)
+		assert.Equal(t, 1, n)
+		ok, _, _ = a.record(errors.New("untracked"), now.Add(time.Second))
+		assert.True(t, ok)
+		assert.Len(t, a.entries, maxAggregatedErrors)
+
+		// the errors logged out of the window are evicted
+		ok, _, _ = a.record(errors.New("tracked"), now.Add(10*time.Second))
+		assert.True(t, ok)
+		ok, _, _ = a.record(errors.New("tracked"), now.Add(11*time.Second))
+		assert.False(t, ok)
+		assert.Len(t, a.entries, 1)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+
+		a := newErrorAggregator(0)
+		for i := 0; i < 3; i++ {
+			ok, n, _ := a.record(errors.New("a"), time.Now())
+			assert.True(t, ok)
+			assert.Equal(t, 1, n)
+		}
+		assert.Empty(t, a.drain())
+	})
+}
+
+func TestServerErrorFields(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, serverErrorFields(errors.New("connection refused")))
+	assert.Nil(t, serverErrorFields(http2.NewError(errors.New("connection refused"))))
+
+	serr := &http2.Error{StatusCode: 400, Code: "invalid", Message: "unable to parse"}
+	exp := logrus.Fields{"status": 400, "code": "invalid", "response": "unable to parse"}
+	assert.Equal(t, exp, serverErrorFields(serr))
+	assert.Equal(t, exp, serverErrorFields(fmt.Errorf("write failed: %w", serr)))
+}
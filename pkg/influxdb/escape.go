@@ -0,0 +1,81 @@
+package influxdb
+
+import "strings"
+
+// lineControlChars are the control characters that the line protocol's encoder writes
+// as the \n, \r, \t and \f sequences, that InfluxDB doesn't unescape and Telegraf rejects.
+const lineControlChars = "\n\r\t\f"
+
+// lineControlReplacer replaces the control characters with a space,
+// e.g. for the multi-line errors of the error tag.
+var lineControlReplacer = strings.NewReplacer( //nolint:gochecknoglobals // it is read-only
+	"\n", " ", "\r", " ", "\t", " ", "\f", " ")
+
+// escapedTagChars are the characters escaped by the encoder in the tag keys and values.
+const escapedTagChars = ", ="
+
+// safeTagText returns the tag's key or value written unambiguously by the line protocol's encoder.
+// A backslash before a character escaped by the encoder would escape its backslash instead,
+// splitting the tag, so it is doubled. A trailing backslash would escape the separator after the tag
+// and the line protocol can't represent it, so it is removed.
+func safeTagText(s string) string {
+	if !strings.ContainsAny(s, lineControlChars+`\`) {
+		return s
+	}
+	s = strings.TrimRight(lineControlReplacer.Replace(s), `\`)
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s) + 1)
+	for i := 0; i < len(s); i++ {
+		sb.WriteByte(s[i])
+		if s[i] == '\\' && strings.IndexByte(escapedTagChars, s[i+1]) >= 0 {
+			sb.WriteByte('\\')
+		}
+	}
+	return sb.String()
+}
+
+// safeStringField returns the string field's value without the control characters,
+// the quotes and the backslashes are already escaped by the encoder.
+func safeStringField(s string) string {
+	if !strings.ContainsAny(s, lineControlChars) {
+		return s
+	}
+	return lineControlReplacer.Replace(s)
+}
+
+// escapeTags rewrites in place the tags with the keys or the values
+// that the line protocol can't represent unchanged.
+// The rewritten keys are added after the iteration, so they are never escaped twice.
+func escapeTags(tags map[string]string) {
+	var renamed map[string]string
+	for k, v := range tags {
+		safeKey, safeValue := safeTagText(k), safeTagText(v)
+		switch {
+		case safeKey != k:
+			if renamed == nil {
+				renamed = make(map[string]string)
+			}
+			delete(tags, k)
+			renamed[safeKey] = safeValue
+		case safeValue != v:
+			tags[k] = safeValue
+		}
+	}
+	for k, v := range renamed {
+		tags[k] = v
+	}
+}
+
+// escapeStringFields rewrites in place the string fields' values with control characters.
+func escapeStringFields(values map[string]interface{}) {
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			if safe := safeStringField(s); safe != s {
+				values[k] = safe
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+)
+
+func TestSafeTagText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"plain":       "plain",
+		"a b,c=d":     "a b,c=d",
+		`a"b`:         `a"b`,
+		`a\b`:         `a\b`,
+		"a\nb\r\tc\f": "a b  c ",
+		`C:\dir\`:     `C:\dir`,
+		`a\\`:         `a`,
+		`\`:           ``,
+		`a\,b`:        `a\\,b`,
+		`a\ b`:        `a\\ b`,
+		`a\=b`:        `a\\=b`,
+		"a\\\nb":      `a\\ b`,
+	}
+	for s, exp := range tests {
+		assert.Equal(t, exp, safeTagText(s), s)
+	}
+}
+
+func TestEscapeTags(t *testing.T) {
+	t.Parallel()
+
+	tags := map[string]string{`a\,b`: `c\`, "ok": "x\ny", "plain": "plain"}
+	escapeTags(tags)
+	assert.Equal(t, map[string]string{`a\\,b`: `c`, "ok": "x y", "plain": "plain"}, tags)
+}
+
+func TestBatchFromSamplesLineProtocolRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value    string
+		expTag   string
+		expField string
+	}{
+		"NewLine":           {value: "line 1\nline 2", expTag: "line 1 line 2", expField: "line 1 line 2"},
+		"Quote":             {value: `say "hi"`, expTag: `say "hi"`, expField: `say "hi"`},
+		"Backslash":         {value: `a\b`, expTag: `a\b`, expField: `a\b`},
+		"Comma":             {value: "a,b", expTag: "a,b", expField: "a,b"},
+		"Equal":             {value: "a=b", expTag: "a=b", expField: "a=b"},
+		"Space":             {value: "a b", expTag: "a b", expField: "a b"},
+		"TrailingBackslash": {value: `C:\dir\`, expTag: `C:\dir`, expField: `C:\dir\`},
+		// InfluxDB doesn't unescape the double backslash of the tags
+		"BackslashComma": {value: `a\,b`, expTag: `a\\,b`, expField: `a\,b`},
+		"BackslashSpace": {value: `a\ b`, expTag: `a\\ b`, expField: `a\ b`},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			registry := metrics.NewRegistry()
+			metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+			require.NoError(t, err)
+			o := newTestOutput(t, `{"tagsAsFields":["url"]}`)
+			points := o.batchFromSamples([]metrics.SampleContainer{metrics.Samples{{
+				TimeSeries: metrics.TimeSeries{
+					Metric: metric,
+					Tags:   registry.RootTagSet().With("error", tc.value).With("url", tc.value).With("method", "GET"),
+				},
+				Time:  time.Unix(1, 0),
+				Value: 1,
+			}}})
+			require.Len(t, points, 1)
+
+			lines, err := encodeLines(points, time.Nanosecond)
+			require.NoError(t, err)
+			require.Len(t, lines, 1)
+			parsed, err := lp.NewParser(lp.NewMetricHandler()).Parse(lines[0])
+			require.NoError(t, err, string(lines[0]))
+			require.Len(t, parsed, 1)
+
+			tags := map[string]string{}
+			for _, tag := range parsed[0].TagList() {
+				tags[tag.Key] = tag.Value
+			}
+			fields := map[string]interface{}{}
+			for _, field := range parsed[0].FieldList() {
+				fields[field.Key] = field.Value
+			}
+			assert.Equal(t, map[string]string{"error": tc.expTag, "method": "GET"}, tags)
+			assert.Equal(t, map[string]interface{}{"url": tc.expField, "value": 1.0}, fields)
+		})
+	}
+}
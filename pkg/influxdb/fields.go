@@ -0,0 +1,108 @@
+package influxdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// FieldsOverflowTruncate drops the fields exceeding MaxFieldsPerPoint.
+	FieldsOverflowTruncate = "truncate"
+	// FieldsOverflowSplit moves the fields exceeding MaxFieldsPerPoint
+	// to more points with the same tags and timestamp.
+	FieldsOverflowSplit = "split"
+)
+
+const (
+	// OnFieldParseErrorFallbackString writes the tag's value as a string field
+	// when it can't be parsed as the field's type.
+	OnFieldParseErrorFallbackString = "fallback-string"
+	// OnFieldParseErrorDrop omits the field when the tag's value can't be parsed.
+	OnFieldParseErrorDrop = "drop"
+	// OnFieldParseErrorError skips the sample when the tag's value can't be parsed.
+	OnFieldParseErrorError = "error"
+)
+
+// checkOnFieldParseError returns an error if the behavior for the tags' parse errors isn't valid.
+func checkOnFieldParseError(behavior string) error {
+	switch behavior {
+	case OnFieldParseErrorFallbackString, OnFieldParseErrorDrop, OnFieldParseErrorError:
+		return nil
+	default:
+		return fmt.Errorf("an invalid behavior on the field's parse error (%s) is specified, "+
+			"the allowed values are: %s, %s and %s",
+			behavior, OnFieldParseErrorFallbackString, OnFieldParseErrorDrop, OnFieldParseErrorError)
+	}
+}
+
+// fieldParseError returns the error of the tag whose value can't be parsed as the field's type,
+// it is logged as a warning only the first time for each tag.
+func (o *Output) fieldParseError(tag, value string, err error) error {
+	logger := o.logger.WithError(err).WithField("tag", tag).WithField("value", value)
+	if _, logged := o.fieldParseErrors.LoadOrStore(tag, struct{}{}); logged {
+		logger.Debug("The tag can't be parsed as a field, the sample is skipped")
+	} else {
+		logger.Warn("The tag can't be parsed as a field, the samples with an invalid value are skipped")
+	}
+	return fmt.Errorf("the %s tag can't be parsed as a field: %w", tag, err)
+}
+
+// checkFieldsOverflow returns an error if the fields' limit or the overflow's behavior aren't valid.
+func checkFieldsOverflow(conf Config) error {
+	if conf.MaxFieldsPerPoint.Int64 < 0 {
+		return fmt.Errorf("the MaxFieldsPerPoint option can't be a negative number")
+	}
+	switch conf.FieldsOverflow.String {
+	case FieldsOverflowTruncate, FieldsOverflowSplit:
+		return nil
+	default:
+		return fmt.Errorf("an invalid fields overflow (%s) is specified, the allowed values are: %s and %s",
+			conf.FieldsOverflow.String, FieldsOverflowTruncate, FieldsOverflowSplit)
+	}
+}
+
+// limitFields returns the fields of each point written for the values, so a point has at most
+// MaxFieldsPerPoint fields. The primary field, if any, is kept first and the others
+// are ordered by key, so the truncated or split fields are always the same.
+func (o *Output) limitFields(values map[string]interface{}, primary string) []map[string]interface{} {
+	limit := int(o.config.MaxFieldsPerPoint.Int64)
+	if limit <= 0 || len(values) <= limit {
+		return []map[string]interface{}{values}
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if k != primary {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := values[primary]; ok {
+		keys = append([]string{primary}, keys...)
+	}
+
+	split := o.config.FieldsOverflow.String == FieldsOverflowSplit
+	if o.fieldsOverflowWarned.CompareAndSwap(false, true) {
+		o.logger.WithField("fields", len(values)).WithField("max", limit).
+			WithField("overflow", o.config.FieldsOverflow.String).
+			Warn("A point exceeds MaxFieldsPerPoint, the next ones aren't logged")
+	}
+
+	var fieldSets []map[string]interface{}
+	for len(keys) > 0 {
+		n := limit
+		if n > len(keys) {
+			n = len(keys)
+		}
+		fields := make(map[string]interface{}, n)
+		for _, k := range keys[:n] {
+			fields[k] = values[k]
+		}
+		fieldSets = append(fieldSets, fields)
+		if !split {
+			break
+		}
+		keys = keys[n:]
+	}
+	return fieldSets
+}
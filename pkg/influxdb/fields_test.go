@@ -0,0 +1,173 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+// pointFields returns the point's fields by key.
+func pointFields(p *write.Point) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, f := range p.FieldList() {
+		fields[f.Key] = f.Value
+	}
+	return fields
+}
+
+func TestBatchFromSamplesMaxFieldsPerPoint(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	tags := registry.RootTagSet().With("status", "200").
+		With("d", "4").With("b", "2").With("c", "3").With("a", "1")
+	samples := metrics.Samples{{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tags},
+		Time:       time.Unix(1, 0),
+		Value:      1.5,
+	}}
+	fields := func(points []*write.Point) []map[string]interface{} {
+		got := make([]map[string]interface{}, 0, len(points))
+		for _, p := range points {
+			got = append(got, pointFields(p))
+		}
+		return got
+	}
+
+	tests := map[string]struct {
+		conf string
+		exp  []map[string]interface{}
+	}{
+		"Truncate": {
+			conf: `{"tagsAsFields":["a:int","b:int","c:int","d:int"],"maxFieldsPerPoint":2}`,
+			exp:  []map[string]interface{}{{"value": 1.5, "a": int64(1)}},
+		},
+		"Split": {
+			conf: `{"tagsAsFields":["a:int","b:int","c:int","d:int"],"maxFieldsPerPoint":2,"fieldsOverflow":"split"}`,
+			exp: []map[string]interface{}{
+				{"value": 1.5, "a": int64(1)},
+				{"b": int64(2), "c": int64(3)},
+				{"d": int64(4)},
+			},
+		},
+		"NotExceeded": {
+			conf: `{"tagsAsFields":["a:int","b:int","c:int","d:int"],"maxFieldsPerPoint":5}`,
+			exp:  []map[string]interface{}{{"value": 1.5, "a": int64(1), "b": int64(2), "c": int64(3), "d": int64(4)}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestOutput(t, tc.conf)
+			points := o.batchFromSamples([]metrics.SampleContainer{samples})
+			assert.Equal(t, tc.exp, fields(points))
+			for _, p := range points {
+				require.Len(t, p.TagList(), 1)
+				assert.Equal(t, "status", p.TagList()[0].Key)
+				assert.Equal(t, time.Unix(1, 0), p.Time())
+			}
+		})
+	}
+}
+
+func TestNewFieldsOverflow(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		`{"bucket":"b","maxFieldsPerPoint":-1}`:                        "MaxFieldsPerPoint option can't be a negative number",
+		`{"bucket":"b","maxFieldsPerPoint":5,"fieldsOverflow":"drop"}`: "invalid fields overflow (drop)",
+	}
+	for conf, expErr := range tests {
+		_, err := New(output.Params{
+			Logger:     testutils.NewLogger(t),
+			JSONConfig: json.RawMessage(conf),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), expErr)
+	}
+}
+
+func TestExtractTagsToValuesOnFieldParseError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		behavior  string
+		expValues map[string]interface{}
+		expErr    bool
+	}{
+		"FallbackString": {
+			behavior:  OnFieldParseErrorFallbackString,
+			expValues: map[string]interface{}{"retries": "many", "status": int64(200)},
+		},
+		"Drop": {
+			behavior:  OnFieldParseErrorDrop,
+			expValues: map[string]interface{}{"status": int64(200)},
+		},
+		"Error": {
+			behavior: OnFieldParseErrorError,
+			expErr:   true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestOutput(t, `{"tagsAsFields":["retries:int","status:int"],"onFieldParseError":"`+tc.behavior+`"}`)
+			tags := map[string]string{"retries": "many", "status": "200", "method": "GET"}
+			values, err := o.extractTagsToValues("test_metric", tags, map[string]interface{}{})
+			if tc.expErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "the retries tag can't be parsed as a field")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expValues, values)
+			assert.Equal(t, map[string]string{"method": "GET"}, tags)
+		})
+	}
+}
+
+func TestBatchFromSamplesOnFieldParseErrorSkipsSample(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	newSample := func(retries string) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("retries", retries)},
+			Time:       time.Unix(1, 0),
+			Value:      1,
+		}
+	}
+
+	o := newTestOutput(t, `{"tagsAsFields":["retries:int"],"onFieldParseError":"error"}`)
+	// the same invalid tags twice, for the cached error
+	samples := metrics.Samples{newSample("many"), newSample("2"), newSample("many")}
+	points := o.batchFromSamples([]metrics.SampleContainer{samples})
+	require.Len(t, points, 1)
+	assert.Equal(t, map[string]interface{}{"retries": int64(2), "value": 1.0}, pointFields(points[0]))
+}
+
+func TestNewInvalidOnFieldParseError(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(output.Params{
+		Logger:     testutils.NewLogger(t),
+		JSONConfig: json.RawMessage(`{"bucket":"b","onFieldParseError":"ignore"}`),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid behavior on the field's parse error (ignore)")
+}
@@ -0,0 +1,25 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/domain"
+)
+
+// checkHealth returns an error if InfluxDB isn't reachable or it doesn't report itself as healthy.
+func checkHealth(ctx context.Context, cl influxdbclient.Client) error {
+	hc, err := cl.Health(ctx)
+	if err != nil {
+		return fmt.Errorf("the InfluxDB health check failed: %w", err)
+	}
+	if hc.Status != domain.HealthCheckStatusPass {
+		msg := ""
+		if hc.Message != nil {
+			msg = ": " + *hc.Message
+		}
+		return fmt.Errorf("InfluxDB isn't healthy, the status is %s%s", hc.Status, msg)
+	}
+	return nil
+}
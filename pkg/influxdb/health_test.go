@@ -0,0 +1,101 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+)
+
+func TestNewWithContextStartupHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		status int
+		body   string
+		expErr string
+	}{
+		"Pass": {
+			status: http.StatusOK,
+			body:   `{"name":"influxdb","status":"pass"}`,
+		},
+		"Fail": {
+			status: http.StatusServiceUnavailable,
+			body:   `{"name":"influxdb","status":"fail","message":"not ready"}`,
+			expErr: "not ready",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/health", r.URL.Path)
+				rw.Header().Set("Content-Type", "application/json")
+				rw.WriteHeader(tc.status)
+				_, _ = rw.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			o, err := NewWithContext(context.Background(), output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: ts.URL + "/mybucket",
+				JSONConfig:     json.RawMessage(`{"startupHealthCheck":true}`),
+			})
+			if tc.expErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, o)
+		})
+	}
+}
+
+func TestNewWithContextCancelledDuringHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(received)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewWithContext(ctx, output.Params{
+			Logger:         testutils.NewLogger(t),
+			ConfigArgument: ts.URL + "/mybucket",
+			JSONConfig:     json.RawMessage(`{"startupHealthCheck":true}`),
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the construction hasn't been aborted by the cancelled context")
+	}
+}
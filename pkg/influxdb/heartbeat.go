@@ -0,0 +1,24 @@
+package influxdb
+
+import (
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// emitHeartbeat writes in background a point marking the output as alive, so the data
+// has no gaps during the quiet periods of a test. It is tagged as the lifecycle events,
+// a failed write is only logged.
+func (o *Output) emitHeartbeat(t time.Time) {
+	values := map[string]interface{}{o.config.HeartbeatField.String: 1.0}
+	p := influxdbclient.NewPoint(o.config.HeartbeatMeasurement.String, o.runTags(), values, t)
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if err := o.currentWriter().WritePoint(o.ctx, p); err != nil {
+			o.logger.WithError(err).Warn("Couldn't write the heartbeat")
+			return
+		}
+		o.logger.Debug("The heartbeat has been written")
+	}()
+}
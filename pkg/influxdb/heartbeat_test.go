@@ -0,0 +1,61 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+)
+
+func TestHeartbeatWithEmptyBuffer(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig: json.RawMessage(
+			`{"emitHeartbeat":true,"heartbeatMeasurement":"k6_alive","heartbeatField":"beat","pushInterval":"50ms"}`),
+		ScriptOptions: lib.Options{RunTags: map[string]string{"env": "staging"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	// no sample is added, a heartbeat is written on each push interval anyway
+	require.Eventually(t, func() bool { return len(lc.Lines()) >= 3 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, o.Stop())
+
+	for _, line := range lc.Lines() {
+		assert.True(t, strings.HasPrefix(line, "k6_alive,env=staging beat=1 "), line)
+	}
+}
+
+func TestHeartbeatDisabled(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"pushInterval":"10ms"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, o.Stop())
+
+	assert.Empty(t, lc.Lines())
+}
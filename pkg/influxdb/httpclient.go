@@ -0,0 +1,36 @@
+package influxdb
+
+import (
+	"net/http"
+	"sync"
+)
+
+var ( //nolint:gochecknoglobals // the client is set by the extensions before the outputs are created
+	defaultHTTPClientMu sync.RWMutex
+	defaultHTTPClient   *http.Client
+)
+
+// SetHTTPClient sets the HTTP client used by the outputs created after the call,
+// and by ReplayFile, instead of the client built from the options.
+// It gives the full control of the HTTP stack, e.g. a custom dialer, tracing or a circuit breaker,
+// so the TLS, HTTP/2 and connections' pool options and the Unix domain socket's address are ignored.
+// The client is shared and it isn't closed by the outputs.
+// Setting it to nil restores the default client:
+//
+//	func init() {
+//		influxdb.SetHTTPClient(&http.Client{
+//			Timeout:   20 * time.Second,
+//			Transport: otelhttp.NewTransport(http.DefaultTransport),
+//		})
+//	}
+func SetHTTPClient(c *http.Client) {
+	defaultHTTPClientMu.Lock()
+	defer defaultHTTPClientMu.Unlock()
+	defaultHTTPClient = c
+}
+
+func getHTTPClient() *http.Client {
+	defaultHTTPClientMu.RLock()
+	defer defaultHTTPClientMu.RUnlock()
+	return defaultHTTPClient
+}
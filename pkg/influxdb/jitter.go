@@ -0,0 +1,61 @@
+package influxdb
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitteredInterval returns a random interval in the [interval-jitter, interval+jitter] range.
+func jitteredInterval(interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return interval - jitter + time.Duration(rand.Int63n(int64(2*jitter)+1)) //nolint:gosec
+}
+
+// jitteredFlusher calls the flush callback after a new jittered interval on each tick,
+// as output.PeriodicFlusher it calls the callback a last time when it is stopped.
+type jitteredFlusher struct {
+	clock         clock
+	interval      time.Duration
+	jitter        time.Duration
+	flushCallback func()
+	stop          chan struct{}
+	stopped       chan struct{}
+	once          sync.Once
+}
+
+func newJitteredFlusher(c clock, interval, jitter time.Duration, flushCallback func()) *jitteredFlusher {
+	jf := &jitteredFlusher{
+		clock:         c,
+		interval:      interval,
+		jitter:        jitter,
+		flushCallback: flushCallback,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go jf.run()
+	return jf
+}
+
+func (jf *jitteredFlusher) run() {
+	defer close(jf.stopped)
+	for {
+		select {
+		case <-jf.clock.After(jitteredInterval(jf.interval, jf.jitter)):
+			jf.flushCallback()
+		case <-jf.stop:
+			jf.flushCallback()
+			return
+		}
+	}
+}
+
+// Stop flushes the last time and waits for the flush's completion.
+func (jf *jitteredFlusher) Stop() {
+	jf.once.Do(func() {
+		close(jf.stop)
+	})
+	<-jf.stopped
+}
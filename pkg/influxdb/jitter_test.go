@@ -0,0 +1,88 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+)
+
+func TestJitteredInterval(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, time.Second, jitteredInterval(time.Second, 0))
+
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 1000; i++ {
+		d := jitteredInterval(time.Second, 200*time.Millisecond)
+		assert.GreaterOrEqual(t, d, 800*time.Millisecond)
+		assert.LessOrEqual(t, d, 1200*time.Millisecond)
+		seen[d] = struct{}{}
+	}
+	assert.Greater(t, len(seen), 1, "the interval hasn't been randomized")
+}
+
+func TestJitteredFlusher(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+	jf := newJitteredFlusher(realClock{}, 20*time.Millisecond, 10*time.Millisecond, func() { calls.Add(1) })
+	start := time.Now()
+	require.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, time.Millisecond)
+	// at least 3 ticks of 10ms at least
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	jf.Stop()
+	n := calls.Load()
+	jf.Stop()
+	time.Sleep(50 * time.Millisecond)
+	// no more flushes after the last one done by Stop
+	assert.Equal(t, n, calls.Load())
+}
+
+func TestNewPushIntervalJitter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		conf   string
+		expErr bool
+	}{
+		"Unset":                {conf: `{}`},
+		"Valid":                {conf: `{"pushInterval":"1s","pushIntervalJitter":"500ms"}`},
+		"Negative":             {conf: `{"pushIntervalJitter":"-1s"}`, expErr: true},
+		"TooLarge":             {conf: `{"pushInterval":"1s","pushIntervalJitter":"1s"}`, expErr: true},
+		"PerTick":              {conf: `{"pushIntervalJitter":"100ms","pushIntervalJitterPerTick":true}`},
+		"PerTickWithoutJitter": {conf: `{"pushIntervalJitterPerTick":true}`},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: "http://localhost:8086/testbucket",
+				JSONConfig:     json.RawMessage(tc.conf),
+			})
+			if tc.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestStartPushIntervalJitterPerTick(t *testing.T) {
+	t.Parallel()
+
+	o := newTestOutput(t, `{"pushInterval":"1s","pushIntervalJitter":"100ms","pushIntervalJitterPerTick":true}`)
+	require.NoError(t, o.Start())
+	_, ok := o.periodicFlusher.(*jitteredFlusher)
+	assert.True(t, ok)
+	require.NoError(t, o.Stop())
+}
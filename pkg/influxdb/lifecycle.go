@@ -0,0 +1,45 @@
+package influxdb
+
+import (
+	"context"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+)
+
+const (
+	lifecyclePhaseStart = "start"
+	lifecyclePhaseStop  = "stop"
+)
+
+// writeLifecycleEvent writes immediately a point marking the test's start or stop,
+// e.g. for the dashboards' annotations. It is tagged with the run's tags, the global tags and the run's ID,
+// a failed write is only logged, so it doesn't abort the test.
+func (o *Output) writeLifecycleEvent(ctx context.Context, phase string, t time.Time) {
+	values := map[string]interface{}{"phase": phase}
+	p := influxdbclient.NewPoint(o.config.LifecycleEventsMeasurement.String, o.runTags(), values, t)
+	if err := o.currentWriter().WritePoint(ctx, p); err != nil {
+		o.logger.WithError(err).WithField("phase", phase).Warn("Couldn't write the lifecycle event")
+		return
+	}
+	o.logger.WithField("phase", phase).Debug("The lifecycle event has been written")
+}
+
+// runTags returns the tags of the points describing the test run instead of a sample:
+// the run's tags, the global tags and the run's ID.
+func (o *Output) runTags() map[string]string {
+	tags := make(map[string]string, len(o.params.ScriptOptions.RunTags)+len(o.config.GlobalTags)+1)
+	for k, v := range o.params.ScriptOptions.RunTags {
+		tags[k] = v
+	}
+	o.addGlobalTags(tags)
+	o.filterTags(tags)
+	if o.config.AddRunID.Bool {
+		tags[o.config.RunIDTag.String] = o.config.RunID.String
+	}
+	if o.keySanitizer != nil {
+		o.keySanitizer.sanitizeTags(tags)
+	}
+	escapeTags(tags)
+	return tags
+}
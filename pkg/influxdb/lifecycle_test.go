@@ -0,0 +1,98 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestLifecycleEvents(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"emitLifecycleEvents":true,"addRunID":true,"runID":"abc"}`),
+		ScriptOptions:  lib.Options{RunTags: map[string]string{"env": "staging"}},
+	})
+	require.NoError(t, err)
+
+	beforeStart := time.Now()
+	require.NoError(t, o.Start())
+	afterStart := time.Now()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+		Time:       time.Now(),
+		Value:      1,
+	}})
+
+	beforeStop := time.Now()
+	require.NoError(t, o.Stop())
+	afterStop := time.Now()
+
+	lines := lc.Lines()
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1], "test_counter,run_id=abc "), lines[1])
+
+	assertEvent := func(line, phase string, from, to time.Time) {
+		fields := strings.Split(line, " ")
+		require.Len(t, fields, 3, line)
+		assert.Equal(t, "k6_events,env=staging,run_id=abc", fields[0])
+		assert.Equal(t, `phase="`+phase+`"`, fields[1])
+		ns, err := strconv.ParseInt(fields[2], 10, 64)
+		require.NoError(t, err)
+		ts := time.Unix(0, ns)
+		assert.False(t, ts.Before(from), "%s is before %s", ts, from)
+		assert.False(t, ts.After(to), "%s is after %s", ts, to)
+	}
+	assertEvent(lines[0], "start", beforeStart, afterStart)
+	assertEvent(lines[2], "stop", beforeStop, afterStop)
+}
+
+func TestLifecycleEventsWriteFailure(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"emitLifecycleEvents":true}`),
+	})
+	require.NoError(t, err)
+	// the events are best effort, they don't fail the test
+	require.NoError(t, o.Start())
+	require.NoError(t, o.Stop())
+}
+
+func TestLifecycleEventsEmptyMeasurement(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(output.Params{
+		Logger:     testutils.NewLogger(t),
+		JSONConfig: json.RawMessage(`{"bucket":"b","emitLifecycleEvents":true,"lifecycleEventsMeasurement":""}`),
+	})
+	require.Error(t, err)
+}
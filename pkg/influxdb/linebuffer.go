@@ -0,0 +1,353 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/metrics"
+)
+
+// checkStreamLineProtocol returns an error if StreamLineProtocol is enabled with an option
+// that requires the intermediate points.
+func checkStreamLineProtocol(c Config) error {
+	if !c.StreamLineProtocol.Bool {
+		return nil
+	}
+	unsupported := []struct {
+		name string
+		set  bool
+	}{
+		{"AsyncWrite", c.AsyncWrite.Bool},
+		{"SingleMeasurement", c.SingleMeasurement.Bool},
+		{"OrgFromTag", c.OrgFromTag.String != ""},
+		{"DisambiguateTimestamps", c.DisambiguateTimestamps.Bool},
+		{"SortByTime", c.SortByTime.Bool},
+		{"DeadLetterFile", c.DeadLetterFile.String != ""},
+		{"MaxFieldsPerPoint", c.MaxFieldsPerPoint.Int64 > 0},
+		{"MaxBatchBytes", c.MaxBatchBytes.Int64 > 0},
+		{"LogTopMetrics", c.LogTopMetrics.Int64 > 0},
+		{"WALDir", c.WALDir.String != ""},
+	}
+	for _, opt := range unsupported {
+		if opt.set {
+			return fmt.Errorf("the %s option isn't supported with StreamLineProtocol", opt.name)
+		}
+	}
+	if c.Flavor.String == FlavorTelegraf {
+		return fmt.Errorf("the StreamLineProtocol option isn't supported by the %s flavor", FlavorTelegraf)
+	}
+	return nil
+}
+
+// lineBuffer is a reusable buffer of line protocol, the lines are encoded directly
+// from the samples' tags and fields, as the line protocol's encoder does for the points.
+type lineBuffer struct {
+	buf       bytes.Buffer
+	precision time.Duration
+	// keys is reused for sorting the tags and the fields
+	keys []string
+	// num is reused for formatting the numbers
+	num []byte
+	// ends are the offsets of the chunks' ends, a chunk is written by a request
+	ends  []int
+	lines int
+}
+
+func (lb *lineBuffer) reset() {
+	lb.buf.Reset()
+	lb.ends = lb.ends[:0]
+	lb.lines = 0
+}
+
+// chunks returns the encoded lines in chunks of at most MaxBatchSize lines.
+func (lb *lineBuffer) chunks() []string {
+	data := lb.buf.Bytes()
+	chunks := make([]string, 0, len(lb.ends)+1)
+	start := 0
+	for _, end := range lb.ends {
+		chunks = append(chunks, string(data[start:end]))
+		start = end
+	}
+	if start < len(data) {
+		chunks = append(chunks, string(data[start:]))
+	}
+	return chunks
+}
+
+// appendLine encodes a line, the tags and the fields are sorted by key. The tags with an empty key
+// or value are skipped, as the point's encoder does. Nothing is written if a field can't be encoded.
+func (lb *lineBuffer) appendLine(
+	name string, tags map[string]string, fields map[string]interface{}, t time.Time,
+) error {
+	if name == "" {
+		return errors.New("invalid name")
+	}
+	if len(fields) == 0 {
+		return errors.New("no serializable fields")
+	}
+	mark := lb.buf.Len()
+	lb.writeEscaped(name, false)
+	for _, k := range lb.sortedTagKeys(tags) {
+		v := tags[k]
+		if k == "" || v == "" {
+			continue
+		}
+		lb.buf.WriteByte(',')
+		lb.writeEscaped(k, true)
+		lb.buf.WriteByte('=')
+		lb.writeEscaped(v, true)
+	}
+	lb.buf.WriteByte(' ')
+	for i, k := range lb.sortedFieldKeys(fields) {
+		if k == "" {
+			lb.buf.Truncate(mark)
+			return errors.New("invalid field key")
+		}
+		if i > 0 {
+			lb.buf.WriteByte(',')
+		}
+		lb.writeEscaped(k, true)
+		lb.buf.WriteByte('=')
+		if err := lb.writeFieldValue(fields[k]); err != nil {
+			lb.buf.Truncate(mark)
+			return err
+		}
+	}
+	if !t.IsZero() {
+		lb.buf.WriteByte(' ')
+		lb.num = strconv.AppendInt(lb.num[:0], timestamp(t, lb.precision), 10)
+		lb.buf.Write(lb.num)
+	}
+	lb.buf.WriteByte('\n')
+	lb.lines++
+	return nil
+}
+
+func (lb *lineBuffer) sortedTagKeys(tags map[string]string) []string {
+	lb.keys = lb.keys[:0]
+	for k := range tags {
+		lb.keys = append(lb.keys, k)
+	}
+	sort.Strings(lb.keys)
+	return lb.keys
+}
+
+func (lb *lineBuffer) sortedFieldKeys(fields map[string]interface{}) []string {
+	lb.keys = lb.keys[:0]
+	for k := range fields {
+		lb.keys = append(lb.keys, k)
+	}
+	sort.Strings(lb.keys)
+	return lb.keys
+}
+
+// writeEscaped writes a measurement's name, or a key or a tag's value if equals is set,
+// that also escapes the equal sign.
+func (lb *lineBuffer) writeEscaped(s string, equals bool) {
+	if !strings.ContainsAny(s, "\t\n\f\r ,=") {
+		lb.buf.WriteString(s)
+		return
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\t':
+			lb.buf.WriteString(`\t`)
+		case '\n':
+			lb.buf.WriteString(`\n`)
+		case '\f':
+			lb.buf.WriteString(`\f`)
+		case '\r':
+			lb.buf.WriteString(`\r`)
+		case ',', ' ':
+			lb.buf.WriteByte('\\')
+			lb.buf.WriteByte(c)
+		case '=':
+			if equals {
+				lb.buf.WriteByte('\\')
+			}
+			lb.buf.WriteByte(c)
+		default:
+			lb.buf.WriteByte(c)
+		}
+	}
+}
+
+func (lb *lineBuffer) writeFieldValue(value interface{}) error {
+	b := lb.num[:0]
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("the field value %v is unsupported", v)
+		}
+		b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	case int64:
+		b = append(strconv.AppendInt(b, v, 10), 'i')
+	case int:
+		b = append(strconv.AppendInt(b, int64(v), 10), 'i')
+	case uint64:
+		b = append(strconv.AppendUint(b, v, 10), 'u')
+	case bool:
+		b = strconv.AppendBool(b, v)
+	case string:
+		lb.buf.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			switch c := v[i]; c {
+			case '\t':
+				lb.buf.WriteString(`\t`)
+			case '\n':
+				lb.buf.WriteString(`\n`)
+			case '\f':
+				lb.buf.WriteString(`\f`)
+			case '\r':
+				lb.buf.WriteString(`\r`)
+			case '"', '\\':
+				lb.buf.WriteByte('\\')
+				lb.buf.WriteByte(c)
+			default:
+				lb.buf.WriteByte(c)
+			}
+		}
+		lb.buf.WriteByte('"')
+		return nil
+	default:
+		return fmt.Errorf("invalid value type: %T", v)
+	}
+	lb.num = b
+	lb.buf.Write(b)
+	return nil
+}
+
+// timestamp returns the time in the unit of the precision, as the point's encoder does.
+func timestamp(t time.Time, precision time.Duration) int64 {
+	switch precision {
+	case time.Microsecond:
+		return t.UnixNano() / 1000
+	case time.Millisecond:
+		return t.UnixNano() / 1000000
+	case time.Second:
+		return t.Unix()
+	default:
+		return t.UnixNano()
+	}
+}
+
+// streamsLines reports if the samples are encoded directly in a line buffer,
+// the points are always built when a point mutator is set.
+func (o *Output) streamsLines() bool {
+	return o.config.StreamLineProtocol.Bool && o.PointMutator == nil
+}
+
+func (o *Output) getLineBuffer() *lineBuffer {
+	if lb, ok := o.lineBuffers.Get().(*lineBuffer); ok {
+		return lb
+	}
+	// the precision has been already validated by New
+	precision, _ := o.config.writePrecision()
+	return &lineBuffer{precision: precision}
+}
+
+func (o *Output) putLineBuffer(lb *lineBuffer) {
+	lb.reset()
+	o.lineBuffers.Put(lb)
+}
+
+// encodeSamples encodes the lines of the samples, as batchFromSamples builds their points.
+func (o *Output) encodeSamples(lb *lineBuffer, containers []metrics.SampleContainer) {
+	containers = o.sampleTrends(containers)
+	maxBatchSize := int(o.config.MaxBatchSize.Int64)
+	cache := o.newTagsCache()
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			if !o.isSampleWritten(sample) {
+				continue
+			}
+			tags, values, err := o.sampleTagsAndValues(sample, cache)
+			if err != nil {
+				// it is already logged
+				continue
+			}
+			o.addSampleValue(sample, values)
+			if !o.finiteFields(sample.Metric.Name, values) || len(values) == 0 {
+				continue
+			}
+			measurement, tags := o.pointMeasurement(sample, tags)
+			err = lb.appendLine(measurement, tags, values, o.pointTime(sample))
+			if err != nil {
+				o.logger.WithError(err).WithField("metric", sample.Metric.Name).
+					Debug("The sample can't be encoded as line protocol, it has been skipped")
+				continue
+			}
+			if maxBatchSize > 0 && lb.lines%maxBatchSize == 0 {
+				lb.ends = append(lb.ends, lb.buf.Len())
+			}
+		}
+	}
+}
+
+// writeSampleLines encodes the samples in a reusable line buffer and writes it,
+// without building the intermediate points.
+func (o *Output) writeSampleLines(samples []metrics.SampleContainer, start time.Time) error {
+	lb := o.getLineBuffer()
+	defer o.putLineBuffer(lb)
+	o.encodeSamples(lb, samples)
+	if lb.lines == 0 {
+		return nil
+	}
+	if err := o.waitRateLimit(lb.lines); err != nil {
+		o.logger.WithField("points", lb.lines).Warn("The metrics points write has been cancelled")
+		return err
+	}
+
+	o.logger.WithField("samples", len(samples)).WithField("points", lb.lines).Debug("Sending metrics lines...")
+	var werr error
+	chunks := lb.chunks()
+	failed := 0
+	for _, chunk := range chunks {
+		if err := o.writeLines(chunk, start); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			// the next chunks are still sent, the error is already logged
+			werr = err
+			failed++
+		}
+	}
+	return o.endFlush(start, lb.lines, failed == len(chunks), werr)
+}
+
+// writeLines writes a chunk of lines, as writeBatch writes the points.
+func (o *Output) writeLines(chunk string, start time.Time) error {
+	lines := strings.Count(chunk, "\n")
+	if err := o.backoff.wait(o.ctx); err != nil {
+		o.logger.WithField("points", lines).Warn("The metrics points write has been cancelled")
+		return err
+	}
+	wait := o.writeMirrors(lines, func(w pointsWriter) error {
+		return w.WriteRecord(o.ctx, chunk)
+	})
+	defer wait()
+	if err := o.orgWriter("").WriteRecord(o.ctx, chunk); err != nil {
+		if errors.Is(err, context.Canceled) {
+			o.logger.WithField("points", lines).Warn("The metrics points write has been cancelled")
+			return err
+		}
+		o.recordWriteOutcome(true)
+		if pause := o.backoff.record(err); pause > 0 {
+			o.logger.WithField("pause", pause).Warn("InfluxDB has requested to retry later, the writes are paused")
+		}
+		o.logWriteError(err, logrus.Fields{"elapsed": o.clock.Now().Sub(start), "points": lines})
+		o.notifyWriteError(err, lines)
+		return err
+	}
+	o.recordWriteOutcome(false)
+	o.recordThroughput(lines)
+	return nil
+}
@@ -0,0 +1,198 @@
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestEncodeSamplesMatchesPoints(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	counter, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	check, err := registry.NewMetric("checks", metrics.Rate)
+	require.NoError(t, err)
+	trend, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	samples := metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: counter,
+				Tags: registry.RootTagSet().WithTagsFromMap(map[string]string{
+					"url": `http://test.k6.io/a b,c=d`, "vu": "7", "iter": "3", "method": "GET", "empty": "",
+				}),
+			},
+			Time:  now,
+			Value: 1,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: check,
+				Tags:   registry.RootTagSet().WithTagsFromMap(map[string]string{"check": "status is 200", "status": "200"}),
+			},
+			Time:  now.Add(time.Millisecond),
+			Value: 1,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: trend,
+				Tags:   registry.RootTagSet().With("name", "tab\there \"quoted\""),
+			},
+			Time:  now.Add(2 * time.Millisecond),
+			Value: 0.000123,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: trend,
+				Tags:   registry.RootTagSet().With("method", "POST"),
+			},
+			Time:  now,
+			Value: math.NaN(),
+		},
+	}
+
+	for _, precision := range []string{"ns", "us", "ms", "s"} {
+		precision := precision
+		t.Run(precision, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestOutput(t, `{"precisionUnit":"`+precision+`","tagsAsFields":["vu:int","iter:int","url","name"]}`)
+			p, err := o.config.writePrecision()
+			require.NoError(t, err)
+
+			var expected bytes.Buffer
+			e := newLineEncoder(&expected, p)
+			for _, point := range o.batchFromSamples([]metrics.SampleContainer{samples}) {
+				// the points with an unsupported value are skipped by the client too
+				_, _ = e.Encode(point)
+			}
+
+			lb := o.getLineBuffer()
+			o.encodeSamples(lb, []metrics.SampleContainer{samples})
+			assert.Equal(t, 3, lb.lines)
+			assert.Equal(t, expected.String(), lb.buf.String())
+		})
+	}
+}
+
+func TestLineBufferChunks(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	samples := make(metrics.Samples, 0, 5)
+	for i := 0; i < 5; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("method", "GET")},
+			Time:       time.Unix(int64(i), 0),
+			Value:      float64(i),
+		})
+	}
+
+	o := newTestOutput(t, `{"maxBatchSize":2,"streamLineProtocol":true}`)
+	lb := o.getLineBuffer()
+	o.encodeSamples(lb, []metrics.SampleContainer{samples})
+	chunks := lb.chunks()
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "test_gauge,method=GET value=0 0\ntest_gauge,method=GET value=1 1000000000\n", chunks[0])
+	assert.Equal(t, "test_gauge,method=GET value=4 4000000000\n", chunks[2])
+
+	o.putLineBuffer(lb)
+	assert.Zero(t, lb.buf.Len())
+	assert.Empty(t, lb.ends)
+}
+
+func TestOutputStreamLineProtocol(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	logger, _ := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"streamLineProtocol":true,"maxBatchSize":2}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	samples := make(metrics.Samples, 0, 3)
+	for i := 0; i < 3; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("method", "GET")},
+			Time:       time.Now(),
+			Value:      float64(i),
+		})
+	}
+	o.AddMetricSamples([]metrics.SampleContainer{samples})
+	require.NoError(t, o.Stop())
+	assert.Len(t, lc.Lines(), 3)
+}
+
+func BenchmarkEncodeSamples(b *testing.B) {
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(b, err)
+
+	tagSets := make([]*metrics.TagSet, 0, 10)
+	for i := 0; i < 10; i++ {
+		tagSets = append(tagSets, registry.RootTagSet().WithTagsFromMap(map[string]string{
+			"vu": strconv.Itoa(i), "iter": "1", "url": "http://test.k6.io/" + strconv.Itoa(i),
+			"method": "GET", "status": "200", "proto": "HTTP/1.1", "scenario": "default",
+		}))
+	}
+	now := time.Now()
+	samples := make(metrics.Samples, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tagSets[i%len(tagSets)]},
+			Time:       now,
+			Value:      float64(i),
+		})
+	}
+	containers := []metrics.SampleContainer{samples}
+
+	b.Run("points", func(b *testing.B) {
+		o := newTestOutput(b, `{}`)
+		var buf bytes.Buffer
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			e := newLineEncoder(&buf, time.Nanosecond)
+			for _, p := range o.batchFromSamples(containers) {
+				_, _ = e.Encode(p)
+			}
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		o := newTestOutput(b, `{"streamLineProtocol":true}`)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			lb := o.getLineBuffer()
+			o.encodeSamples(lb, containers)
+			o.putLineBuffer(lb)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package influxdb
+
+import (
+	"fmt"
+	"path"
+)
+
+// metricTagDrop is a rule of the MetricTagDrops option, the tags are dropped
+// from the samples of the metrics matching the glob pattern.
+type metricTagDrop struct {
+	pattern string
+	tags    map[string]struct{}
+}
+
+// makeMetricTagDrops returns the rules of the MetricTagDrops option.
+func makeMetricTagDrops(conf Config) ([]metricTagDrop, error) {
+	rules := make([]metricTagDrop, 0, len(conf.MetricTagDrops))
+	for pattern, tags := range conf.MetricTagDrops {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("an invalid metric pattern (%s) in MetricTagDrops: %w", pattern, err)
+		}
+		rules = append(rules, metricTagDrop{pattern: pattern, tags: makeTagSet(tags)})
+	}
+	return rules, nil
+}
+
+// droppedMetricTags returns the tags dropped from the metric's samples, merging the tags
+// of all the matching patterns. It is resolved once per metric.
+func (o *Output) droppedMetricTags(metric string) map[string]struct{} {
+	if cached, ok := o.metricDroppedTags.Load(metric); ok {
+		return cached.(map[string]struct{}) //nolint:forcetypeassert
+	}
+	tags := make(map[string]struct{})
+	for _, rule := range o.tagDrops {
+		// the patterns are already validated
+		if ok, _ := path.Match(rule.pattern, metric); !ok {
+			continue
+		}
+		for tag := range rule.tags {
+			tags[tag] = struct{}{}
+		}
+	}
+	o.metricDroppedTags.Store(metric, tags)
+	return tags
+}
+
+// dropMetricTags removes the tags dropped by the MetricTagDrops option from the metric's tags.
+func (o *Output) dropMetricTags(metric string, tags map[string]string) {
+	if len(o.tagDrops) == 0 {
+		return
+	}
+	for tag := range o.droppedMetricTags(metric) {
+		delete(tags, tag)
+	}
+}
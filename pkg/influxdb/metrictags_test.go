@@ -0,0 +1,49 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+)
+
+func TestBatchFromSamplesMetricTagDrops(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	duration, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	waiting, err := registry.NewMetric("http_req_waiting", metrics.Trend)
+	require.NoError(t, err)
+	reqs, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	tags := registry.RootTagSet().With("url", "http://test/1").With("name", "test").With("method", "GET")
+	var samples metrics.Samples
+	for _, m := range []*metrics.Metric{duration, waiting, reqs} {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: m, Tags: tags},
+			Time:       time.Now(),
+			Value:      1,
+		})
+	}
+
+	// url isn't set as a field, so it isn't extracted from the tags
+	o := newTestOutput(t, `{"tagsAsFields":["vu"],"metricTagDrops":{"http_req_duration":["url"],"http_req_[dw]*":["name"]}}`)
+	points := o.batchFromSamples([]metrics.SampleContainer{samples})
+	require.Len(t, points, 3)
+	got := make(map[string][]string)
+	for _, p := range points {
+		var keys []string
+		for _, tag := range p.TagList() {
+			keys = append(keys, tag.Key)
+		}
+		got[p.Name()] = keys
+	}
+	assert.Equal(t, map[string][]string{
+		"http_req_duration": {"method"},
+		"http_req_waiting":  {"method", "url"},
+		"http_reqs":         {"method", "name", "url"},
+	}, got)
+}
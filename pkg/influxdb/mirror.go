@@ -0,0 +1,110 @@
+package influxdb
+
+import (
+	"fmt"
+	"sync"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// checkMirrorBuckets returns an error if the MirrorBuckets option is invalid
+// or it is used with an option that writes the points elsewhere.
+func checkMirrorBuckets(c Config) error {
+	if len(c.MirrorBuckets) == 0 {
+		return nil
+	}
+	if c.Flavor.String == FlavorTelegraf {
+		return fmt.Errorf("the MirrorBuckets option isn't supported by the %s flavor", FlavorTelegraf)
+	}
+	if c.AsyncWrite.Bool {
+		return fmt.Errorf("the MirrorBuckets option isn't supported with AsyncWrite")
+	}
+	if c.OrgFromTag.String != "" {
+		return fmt.Errorf("the MirrorBuckets option isn't supported with OrgFromTag")
+	}
+	seen := make(map[string]struct{}, len(c.MirrorBuckets))
+	for _, bucket := range c.MirrorBuckets {
+		if bucket == "" {
+			return fmt.Errorf("an empty bucket is specified in MirrorBuckets")
+		}
+		if bucket == c.Bucket.String {
+			return fmt.Errorf("the mirror bucket (%s) is the same of the Bucket option", bucket)
+		}
+		if _, ok := seen[bucket]; ok {
+			return fmt.Errorf("the mirror bucket (%s) is specified more times", bucket)
+		}
+		seen[bucket] = struct{}{}
+	}
+	return nil
+}
+
+// mirrorWriter writes the points to a mirror bucket.
+type mirrorWriter struct {
+	bucket string
+	writer pointsWriter
+}
+
+// newMirrorWriters returns a writer for each mirror bucket, they use the same client of the primary bucket.
+func newMirrorWriters(conf Config, cl influxdbclient.Client, opts *influxdbclient.Options) ([]mirrorWriter, error) {
+	mirrors := make([]mirrorWriter, 0, len(conf.MirrorBuckets))
+	for _, bucket := range conf.MirrorBuckets {
+		mconf := conf
+		mconf.Bucket.String = bucket
+		w, err := newPointsWriter(mconf, cl, opts)
+		if err != nil {
+			return nil, err
+		}
+		mirrors = append(mirrors, mirrorWriter{bucket: bucket, writer: w})
+	}
+	return mirrors, nil
+}
+
+func (o *Output) currentMirrors() []mirrorWriter {
+	o.clientMu.RLock()
+	defer o.clientMu.RUnlock()
+	return o.mirrors
+}
+
+// writeMirrors starts the writes to the mirror buckets, concurrently with the primary's write,
+// and it returns the function waiting for them. A failed write to a mirror is only logged
+// and counted, so it doesn't fail the primary's write nor affect its retries.
+func (o *Output) writeMirrors(points int, write func(w pointsWriter) error) func() {
+	mirrors := o.currentMirrors()
+	if len(mirrors) == 0 {
+		return func() {}
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(mirrors))
+	for _, m := range mirrors {
+		go func(m mirrorWriter) {
+			defer wg.Done()
+			err := write(m.writer)
+			o.stats.recordMirrorWrite(m.bucket, err != nil)
+			if err != nil {
+				o.logWriteError(err, logrus.Fields{"bucket": m.bucket, "points": points})
+			}
+		}(m)
+	}
+	return wg.Wait
+}
+
+// logMirrorWrites logs the writes to each bucket, when the points are mirrored.
+func (o *Output) logMirrorWrites(stats Stats) {
+	if len(stats.MirrorWrites) == 0 {
+		return
+	}
+	o.logger.WithFields(logrus.Fields{
+		"bucket": o.config.Bucket.String,
+		"writes": stats.Writes,
+		"failed": stats.FailedWrites,
+	}).Info("The writes to the primary bucket")
+	for _, bucket := range o.config.MirrorBuckets {
+		w := stats.MirrorWrites[bucket]
+		o.logger.WithFields(logrus.Fields{
+			"bucket": bucket,
+			"writes": w.Writes,
+			"failed": w.FailedWrites,
+		}).Info("The writes to the mirror bucket")
+	}
+}
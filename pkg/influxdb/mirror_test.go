@@ -0,0 +1,126 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+// bucketCollector collects the written lines by bucket, the writes to the failing bucket are rejected.
+type bucketCollector struct {
+	failing string
+
+	mu    sync.Mutex
+	lines map[string][]string
+}
+
+func (bc *bucketCollector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == bc.failing {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	bc.mu.Lock()
+	if bc.lines == nil {
+		bc.lines = make(map[string][]string)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line != "" {
+			bc.lines[bucket] = append(bc.lines[bucket], line)
+		}
+	}
+	bc.mu.Unlock()
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (bc *bucketCollector) Lines(bucket string) []string {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return append([]string(nil), bc.lines[bucket]...)
+}
+
+func TestOutputMirrorBuckets(t *testing.T) {
+	t.Parallel()
+
+	testdata := map[string]struct {
+		conf    string
+		failing string
+	}{
+		"points":                {conf: `{}`},
+		"stream line protocol":  {conf: `{"streamLineProtocol":true}`},
+		"failing mirror":        {conf: `{}`, failing: "k6-archive"},
+		"failing mirror stream": {conf: `{"streamLineProtocol":true}`, failing: "k6-archive"},
+	}
+	for name, tc := range testdata {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bc := &bucketCollector{failing: tc.failing}
+			ts := httptest.NewServer(bc)
+			defer ts.Close()
+
+			conf := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal([]byte(tc.conf), &conf))
+			conf["mirrorBuckets"] = []string{"k6-dr", "k6-archive"}
+			conf["maxBatchSize"] = 2
+			jsonConf, err := json.Marshal(conf)
+			require.NoError(t, err)
+
+			logger, _ := logtest.NewNullLogger()
+			o, err := New(output.Params{
+				Logger:         logger,
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     jsonConf,
+			})
+			require.NoError(t, err)
+			require.NoError(t, o.Start())
+
+			registry := metrics.NewRegistry()
+			metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+			require.NoError(t, err)
+			samples := make(metrics.Samples, 0, 3)
+			for i := 0; i < 3; i++ {
+				samples = append(samples, metrics.Sample{
+					TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("method", "GET")},
+					Time:       time.Unix(int64(i), 0),
+					Value:      float64(i),
+				})
+			}
+			o.AddMetricSamples([]metrics.SampleContainer{samples})
+			require.NoError(t, o.Stop())
+
+			primary := bc.Lines("testbucket")
+			assert.Len(t, primary, 3)
+			assert.Equal(t, primary, bc.Lines("k6-dr"))
+
+			stats := o.Stats()
+			assert.Equal(t, 2, stats.Writes)
+			assert.Zero(t, stats.FailedWrites)
+			assert.Equal(t, MirrorWrites{Writes: 2}, stats.MirrorWrites["k6-dr"])
+			if tc.failing == "" {
+				assert.Equal(t, primary, bc.Lines("k6-archive"))
+				assert.Equal(t, MirrorWrites{Writes: 2}, stats.MirrorWrites["k6-archive"])
+			} else {
+				assert.Empty(t, bc.Lines("k6-archive"))
+				assert.Equal(t, MirrorWrites{Writes: 2, FailedWrites: 2}, stats.MirrorWrites["k6-archive"])
+			}
+		})
+	}
+}
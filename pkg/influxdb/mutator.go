@@ -0,0 +1,42 @@
+package influxdb
+
+import (
+	"sync"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.k6.io/k6/metrics"
+)
+
+// PointMutator is called for each point created from the samples, before it is written.
+// It can change the point, e.g. adding a computed field or redacting a tag's value.
+// It is called by the concurrent writes, so it must be safe for concurrent use.
+//
+// In the single measurement mode, the point contains the values of more metrics
+// and it is called with the sample that created the point.
+type PointMutator func(*write.Point, metrics.Sample)
+
+var ( //nolint:gochecknoglobals // the mutator is set by the extensions before the outputs are created
+	defaultPointMutatorMu sync.RWMutex
+	defaultPointMutator   PointMutator
+)
+
+// SetPointMutator sets the PointMutator used by the outputs created after the call.
+// k6 creates the output through the registered extension, so it is the way
+// for setting a mutator from the init function of a custom k6 build's package:
+//
+//	func init() {
+//		influxdb.SetPointMutator(func(p *write.Point, s metrics.Sample) {
+//			p.AddField("value_ms", s.Value*1000)
+//		})
+//	}
+func SetPointMutator(m PointMutator) {
+	defaultPointMutatorMu.Lock()
+	defer defaultPointMutatorMu.Unlock()
+	defaultPointMutator = m
+}
+
+func getPointMutator() PointMutator {
+	defaultPointMutatorMu.RLock()
+	defer defaultPointMutatorMu.RUnlock()
+	return defaultPointMutator
+}
@@ -0,0 +1,71 @@
+package influxdb
+
+import (
+	"fmt"
+	"math"
+)
+
+// The handling of the non-finite float values, NaN and ±Inf, rejected by InfluxDB.
+const (
+	// NonFiniteDrop drops the field, the sample is skipped if it hasn't any other field.
+	NonFiniteDrop = "drop"
+	// NonFiniteZero writes zero instead of the value.
+	NonFiniteZero = "zero"
+	// NonFiniteSkipSample skips the whole sample.
+	NonFiniteSkipSample = "skip-sample"
+)
+
+// checkNonFiniteMode returns an error if the NonFiniteMode option is unknown.
+func checkNonFiniteMode(mode string) error {
+	switch mode {
+	case NonFiniteDrop, NonFiniteZero, NonFiniteSkipSample:
+		return nil
+	default:
+		return fmt.Errorf("an invalid non-finite mode (%s) is specified, the allowed values are: %s, %s and %s",
+			mode, NonFiniteDrop, NonFiniteZero, NonFiniteSkipSample)
+	}
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// finiteFields handles the non-finite float fields as set by NonFiniteMode, so they don't fail
+// the whole batch's write. It returns false if the sample has to be skipped.
+func (o *Output) finiteFields(metric string, fields map[string]interface{}) bool {
+	for k, v := range fields {
+		f, ok := v.(float64)
+		if !ok || isFinite(f) {
+			continue
+		}
+		if o.config.NonFiniteMode.String == NonFiniteSkipSample {
+			o.logNonFinite(metric, k, f)
+			return false
+		}
+		if f, ok := o.finiteValue(metric, k, f); ok {
+			fields[k] = f
+		} else {
+			delete(fields, k)
+		}
+	}
+	return true
+}
+
+// finiteValue returns the value of the non-finite float field as set by NonFiniteMode,
+// it returns false if the field has to be dropped.
+func (o *Output) finiteValue(metric, field string, v float64) (float64, bool) {
+	o.logNonFinite(metric, field, v)
+	if o.config.NonFiniteMode.String == NonFiniteZero {
+		return 0, true
+	}
+	return 0, false
+}
+
+// logNonFinite logs the first non-finite value of each metric's field.
+func (o *Output) logNonFinite(metric, field string, v float64) {
+	if _, logged := o.nonFiniteFields.LoadOrStore(metric+"."+field, struct{}{}); logged {
+		return
+	}
+	o.logger.WithField("metric", metric).WithField("field", field).WithField("value", v).
+		WithField("mode", o.config.NonFiniteMode.String).Debug("A non-finite value, rejected by InfluxDB, has been handled")
+}
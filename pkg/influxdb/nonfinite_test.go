@@ -0,0 +1,132 @@
+package influxdb
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+)
+
+func TestBatchFromSamplesNonFiniteMode(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("iteration_rate", metrics.Trend)
+	require.NoError(t, err)
+	now := time.Now()
+	newSample := func(v float64, tags map[string]string) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().WithTagsFromMap(tags)},
+			Time:       now,
+			Value:      v,
+		}
+	}
+	samples := metrics.Samples{
+		newSample(1, map[string]string{"method": "GET"}),
+		newSample(math.NaN(), map[string]string{"method": "GET"}),
+		newSample(math.Inf(1), map[string]string{"method": "GET"}),
+		// the finite value is kept, the field parsed from the tag isn't finite
+		newSample(2, map[string]string{"method": "GET", "ratio": "-Inf"}),
+		newSample(math.Inf(-1), map[string]string{"method": "GET", "ratio": "0.5"}),
+	}
+	fields := func(points []*write.Point) []map[string]interface{} {
+		all := make([]map[string]interface{}, 0, len(points))
+		for _, p := range points {
+			got := map[string]interface{}{}
+			for _, f := range p.FieldList() {
+				got[f.Key] = f.Value
+			}
+			all = append(all, got)
+		}
+		return all
+	}
+
+	testdata := map[string]struct {
+		conf     string
+		expected []map[string]interface{}
+	}{
+		"default": {
+			conf: `{"tagsAsFields":["ratio:float"]}`,
+			expected: []map[string]interface{}{
+				{"value": 1.0},
+				{"value": 2.0},
+				{"ratio": 0.5},
+			},
+		},
+		"drop": {
+			conf: `{"tagsAsFields":["ratio:float"],"nonFiniteMode":"drop"}`,
+			expected: []map[string]interface{}{
+				{"value": 1.0},
+				{"value": 2.0},
+				{"ratio": 0.5},
+			},
+		},
+		"zero": {
+			conf: `{"tagsAsFields":["ratio:float"],"nonFiniteMode":"zero"}`,
+			expected: []map[string]interface{}{
+				{"value": 1.0},
+				{"value": 0.0},
+				{"value": 0.0},
+				{"value": 2.0, "ratio": 0.0},
+				{"value": 0.0, "ratio": 0.5},
+			},
+		},
+		"skip sample": {
+			conf: `{"tagsAsFields":["ratio:float"],"nonFiniteMode":"skip-sample"}`,
+			expected: []map[string]interface{}{
+				{"value": 1.0},
+			},
+		},
+	}
+	for name, tc := range testdata {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestOutput(t, tc.conf)
+			points := o.batchFromSamples([]metrics.SampleContainer{samples})
+			assert.Equal(t, tc.expected, fields(points))
+		})
+	}
+}
+
+func TestBatchFromSamplesSingleMeasurementNonFiniteMode(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	reqs, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	rate, err := registry.NewMetric("iteration_rate", metrics.Trend)
+	require.NoError(t, err)
+	now := time.Now()
+	tags := registry.RootTagSet().With("method", "GET")
+	samples := metrics.Samples{
+		{TimeSeries: metrics.TimeSeries{Metric: reqs, Tags: tags}, Time: now, Value: 1},
+		{TimeSeries: metrics.TimeSeries{Metric: rate, Tags: tags}, Time: now, Value: math.NaN()},
+	}
+
+	testdata := map[string]map[string]interface{}{
+		NonFiniteDrop:       {"http_reqs": 1.0},
+		NonFiniteZero:       {"http_reqs": 1.0, "iteration_rate": 0.0},
+		NonFiniteSkipSample: {"http_reqs": 1.0},
+	}
+	for mode, expected := range testdata {
+		mode, expected := mode, expected
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestOutput(t, `{"singleMeasurement":true,"nonFiniteMode":"`+mode+`"}`)
+			points := o.batchFromSamples([]metrics.SampleContainer{samples})
+			require.Len(t, points, 1)
+			got := map[string]interface{}{}
+			for _, f := range points[0].FieldList() {
+				got[f.Key] = f.Value
+			}
+			assert.Equal(t, expected, got)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.k6.io/k6/metrics"
+)
+
+// cloudHostSuffix is the domain of the InfluxDB Cloud's regions.
+const cloudHostSuffix = ".cloud2.influxdata.com"
+
+// orgInferenceTimeout is the max duration of the organizations' lookup done by New.
+const orgInferenceTimeout = 5 * time.Second
+
+// checkOrganization returns an error if the Organization isn't set for InfluxDB Cloud,
+// that requires it. InfluxDB OSS can infer it from the token.
+func checkOrganization(c Config) error {
+	if c.Flavor.String != FlavorV2 || c.Organization.String != "" {
+		return nil
+	}
+	// an invalid address is already reported by checkDestination
+	u, err := url.Parse(c.Addr.String)
+	if err == nil && strings.HasSuffix(strings.ToLower(u.Hostname()), cloudHostSuffix) {
+		return fmt.Errorf("the Organization option is required by InfluxDB Cloud")
+	}
+	return nil
+}
+
+// shouldInferOrganization reports whether the Organization has to be inferred from the token.
+// A token in the username:password form is for the v1.8+ compatibility API, that has no organizations.
+func shouldInferOrganization(c Config) bool {
+	return c.Flavor.String == FlavorV2 && c.Organization.String == "" && c.InferOrganization.Bool &&
+		c.Token.String != "" && !strings.Contains(c.Token.String, ":")
+}
+
+// inferOrganization returns the name of the only organization the token has access to.
+// The lookup is bounded by orgInferenceTimeout, so an unreachable server doesn't delay the start
+// by the whole HTTP timeout.
+func inferOrganization(ctx context.Context, cl influxdbclient.Client) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, orgInferenceTimeout)
+	defer cancel()
+	orgs, err := cl.OrganizationsAPI().GetOrganizations(ctx)
+	if err != nil {
+		return "", fmt.Errorf("couldn't list the organizations: %w", err)
+	}
+	if orgs == nil || len(*orgs) != 1 {
+		n := 0
+		if orgs != nil {
+			n = len(*orgs)
+		}
+		return "", fmt.Errorf("the token has access to %d organizations, only one can be inferred", n)
+	}
+	return (*orgs)[0].Name, nil
+}
+
+// orgSamples are the samples written to the same organization,
+// an empty org means the configured Organization.
+type orgSamples struct {
+	org     string
+	samples []metrics.SampleContainer
+}
+
+// orgBatch are the points written to the same organization.
+type orgBatch struct {
+	org    string
+	points []*write.Point
+}
+
+// groupSamplesByOrg groups the samples by the value of the OrgFromTag's tag, in order
+// of appearance. The samples without the tag, or with an empty value, are written
+// to the configured Organization. All the samples are a single group if it isn't set.
+func (o *Output) groupSamplesByOrg(containers []metrics.SampleContainer) []orgSamples {
+	tag := o.config.OrgFromTag.String
+	if tag == "" {
+		return []orgSamples{{samples: containers}}
+	}
+	var groups []orgSamples
+	index := make(map[string]int)
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			org, _ := sample.Tags.Get(tag)
+			i, ok := index[org]
+			if !ok {
+				i = len(groups)
+				index[org] = i
+				groups = append(groups, orgSamples{org: org, samples: []metrics.SampleContainer{metrics.Samples{}}})
+			}
+			samples := groups[i].samples[0].(metrics.Samples) //nolint:forcetypeassert
+			groups[i].samples[0] = append(samples, sample)
+		}
+	}
+	return groups
+}
+
+// orgWriter returns the synchronous writer of the organization. The writers of the organizations
+// routed by OrgFromTag are created on their first write, and they are dropped when the client is rebuilt.
+func (o *Output) orgWriter(org string) pointsWriter {
+	if org == "" || org == o.config.Organization.String {
+		return o.currentWriter()
+	}
+	o.clientMu.RLock()
+	w, ok := o.orgWriters[org]
+	o.clientMu.RUnlock()
+	if ok {
+		return w
+	}
+
+	o.clientMu.Lock()
+	defer o.clientMu.Unlock()
+	if w, ok := o.orgWriters[org]; ok {
+		return w
+	}
+	if o.orgWriters == nil {
+		o.orgWriters = make(map[string]pointsWriter)
+	}
+	w = o.client.WriteAPIBlocking(org, o.config.Bucket.String)
+	o.orgWriters[org] = w
+	return w
+}
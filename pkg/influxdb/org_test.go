@@ -0,0 +1,254 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputOrgFromTag(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	orgLines := make(map[string][]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		org := r.URL.Query().Get("org")
+		mu.Lock()
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			orgLines[org] = append(orgLines[org], strings.Split(line, " ")[0])
+		}
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"organization":"default-org","orgFromTag":"tenant","pushInterval":"1h"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	sample := func(tags map[string]string) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().WithTagsFromMap(tags)},
+			Time:       time.Now(),
+			Value:      1,
+		}
+	}
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Samples{
+		sample(map[string]string{"tenant": "acme", "status": "200"}),
+		sample(map[string]string{"tenant": "globex", "status": "200"}),
+		sample(map[string]string{"tenant": "acme", "status": "500"}),
+		sample(map[string]string{"status": "404"}),
+	}})
+	require.NoError(t, o.Flush())
+	require.NoError(t, o.Stop())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string][]string{
+		"acme":        {"http_reqs,status=200,tenant=acme", "http_reqs,status=500,tenant=acme"},
+		"globex":      {"http_reqs,status=200,tenant=globex"},
+		"default-org": {"http_reqs,status=404"},
+	}, orgLines)
+}
+
+// interleavedTenantSamples returns the samples of the tenants,
+// so a consecutive sample is always of a different tenant.
+func interleavedTenantSamples(tb testing.TB, n int, tenants ...string) metrics.Samples {
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(tb, err)
+	samples := make(metrics.Samples, 0, n)
+	for i := 0; i < n; i++ {
+		tags := map[string]string{"vu": strconv.Itoa(i)}
+		if tenant := tenants[i%len(tenants)]; tenant != "" {
+			tags["tenant"] = tenant
+		}
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().WithTagsFromMap(tags)},
+			Time:       time.Now(),
+			Value:      1,
+		})
+	}
+	return samples
+}
+
+func TestOutputOrgFromTagWriteCalls(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	orgRequests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		orgRequests[r.URL.Query().Get("org")]++
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"organization":"default-org","orgFromTag":"tenant","pushInterval":"1h"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	o.AddMetricSamples([]metrics.SampleContainer{interleavedTenantSamples(t, 40, "acme", "globex", "initech", "")})
+	require.NoError(t, o.Flush())
+	require.NoError(t, o.Stop())
+
+	// the batch is grouped once, so each organization has a single write
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"acme": 1, "globex": 1, "initech": 1, "default-org": 1}, orgRequests)
+}
+
+func BenchmarkOrgFromTagRouting(b *testing.B) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		requests.Add(1)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(b),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"organization":"default-org","orgFromTag":"tenant"}`),
+	})
+	require.NoError(b, err)
+	samples := interleavedTenantSamples(b, 300, "acme", "globex", "initech")
+	ctx := context.Background()
+
+	b.Run("Grouped", func(b *testing.B) {
+		requests.Store(0)
+		for i := 0; i < b.N; i++ {
+			for _, batch := range o.orgBatches([]metrics.SampleContainer{samples}) {
+				require.NoError(b, o.orgWriter(batch.org).WritePoint(ctx, batch.points...))
+			}
+		}
+		b.ReportMetric(float64(requests.Load())/float64(b.N), "writes/op")
+	})
+	// the naive routing, writing each sample's point to its organization
+	b.Run("PerPoint", func(b *testing.B) {
+		requests.Store(0)
+		for i := 0; i < b.N; i++ {
+			for _, sample := range samples {
+				org, _ := sample.Tags.Get("tenant")
+				points := o.batchFromSamples([]metrics.SampleContainer{sample})
+				require.NoError(b, o.orgWriter(org).WritePoint(ctx, points...))
+			}
+		}
+		b.ReportMetric(float64(requests.Load())/float64(b.N), "writes/op")
+	})
+}
+
+func TestOutputInferOrganization(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		conf     string
+		orgs     string
+		expected string
+		lookups  int64
+	}{
+		"Single": {
+			conf:     `{"token":"my-token"}`,
+			orgs:     `{"orgs":[{"id":"0000000000000001","name":"my-org"}]}`,
+			expected: "my-org",
+			lookups:  1,
+		},
+		"Multiple": {
+			conf:    `{"token":"my-token"}`,
+			orgs:    `{"orgs":[{"id":"0000000000000001","name":"org-a"},{"id":"0000000000000002","name":"org-b"}]}`,
+			lookups: 1,
+		},
+		"Set": {
+			conf:     `{"token":"my-token","organization":"explicit-org"}`,
+			expected: "explicit-org",
+		},
+		"WithoutToken": {
+			conf: `{}`,
+		},
+		"Disabled": {
+			conf: `{"token":"my-token","inferOrganization":false}`,
+		},
+		"V1Token": {
+			conf: `{"token":"my-user:my-password"}`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var lookups atomic.Int64
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v2/orgs" {
+					rw.WriteHeader(http.StatusNotFound)
+					return
+				}
+				lookups.Add(1)
+				assert.Equal(t, "Token my-token", r.Header.Get("Authorization"))
+				rw.Header().Set("Content-Type", "application/json")
+				_, _ = rw.Write([]byte(tc.orgs))
+			}))
+			defer ts.Close()
+
+			o, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     json.RawMessage(tc.conf),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, o.config.Organization.String)
+			assert.Equal(t, tc.lookups, lookups.Load())
+		})
+	}
+}
+
+func TestNewOrganizationRequiredByCloud(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "https://eu-central-1-1.aws.cloud2.influxdata.com/testbucket",
+		JSONConfig:     json.RawMessage(`{"token":"my-token"}`),
+	})
+	require.ErrorContains(t, err, "the Organization option is required by InfluxDB Cloud")
+
+	_, err = New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "https://eu-central-1-1.aws.cloud2.influxdata.com/testbucket",
+		JSONConfig:     json.RawMessage(`{"token":"my-token","organization":"my-org"}`),
+	})
+	require.NoError(t, err)
+}
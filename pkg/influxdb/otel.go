@@ -0,0 +1,58 @@
+package influxdb
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// otelResourceAttrsEnv is the OpenTelemetry's environment variable with the resource's attributes.
+const otelResourceAttrsEnv = "OTEL_RESOURCE_ATTRIBUTES"
+
+// parseOTelResourceAttrs parses the OpenTelemetry's resource attributes
+// in the key1=value1,key2=value2 format, the values are percent-encoded.
+func parseOTelResourceAttrs(s string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return attrs, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("the attribute %q isn't in the key=value format", strings.TrimSpace(pair))
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("the value of the attribute %q can't be decoded: %w", k, err)
+		}
+		attrs[k] = value
+	}
+	return attrs, nil
+}
+
+// mergeGlobalTags returns a new map with the imported tags and the explicit ones,
+// an explicit tag overrides the imported tag with the same key.
+func mergeGlobalTags(imported, explicit map[string]string) map[string]string {
+	tags := make(map[string]string, len(imported)+len(explicit))
+	for k, v := range imported {
+		tags[k] = v
+	}
+	for k, v := range explicit {
+		tags[k] = v
+	}
+	return tags
+}
+
+// addGlobalTags adds the global tags missing in the tags, a tag already set is never overridden,
+// as the client's default tags. These aren't used since the client merges them on each point's encoding,
+// while the global tags are merged once for each tag set, and the other flavors' writers, the line protocol
+// written as records and the files don't apply them. The global tags are also processed as the sample's tags,
+// e.g. they can be extracted as fields, excluded, prefixed, sanitized and truncated.
+func (o *Output) addGlobalTags(tags map[string]string) {
+	for k, v := range o.config.GlobalTags {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestParseOTelResourceAttrs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in     string
+		exp    map[string]string
+		expErr string
+	}{
+		"Empty":  {in: "", exp: map[string]string{}},
+		"Single": {in: "service.name=k6", exp: map[string]string{"service.name": "k6"}},
+		"Multiple": {
+			in:  "service.name=k6, deployment.environment = staging ,team=perf",
+			exp: map[string]string{"service.name": "k6", "deployment.environment": "staging", "team": "perf"},
+		},
+		"URLEncoded": {
+			in:  "service.name=k6%20load%20test,owner=a%2Cb%3Dc,path=%2Fapi+v1",
+			exp: map[string]string{"service.name": "k6 load test", "owner": "a,b=c", "path": "/api+v1"},
+		},
+		"EmptyValue":   {in: "team=", exp: map[string]string{"team": ""}},
+		"MissingValue": {in: "service.name=k6,team", expErr: `"team" isn't in the key=value format`},
+		"EmptyKey":     {in: "=k6", expErr: `"=k6" isn't in the key=value format`},
+		"InvalidValue": {in: "team=%zz", expErr: `the value of the attribute "team" can't be decoded`},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			attrs, err := parseOTelResourceAttrs(tc.in)
+			if tc.expErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, attrs)
+		})
+	}
+}
+
+func TestGetConsolidatedConfigOTelResourceAttrs(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"OTEL_RESOURCE_ATTRIBUTES":               "service.name=k6,team=perf",
+		"K6_INFLUXDB_GLOBAL_TAGS":                "team:sre",
+		"K6_INFLUXDB_IMPORT_OTEL_RESOURCE_ATTRS": "true",
+	}
+	conf, err := GetConsolidatedConfig(nil, env, "")
+	require.NoError(t, err)
+	// the explicit global tag wins
+	assert.Equal(t, map[string]string{"service.name": "k6", "team": "sre"}, conf.GlobalTags)
+
+	delete(env, "K6_INFLUXDB_IMPORT_OTEL_RESOURCE_ATTRS")
+	conf, err = GetConsolidatedConfig(nil, env, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "sre"}, conf.GlobalTags)
+
+	_, err = GetConsolidatedConfig([]byte(`{"importOTelResourceAttrs":true}`),
+		map[string]string{"OTEL_RESOURCE_ATTRIBUTES": "team"}, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "OTEL_RESOURCE_ATTRIBUTES can't be parsed")
+}
+
+func TestBatchFromSamplesGlobalTags(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+
+	o := newTestOutput(t, `{"globalTags":{"service.name":"k6","status":"0"}}`)
+	points := o.batchFromSamples([]metrics.SampleContainer{metrics.Samples{{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("status", "200")},
+		Time:       time.Now(),
+		Value:      1,
+	}}})
+	require.Len(t, points, 1)
+	tags := map[string]string{}
+	for _, tag := range points[0].TagList() {
+		tags[tag.Key] = tag.Value
+	}
+	// the sample's tag wins
+	assert.Equal(t, map[string]string{"service.name": "k6", "status": "200"}, tags)
+}
+
+// TestGlobalTagsClientDefaultTags checks the lines written with the global tags
+// are the same written by a client with the same default tags.
+func TestGlobalTagsClientDefaultTags(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	root := registry.RootTagSet()
+	sample := func(tags *metrics.TagSet, sec int64) metrics.Sample {
+		return metrics.Sample{TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tags}, Time: time.Unix(sec, 0), Value: 1}
+	}
+	samples := []metrics.SampleContainer{metrics.Samples{
+		sample(root.With("status", "200"), 1),
+		sample(root, 2),
+		sample(root.With("env", "prod"), 3),
+	}}
+	globalTags := map[string]string{"env": "staging", "status": "0", "team": "perf"}
+
+	merged := &lineCollector{}
+	ts := httptest.NewServer(merged)
+	defer ts.Close()
+	jsonConf, err := json.Marshal(map[string]interface{}{"globalTags": globalTags})
+	require.NoError(t, err)
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     jsonConf,
+	})
+	require.NoError(t, err)
+	o.ctx = context.Background()
+	require.NoError(t, o.writeSamples(samples))
+
+	defaulted := &lineCollector{}
+	dts := httptest.NewServer(defaulted)
+	defer dts.Close()
+	opts := influxdbclient.DefaultOptions()
+	for k, v := range globalTags {
+		opts.AddDefaultTag(k, v)
+	}
+	cl := influxdbclient.NewClientWithOptions(dts.URL, "", opts)
+	defer cl.Close()
+	points := newTestOutput(t, `{}`).batchFromSamples(samples)
+	require.NoError(t, cl.WriteAPIBlocking("", "testbucket").WritePoint(context.Background(), points...))
+
+	// in both the cases the sample's tag wins
+	assert.Equal(t, []string{
+		"http_reqs,env=staging,status=200,team=perf value=1 1000000000",
+		"http_reqs,env=staging,status=0,team=perf value=1 2000000000",
+		"http_reqs,env=prod,status=0,team=perf value=1 3000000000",
+	}, merged.Lines())
+	assert.Equal(t, merged.Lines(), defaulted.Lines())
+}
@@ -2,14 +2,22 @@
 package influxdb
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
@@ -17,13 +25,19 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/metrics"
 	"go.k6.io/k6/output"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
+	"gopkg.in/guregu/null.v3"
 )
 
 func init() {
-	// disable the internal influxdb log
-	influxdblog.Log = nil
+	// the internal influxdb log is discarded, unless it is enabled by EnableClientLog
+	influxdblog.Log = clientLog
 }
 
+// scenarioTag is the k6's tag with the name of the scenario.
+const scenarioTag = "scenario"
+
 // FieldKind defines Enum for tag-to-field type conversion
 type FieldKind int
 
@@ -38,7 +52,12 @@ const (
 	Bool
 )
 
-var _ output.Output = new(Output)
+var (
+	_ output.Output                = new(Output)
+	_ output.WithStopWithTestError = new(Output)
+	_ output.WithThresholds        = new(Output)
+	_ output.WithTestRunStop       = new(Output)
+)
 
 // Output is the influxdb Output struct
 type Output struct {
@@ -47,82 +66,641 @@ type Output struct {
 	client influxdbclient.Client
 	config Config
 
+	// PointMutator, if set, is called for each point before it is written.
+	// It defaults to the mutator set by SetPointMutator and it can be changed only before Start.
+	PointMutator PointMutator
+	// OnWriteError, if set, is called when a write fails.
+	// It defaults to the callback set by SetOnWriteError and it can be changed only before Start.
+	OnWriteError WriteErrorCallback
+
 	params          output.Params
-	periodicFlusher *output.PeriodicFlusher
 	logger          logrus.FieldLogger
+	clock           clock
 	fieldKinds      map[string]FieldKind
-	pointWriter     api.WriteAPIBlocking
-	semaphoreCh     chan struct{}
-	wg              sync.WaitGroup
+	fieldMetrics    map[string]map[string]struct{}
+	fieldKeys       map[string]string
+	keepTags        map[string]struct{}
+	dropTags        map[string]struct{}
+	includedTypes   map[metrics.MetricType]struct{}
+	excludedTypes   map[metrics.MetricType]struct{}
+	valueExcluded   map[string]struct{}
+	timeWindow      timeWindow
+	tagDrops        []metricTagDrop
+	valueFields     map[metrics.MetricType]string
+	pointWriter     pointsWriter
+	asyncWriter     api.WriteAPI
+	asyncErrorsDone chan struct{}
+	writeSlots      *writeSlots
+	// concurrencyTuner is set when AutoConcurrency is enabled
+	concurrencyTuner *concurrencyTuner
+	inFlight         *semaphore.Weighted
+	wg               sync.WaitGroup
+	stats            statsCollector
+	writeErrors      *errorAggregator
+	limiter          *rate.Limiter
+	backoff          *writeBackoff
+	keySanitizer     *keySanitizer
+	numericTags      *numericTagKinds
+	deadLetter       *deadLetterFile
+	wal              *writeAheadLog
+
+	// clientLogger is the logger of the client's logs, set by EnableClientLog,
+	// they are detached from it when the output is stopped.
+	clientLogger *logrus.Entry
+
+	// throughput measures the written points' rate if LogThroughput is enabled.
+	throughput *throughputMeter
+
+	// summary aggregates the samples in the SummaryOnly mode, it is written by Stop.
+	summary summaryCollector
+
+	// thresholds are set by k6 before Start, they are written by Stop if EmitThresholds is enabled.
+	thresholds map[string]metrics.Thresholds
+
+	// testRunStop is set by k6 before Start, it aborts the test when the write error budget is exceeded.
+	testRunStop func(error)
+
+	// fieldParseErrors are the tags whose parse error has already been logged.
+	fieldParseErrors sync.Map
+	// emptyTags are the tags whose dropped empty value has already been logged.
+	emptyTags sync.Map
+	// nonFiniteFields are the metrics' fields whose non-finite value has already been logged.
+	nonFiniteFields sync.Map
+	// metricDroppedTags are the tags dropped by MetricTagDrops, resolved for each metric.
+	metricDroppedTags sync.Map
+	// lineBuffers are the reusable buffers of the StreamLineProtocol mode.
+	lineBuffers sync.Pool
+
+	// fieldsOverflowWarned is set when the first point exceeding MaxFieldsPerPoint is logged.
+	fieldsOverflowWarned atomic.Bool
+
+	// idleFlushes is the number of the consecutive flushes without any buffered sample,
+	// the idle period is logged once when it starts and once when it ends.
+	idleFlushes atomic.Int64
+
+	// bufferedSamples is the number of the buffered samples, it is tracked
+	// only when the FlushThreshold option is set for triggering the early flushes.
+	bufferedSamples atomic.Int64
+	earlyFlushCh    chan struct{}
+	earlyFlushStop  chan struct{}
+	earlyFlushDone  chan struct{}
+
+	// flusherMu guards the periodic flusher, that is replaced when the push interval
+	// is changed, pushInterval is the current interval in nanoseconds.
+	flusherMu       sync.Mutex
+	periodicFlusher interface{ Stop() }
+	stopped         bool
+	pushInterval    atomic.Int64
+
+	// clientMu guards the client and the points' writers, that are rebuilt after
+	// ReconnectAfterFailures consecutive failed flushes, at most once per ReconnectCooldown.
+	clientMu      sync.RWMutex
+	orgWriters    map[string]pointsWriter
+	mirrors       []mirrorWriter
+	failedFlushes atomic.Int64
+	lastReconnect time.Time
+
+	// ctx is used by all the write requests,
+	// it is cancelled when the output is stopped.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New returns new InfluxDB Output
 func New(params output.Params) (*Output, error) {
+	return NewWithContext(context.Background(), params)
+}
+
+// NewWithContext returns new InfluxDB Output,
+// the context bounds the network calls done during the construction.
+func NewWithContext(ctx context.Context, params output.Params) (_ *Output, err error) {
 	logger := params.Logger.WithFields(logrus.Fields{"output": "InfluxDBv2"})
 
 	conf, err := GetConsolidatedConfig(params.JSONConfig, params.Environment, params.ConfigArgument)
 	if err != nil {
 		return nil, err
 	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	conf = prepareConfig(conf, logger)
+	var clientLogger *logrus.Entry
+	if conf.EnableClientLog.Bool {
+		clientLogger = clientLog.attach(logger)
+		defer func() {
+			if err != nil {
+				clientLog.detach(clientLogger)
+			}
+		}()
+	}
+	opts, err := newClientOptions(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.AsyncWrite.Bool {
+		// the async writer's buffer is flushed with the same cadence of the output's buffer
+		opts.SetFlushInterval(uint(time.Duration(conf.PushInterval.Duration).Milliseconds()))
+		if conf.MaxBatchSize.Int64 > 0 {
+			opts.SetBatchSize(uint(conf.MaxBatchSize.Int64))
+		}
+	}
+	cl := influxdbclient.NewClientWithOptions(serverURL(conf), conf.Token.String, opts)
+	if conf.StartupHealthCheck.Bool {
+		if err := checkHealth(ctx, cl); err != nil {
+			cl.Close()
+			return nil, err
+		}
+	}
+	if shouldInferOrganization(conf) {
+		org, err := inferOrganization(ctx, cl)
+		if err != nil {
+			// the single-org OSS setups can accept the writes without the org
+			logger.WithError(err).Warn("The Organization isn't set and it can't be inferred, the writes could be rejected")
+		} else {
+			logger.WithField("organization", org).Debug("The Organization has been inferred from the token")
+			conf.Organization = null.StringFrom(org)
+		}
+	}
+	o := &Output{
+		PointMutator:  getPointMutator(),
+		OnWriteError:  getWriteErrorCallback(),
+		params:        params,
+		logger:        logger,
+		clock:         realClock{},
+		client:        cl,
+		config:        conf,
+		keepTags:      makeTagSet(conf.KeepTags),
+		dropTags:      makeTagSet(conf.DropTags),
+		valueExcluded: makeTagSet(conf.ValueFilterExcludedMetrics),
+		writeErrors:   newErrorAggregator(time.Duration(conf.ErrorLogWindow.Duration)),
+		backoff:       &writeBackoff{clock: realClock{}, max: time.Duration(conf.MaxRetryAfter.Duration)},
+		clientLogger:  clientLogger,
+		wg:            sync.WaitGroup{},
+	}
+	if err := o.setupPoints(); err != nil {
+		return nil, err
+	}
+	if err := o.setupWriters(opts); err != nil {
+		return nil, err
+	}
+	o.setupLimits()
+	o.pushInterval.Store(int64(conf.PushInterval.Duration))
+	return o, nil
+}
+
+// prepareConfig warns about the risky combinations of the validated config's options
+// and it generates the run's ID, if it is required.
+func prepareConfig(conf Config, logger logrus.FieldLogger) Config {
+	if conf.AddMetricType.Bool && conf.SingleMeasurement.Bool {
+		logger.Warn("The metric's type tag splits the combined points of SingleMeasurement by type, " +
+			"increasing the number of the points and the series")
+	}
+	// generate it once, so all the points of the same run share it
+	if conf.AddRunID.Bool && conf.RunID.String == "" {
+		conf.RunID = null.StringFrom(uuid.New().String())
+	}
+	if conf.InsecureSkipTLSVerify.Bool && strings.HasPrefix(strings.ToLower(conf.Addr.String), "https://") {
+		logger.Warn("The TLS certificate of InfluxDB isn't verified (InsecureSkipTLSVerify), " +
+			"the connection is exposed to man-in-the-middle attacks")
+	}
+	// it helps to debug the precedence of the JSON, environment and URL options
+	logger.WithField("config", conf.String()).Debug("Resolved config")
+	return conf
+}
+
+// setupPoints parses the config's options used for building the metrics' points.
+func (o *Output) setupPoints() error {
+	conf := o.config
+	var err error
+	if o.fieldKinds, err = makeFieldKinds(conf); err != nil {
+		return err
+	}
+	if o.fieldMetrics, err = makeFieldMetrics(conf); err != nil {
+		return err
+	}
+	if o.fieldKeys, err = makeFieldKeys(conf); err != nil {
+		return err
+	}
+	if o.includedTypes, err = makeMetricTypeSet(conf.IncludedMetricTypes); err != nil {
+		return err
+	}
+	if o.excludedTypes, err = makeMetricTypeSet(conf.ExcludedMetricTypes); err != nil {
+		return err
+	}
+	if o.timeWindow, err = conf.timeWindow(); err != nil {
+		return err
+	}
+	if o.tagDrops, err = makeMetricTagDrops(conf); err != nil {
+		return err
+	}
+	if o.valueFields, err = makeValueFields(conf); err != nil {
+		return err
+	}
+	if conf.SanitizeKeys.Bool {
+		o.keySanitizer = newKeySanitizer(conf.SanitizeReplacement.String, o.logger)
+	}
+	if conf.AutoFieldNumericTags.Bool {
+		o.numericTags = &numericTagKinds{}
+	}
+	return nil
+}
+
+// setupWriters creates the points' writers of the flavor, of the mirror buckets
+// and of the failed batches' files, the files are opened by Start.
+func (o *Output) setupWriters(opts *influxdbclient.Options) error {
+	conf := o.config
+	var err error
+	if o.pointWriter, err = newPointsWriter(conf, o.client, opts); err != nil {
+		return err
+	}
+	if o.mirrors, err = newMirrorWriters(conf, o.client, opts); err != nil {
+		return err
+	}
+	if conf.DeadLetterFile.String != "" {
+		o.deadLetter = &deadLetterFile{
+			path:      conf.DeadLetterFile.String,
+			maxSize:   conf.DeadLetterMaxSize.Int64,
+			precision: opts.Precision(),
+		}
+	}
+	if conf.WALDir.String != "" {
+		o.wal = &writeAheadLog{dir: conf.WALDir.String, precision: opts.Precision()}
+	}
+	return nil
+}
+
+// setupLimits creates the limiters of the concurrent writes, of the in-flight points
+// and of the written points' rate.
+func (o *Output) setupLimits() {
+	conf := o.config
+	o.writeSlots = newWriteSlots(int(conf.ConcurrentWrites.Int64))
+	if conf.AutoConcurrency.Bool {
+		o.concurrencyTuner = &concurrencyTuner{
+			slots:   o.writeSlots,
+			minSize: int(conf.MinConcurrentWrites.Int64),
+			maxSize: int(conf.MaxConcurrentWrites.Int64),
+		}
+	}
+	if conf.MaxInFlightPoints.Int64 > 0 {
+		o.inFlight = semaphore.NewWeighted(conf.MaxInFlightPoints.Int64)
+	}
+	if maxPPS := conf.MaxPointsPerSecond.Int64; maxPPS > 0 {
+		o.limiter = rate.NewLimiter(rate.Limit(maxPPS), int(maxPPS))
+	}
+	if conf.LogThroughput.Bool {
+		o.throughput = &throughputMeter{interval: time.Duration(conf.LogThroughputInterval.Duration)}
+	}
+}
+
+// checkDestination returns an error if the address or the bucket
+// aren't valid for the flavor.
+func checkDestination(conf Config) error {
+	if conf.Flavor.String == FlavorTelegraf {
+		return checkSocketAddr(conf.Addr.String)
+	}
+	if err := checkAddr(conf.Addr.String); err != nil {
+		return err
+	}
 	if conf.Bucket.String == "" {
-		return nil, fmt.Errorf("the Bucket option is required")
+		return fmt.Errorf("the Bucket option is required")
+	}
+	return nil
+}
+
+// checkFlavor returns an error if the flavor is unknown
+// or it is used with options that it doesn't support.
+func checkFlavor(conf Config) error {
+	switch conf.Flavor.String {
+	case FlavorV2:
+		return nil
+	case FlavorV3:
+		if conf.AsyncWrite.Bool {
+			return fmt.Errorf("the AsyncWrite option isn't supported by the %s flavor", FlavorV3)
+		}
+		if conf.CreateBucket.Bool {
+			return fmt.Errorf("the CreateBucket option isn't supported by the %s flavor", FlavorV3)
+		}
+		if conf.OrgFromTag.String != "" {
+			return fmt.Errorf("the OrgFromTag option isn't supported by the %s flavor", FlavorV3)
+		}
+		return nil
+	case FlavorTelegraf:
+		if conf.AsyncWrite.Bool {
+			return fmt.Errorf("the AsyncWrite option isn't supported by the %s flavor", FlavorTelegraf)
+		}
+		if conf.CreateBucket.Bool {
+			return fmt.Errorf("the CreateBucket option isn't supported by the %s flavor", FlavorTelegraf)
+		}
+		if conf.OrgFromTag.String != "" {
+			return fmt.Errorf("the OrgFromTag option isn't supported by the %s flavor", FlavorTelegraf)
+		}
+		if conf.Gzip.Bool {
+			return fmt.Errorf("the Gzip option isn't supported by the %s flavor", FlavorTelegraf)
+		}
+		if conf.StartupHealthCheck.Bool {
+			return fmt.Errorf("the StartupHealthCheck option isn't supported by the %s flavor", FlavorTelegraf)
+		}
+		return nil
+	default:
+		return fmt.Errorf("an invalid flavor (%s) is specified, the allowed values are: %s, %s and %s",
+			conf.Flavor.String, FlavorV2, FlavorV3, FlavorTelegraf)
 	}
-	if conf.ConcurrentWrites.Int64 <= 0 {
-		return nil, fmt.Errorf("the ConcurrentWrites option must be a positive number")
+}
+
+// newClientOptions returns the client's options for the synchronous writes,
+// the asynchronous writer's options are set by New. The context bounds the loading
+// of the AWS config, when AWSSigV4 is enabled.
+func newClientOptions(ctx context.Context, conf Config) (*influxdbclient.Options, error) {
+	precision, err := conf.writePrecision()
+	if err != nil {
+		return nil, err
 	}
 	opts := influxdbclient.DefaultOptions().
 		SetTLSConfig(&tls.Config{
 			InsecureSkipVerify: conf.InsecureSkipTLSVerify.Bool, //nolint:gosec
-		})
-	if conf.Precision.Valid {
-		opts.SetPrecision(time.Duration(conf.Precision.Duration))
+		}).
+		SetPrecision(precision)
+	if conf.Consistency.String != "" {
+		opts.WriteOptions().SetConsistency(write.Consistency(conf.Consistency.String))
 	}
-	cl := influxdbclient.NewClientWithOptions(conf.Addr.String, conf.Token.String, opts)
-	fldKinds, err := makeFieldKinds(conf)
-	if err != nil {
-		return nil, err
+	opts.SetUseGZip(conf.Gzip.Bool)
+	if client := getHTTPClient(); client != nil {
+		// the transport of the injected client isn't changed
+		opts.SetHTTPClient(client)
+	} else {
+		configureTransport(opts, conf)
+	}
+	if conf.AWSSigV4.Bool {
+		if err := signRequests(ctx, opts, conf); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// newPointsWriter returns the synchronous writer of the flavor.
+func newPointsWriter(conf Config, cl influxdbclient.Client, opts *influxdbclient.Options) (pointsWriter, error) {
+	switch conf.Flavor.String {
+	case FlavorV3:
+		return newV3Writer(opts.HTTPClient(), serverURL(conf), conf.Bucket.String, conf.Token.String,
+			opts.Precision(), conf.Gzip.Bool)
+	case FlavorTelegraf:
+		return newSocketWriter(conf.Addr.String, opts.Precision())
+	default:
+		return cl.WriteAPIBlocking(conf.Organization.String, conf.Bucket.String), nil
 	}
-	return &Output{
-		params:      params,
-		logger:      logger,
-		client:      cl,
-		config:      conf,
-		fieldKinds:  fldKinds,
-		pointWriter: cl.WriteAPIBlocking(conf.Organization.String, conf.Bucket.String),
-		semaphoreCh: make(chan struct{}, conf.ConcurrentWrites.Int64),
-		wg:          sync.WaitGroup{},
-	}, nil
 }
 
-// Description returns a human-readable description of the output.
+// configureTransport tunes the connections' pool of the client's transport,
+// by default it keeps warm a connection for each of the concurrent writes.
+func configureTransport(opts *influxdbclient.Options, conf Config) {
+	maxIdleConns := int(conf.ConcurrentWrites.Int64)
+	if conf.MaxIdleConns.Valid {
+		maxIdleConns = int(conf.MaxIdleConns.Int64)
+	}
+	// it creates the default client, so all the other transport's settings are kept
+	tr, ok := opts.HTTPClient().Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	// all the requests are for the same host
+	tr.MaxIdleConns = maxIdleConns
+	tr.MaxIdleConnsPerHost = maxIdleConns
+	tr.IdleConnTimeout = time.Duration(conf.IdleConnTimeout.Duration)
+	if conf.ForceHTTP2.Valid {
+		// the client's transport has a custom dialer and TLS config,
+		// so HTTP/2 isn't negotiated unless it is explicitly attempted
+		tr.ForceAttemptHTTP2 = conf.ForceHTTP2.Bool
+	}
+
+	if socket, ok := unixSocketPath(conf.Addr.String); ok {
+		// the same timeout of the client's default dialer
+		tr.DialContext = unixSocketDialer(&net.Dialer{Timeout: 5 * time.Second}, socket)
+	}
+
+	if len(conf.InsecureSkipTLSVerifyHosts) > 0 && !conf.InsecureSkipTLSVerify.Bool {
+		// the same timeout of the client's default dialer
+		tr.DialTLSContext = insecureHostsDialer(tr.TLSClientConfig, &net.Dialer{Timeout: 5 * time.Second},
+			conf.InsecureSkipTLSVerifyHosts)
+	}
+}
+
+// ResolvedConfig returns the consolidated config used by the output,
+// with the credentials redacted.
+func (o *Output) ResolvedConfig() Config {
+	return o.config.Redacted()
+}
+
+// Description returns a human-readable description of the output, with its flavor.
 func (o *Output) Description() string {
-	return fmt.Sprintf("InfluxDBv2 (%s)", o.config.Addr.String)
+	return fmt.Sprintf("InfluxDB %s (%s)", o.config.Flavor.String, redactURL(o.config.Addr.String))
 }
 
 // Start initializes the SampleBuffer for collect samples.
 func (o *Output) Start() error {
 	o.logger.Debug("Starting...")
-	pf, err := output.NewPeriodicFlusher(time.Duration(o.config.PushInterval.Duration), o.flushMetrics)
+	o.ctx, o.cancel = context.WithCancel(context.Background())
+	if o.config.CreateBucket.Bool {
+		if err := o.ensureBucket(o.ctx); err != nil {
+			o.cancel()
+			return err
+		}
+	}
+	if o.deadLetter != nil {
+		if err := o.deadLetter.open(); err != nil {
+			o.cancel()
+			return err
+		}
+	}
+	if o.wal != nil {
+		n, err := o.wal.open()
+		if err != nil {
+			o.cancel()
+			return err
+		}
+		if n > 0 {
+			o.logger.WithField("segments", n).WithField("dir", o.wal.dir).
+				Warn("The WAL contains the unwritten batches of a previous run, they can be recovered with ReplayWAL")
+		}
+	}
+	if o.config.AsyncWrite.Bool {
+		o.startAsyncWriter()
+	}
+	if o.config.EmitLifecycleEvents.Bool {
+		o.writeLifecycleEvent(o.ctx, lifecyclePhaseStart, o.clock.Now())
+	}
+	if o.config.EmitRunMetadata.Bool {
+		o.writeRunMetadata(o.ctx, o.clock.Now())
+	}
+	o.flusherMu.Lock()
+	defer o.flusherMu.Unlock()
+	pf, err := o.newPeriodicFlusher(time.Duration(o.pushInterval.Load()))
 	if err != nil {
+		o.cancel()
 		return err
 	}
+	if o.config.FlushThreshold.Int64 > 0 {
+		o.startEarlyFlusher()
+	}
 	o.logger.Debug("Started")
 	o.periodicFlusher = pf
 	return nil
 }
 
+// newPeriodicFlusher returns the flusher for the push interval, if a jitter is set
+// then the interval is randomized once or on each tick, so the outputs of more instances
+// started at the same time don't flush in synchronized bursts.
+func (o *Output) newPeriodicFlusher(interval time.Duration) (interface{ Stop() }, error) {
+	jitter := time.Duration(o.config.PushIntervalJitter.Duration)
+	if jitter > 0 && o.config.PushIntervalJitterPerTick.Bool {
+		return newJitteredFlusher(o.clock, interval, jitter, o.periodicFlush), nil
+	}
+	interval = jitteredInterval(interval, jitter)
+	if jitter > 0 {
+		o.logger.WithField("interval", interval).Debug("The push interval has been jittered")
+	}
+	return newTickerFlusher(o.clock, interval, o.periodicFlush)
+}
+
+// periodicFlush is called on each push interval, it writes the heartbeat
+// before flushing, so a point is written also when no sample is buffered.
+func (o *Output) periodicFlush() {
+	if o.config.EmitHeartbeat.Bool {
+		o.emitHeartbeat(o.clock.Now())
+	}
+	o.flushMetrics()
+}
+
+// SetPushInterval changes the push interval, it is safe to call it concurrently with the flushes.
+// If the output is running, the periodic flusher is replaced by a new one with the interval,
+// the previous one flushes a last time when it is stopped, so the buffered samples aren't lost.
+// The async writer's flush interval isn't changed.
+func (o *Output) SetPushInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("the push interval must be a positive duration")
+	}
+	if time.Duration(o.config.PushIntervalJitter.Duration) >= d {
+		return fmt.Errorf("the push interval must be higher than PushIntervalJitter")
+	}
+
+	o.flusherMu.Lock()
+	defer o.flusherMu.Unlock()
+	if o.stopped {
+		return fmt.Errorf("the push interval can't be changed, the output is stopped")
+	}
+	if o.periodicFlusher == nil {
+		// it is used by Start
+		o.pushInterval.Store(int64(d))
+		return nil
+	}
+	// the new flusher is started before the previous one is stopped,
+	// so the samples are always flushed
+	pf, err := o.newPeriodicFlusher(d)
+	if err != nil {
+		return err
+	}
+	o.pushInterval.Store(int64(d))
+	o.periodicFlusher.Stop()
+	o.periodicFlusher = pf
+	o.logger.WithField("interval", d).Debug("The push interval has been changed")
+	return nil
+}
+
 // Stop flushes any remaining metrics and stops the goroutine.
 func (o *Output) Stop() error {
+	return o.StopWithTestError(nil)
+}
+
+// StopWithTestError flushes any remaining metrics and stops the goroutine.
+// It keeps flushing until the buffer is empty, at most for the StopTimeout, if set.
+// If the test run has been aborted then the in-flight writes are cancelled
+// instead of waiting for their completion.
+func (o *Output) StopWithTestError(testRunErr error) error {
 	o.logger.Debug("Stopping...")
+	stoppedAt := o.clock.Now()
+	if testRunErr != nil {
+		o.logger.WithError(testRunErr).Debug("The test run has been aborted, cancelling the in-flight writes")
+		o.cancel()
+	}
+	if o.earlyFlushStop != nil {
+		close(o.earlyFlushStop)
+		<-o.earlyFlushDone
+	}
+	o.flusherMu.Lock()
 	o.periodicFlusher.Stop()
-	o.client.Close()
+	o.stopped = true
+	o.flusherMu.Unlock()
+	if testRunErr == nil {
+		o.drainBuffer()
+	}
 	o.wg.Wait()
+	if o.config.SummaryOnly.Bool {
+		o.writeSummary(context.Background(), stoppedAt)
+	}
+	if o.config.EmitLifecycleEvents.Bool {
+		// the writes' context could be already cancelled by the aborted test
+		o.writeLifecycleEvent(context.Background(), lifecyclePhaseStop, stoppedAt)
+	}
+	if o.config.EmitThresholds.Bool {
+		o.writeThresholds(context.Background(), stoppedAt)
+	}
+	// it flushes the async writer's buffer, if any
+	o.currentClient().Close()
+	if o.asyncErrorsDone != nil {
+		<-o.asyncErrorsDone
+	}
+	o.cancel()
+	if o.clientLogger != nil {
+		clientLog.detach(o.clientLogger)
+	}
+	if o.deadLetter != nil {
+		if err := o.deadLetter.Close(); err != nil {
+			o.logger.WithError(err).Error("Couldn't flush the dead letter file")
+		}
+	}
+
+	for msg, occurrences := range o.writeErrors.drain() {
+		o.logger.WithField("error", msg).
+			Errorf("InfluxDB write failing: %d more times since the last report", occurrences)
+	}
+
+	stats := o.stats.stats()
+	if stats.RejectedPoints > 0 {
+		o.logger.Warnf("%d metrics points have been rejected by InfluxDB with partial writes", stats.RejectedPoints)
+	}
+	if stats.DroppedSamples > 0 {
+		o.logger.Warnf("%d metrics samples have been dropped because the write concurrency was saturated",
+			stats.DroppedSamples)
+	}
+	fd := stats.FlushDuration
+	o.logger.WithFields(logrus.Fields{
+		"count": fd.Count,
+		"min":   fd.Min,
+		"max":   fd.Max,
+		"p50":   fd.P50,
+		"p95":   fd.P95,
+	}).Debug("Flush operations summary")
+	o.logMirrorWrites(stats)
+
 	o.logger.Debug("Stopped")
-	return nil
+	return o.finalWriteErrorBudget(stats)
+}
+
+// Stats returns the statistics collected so far.
+func (o *Output) Stats() Stats {
+	return o.stats.stats()
 }
 
-func (o *Output) extractTagsToValues(tags map[string]string, values map[string]interface{}) map[string]interface{} {
+// extractTagsToValues moves the tags set by TagsAsFields and AutoFieldNumericTags to the values.
+// It returns an error if a tag can't be parsed as its field's type
+// and the sample has to be skipped, as set by OnFieldParseError.
+func (o *Output) extractTagsToValues(
+	metric string, tags map[string]string, values map[string]interface{},
+) (map[string]interface{}, error) {
 	for tag, kind := range o.fieldKinds {
+		if !o.isFieldExtracted(tag, metric) {
+			continue
+		}
 		if val, ok := tags[tag]; ok {
 			var v interface{}
 			var err error
@@ -136,103 +714,1040 @@ func (o *Output) extractTagsToValues(tags map[string]string, values map[string]i
 			case Int:
 				v, err = strconv.ParseInt(val, 10, 64)
 			}
-			if err == nil {
-				values[tag] = v
-			} else {
-				values[tag] = val
+			switch {
+			case err == nil:
+				values[o.fieldKey(tag)] = v
+			case o.config.OnFieldParseError.String == OnFieldParseErrorError:
+				return values, o.fieldParseError(tag, val, err)
+			case o.config.OnFieldParseError.String != OnFieldParseErrorDrop:
+				values[o.fieldKey(tag)] = val
+			}
+			if !o.config.KeepExtractedTags.Bool {
+				delete(tags, tag)
+			}
+		}
+	}
+	if o.numericTags != nil {
+		for tag, val := range tags {
+			if _, ok := values[o.fieldKey(tag)]; ok {
+				continue
+			}
+			v, ok := o.numericTags.fieldValue(tag, val)
+			if !ok {
+				continue
 			}
-			delete(tags, tag)
+			values[tag] = v
+			if !o.config.KeepExtractedTags.Bool {
+				delete(tags, tag)
+			}
+		}
+	}
+	return values, nil
+}
+
+// isFieldExtracted reports whether the tag is extracted as a field for the metric,
+// that is always true for the TagsAsFields rules without a condition.
+func (o *Output) isFieldExtracted(tag, metric string) bool {
+	metricNames, ok := o.fieldMetrics[tag]
+	if !ok {
+		return true
+	}
+	_, ok = metricNames[metric]
+	return ok
+}
+
+type cacheItem struct {
+	tags   map[string]string
+	values map[string]interface{}
+	err    error
+}
+
+type cacheKey struct {
+	// tags is the key of the TagSet's content
+	tags string
+	// metricType is set only when the metric's type is added to the point
+	metricType metrics.MetricType
+	// metric is set only when a TagsAsFields rule has a condition on the metric
+	metric string
+}
+
+// tagsCache is used for extracting the tags and the fields from the same set of tags
+// only once per batch. It is keyed on the tags' content, so it doesn't depend on
+// how k6 reuses the TagSets: different TagSets with the same tags share the entry.
+type tagsCache struct {
+	// tagKeys memoizes the key of each TagSet, so its content is read once per batch.
+	// It only assumes the k6's TagSets are immutable.
+	tagKeys map[*metrics.TagSet]string
+	items   map[cacheKey]cacheItem
+}
+
+// newTagsCache returns the cache of the batch, or nil if it is disabled.
+// It can be disabled for ruling it out when a correctness issue is investigated.
+func (o *Output) newTagsCache() *tagsCache {
+	if o.config.DisableTagCache.Bool {
+		return nil
+	}
+	return &tagsCache{
+		tagKeys: make(map[*metrics.TagSet]string),
+		items:   make(map[cacheKey]cacheItem),
+	}
+}
+
+// get returns the cached item of the key, it never finds an item when the cache is disabled.
+func (c *tagsCache) get(key cacheKey) (cacheItem, bool) {
+	if c == nil {
+		return cacheItem{}, false
+	}
+	item, ok := c.items[key]
+	return item, ok
+}
+
+// tagSetKey returns the key of the TagSet's content.
+func (c *tagsCache) tagSetKey(tags *metrics.TagSet) string {
+	if key, ok := c.tagKeys[tags]; ok {
+		return key
+	}
+	var sb strings.Builder
+	writeTagsKey(&sb, tags.Map())
+	key := sb.String()
+	c.tagKeys[tags] = key
+	return key
+}
+
+// sampleTagsAndValues returns the tags and the fields extracted from the sample's tags.
+// The tags can be shared between the points of the batch so they must not be changed,
+// instead the values are always a new map. If an error is returned the sample is skipped.
+func (o *Output) sampleTagsAndValues(
+	sample metrics.Sample, cache *tagsCache,
+) (map[string]string, map[string]interface{}, error) {
+	var key cacheKey
+	if cache != nil {
+		key.tags = cache.tagSetKey(sample.Tags)
+	}
+	if o.config.AddMetricType.Bool {
+		key.metricType = sample.Metric.Type
+	}
+	if len(o.fieldMetrics) > 0 || len(o.tagDrops) > 0 {
+		key.metric = sample.Metric.Name
+	}
+	values := make(map[string]interface{})
+	if cached, ok := cache.get(key); ok {
+		if cached.err != nil {
+			return nil, nil, cached.err
+		}
+		for k, v := range cached.values {
+			values[k] = v
+		}
+		return cached.tags, values, nil
+	}
+
+	tags := sample.Tags.Map()
+	if scenario := o.config.DefaultScenarioTag.String; scenario != "" {
+		// it never overrides the sample's tag, also when it is empty
+		if _, ok := tags[scenarioTag]; !ok {
+			tags[scenarioTag] = scenario
+		}
+	}
+	o.addGlobalTags(tags)
+	if _, err := o.extractTagsToValues(sample.Metric.Name, tags, values); err != nil {
+		if cache != nil {
+			cache.items[key] = cacheItem{err: err}
+		}
+		return nil, nil, err
+	}
+	o.filterTags(tags)
+	o.dropMetricTags(sample.Metric.Name, tags)
+	if o.config.DropEmptyTags.Bool {
+		// after the extraction, so the empty tags set as fields are kept as fields
+		o.dropEmptyTags(tags)
+	}
+	// after the extraction, so the fields keep the full values
+	o.truncateTagValues(tags)
+	if o.config.AddRunID.Bool {
+		tags[o.config.RunIDTag.String] = o.config.RunID.String
+	}
+	if o.config.AddMetricType.Bool {
+		if o.config.MetricTypeAsField.Bool {
+			values[o.config.MetricTypeKey.String] = sample.Metric.Type.String()
+		} else {
+			tags[o.config.MetricTypeKey.String] = sample.Metric.Type.String()
+		}
+	}
+	if prefix := o.config.TagKeyPrefix.String; prefix != "" {
+		// the cached tags are already prefixed, so they are prefixed only once
+		tags = prefixTagKeys(tags, prefix)
+		if o.config.PrefixFieldKeys.Bool {
+			values = prefixValueKeys(values, prefix)
 		}
 	}
-	return values
+	if o.keySanitizer != nil {
+		o.keySanitizer.sanitizeTags(tags)
+		o.keySanitizer.sanitizeValues(values)
+	}
+	escapeTags(tags)
+	escapeStringFields(values)
+	if cache != nil {
+		cachedValues := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			cachedValues[k] = v
+		}
+		cache.items[key] = cacheItem{tags: tags, values: cachedValues}
+	}
+	return tags, values, nil
+}
+
+// prefixTagKeys returns a copy of the tags with the prefix added to each key.
+func prefixTagKeys(tags map[string]string, prefix string) map[string]string {
+	prefixed := make(map[string]string, len(tags))
+	for k, v := range tags {
+		prefixed[prefix+k] = v
+	}
+	return prefixed
+}
+
+// prefixValueKeys returns a copy of the values with the prefix added to each key.
+func prefixValueKeys(values map[string]interface{}, prefix string) map[string]interface{} {
+	prefixed := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		prefixed[prefix+k] = v
+	}
+	return prefixed
+}
+
+// pointTime returns the timestamp of the point for the sample.
+func (o *Output) pointTime(sample metrics.Sample) time.Time {
+	// the offset is applied before the precision's truncation done by the encoder
+	return sample.Time.Add(time.Duration(o.config.TimestampOffset.Duration))
 }
 
 func (o *Output) batchFromSamples(containers []metrics.SampleContainer) []*write.Point {
-	type cacheItem struct {
-		tags   map[string]string
-		values map[string]interface{}
+	containers = o.sampleTrends(containers)
+	if o.config.SingleMeasurement.Bool {
+		return o.combinedBatchFromSamples(containers)
 	}
-	cache := map[*metrics.TagSet]cacheItem{}
 
+	cache := o.newTagsCache()
 	var points []*write.Point
 	for _, container := range containers {
 		samples := container.GetSamples()
 		for _, sample := range samples {
-			var tags map[string]string
-			values := make(map[string]interface{})
-			if cached, ok := cache[sample.Tags]; ok {
-				tags = cached.tags
-				for k, v := range cached.values {
-					values[k] = v
+			if !o.isSampleWritten(sample) {
+				continue
+			}
+			tags, values, err := o.sampleTagsAndValues(sample, cache)
+			if err != nil {
+				// it is already logged
+				continue
+			}
+			primary := o.addSampleValue(sample, values)
+			if !o.finiteFields(sample.Metric.Name, values) || len(values) == 0 {
+				continue
+			}
+			measurement, tags := o.pointMeasurement(sample, tags)
+			for _, fields := range o.limitFields(values, primary) {
+				p := influxdbclient.NewPoint(
+					measurement,
+					tags,
+					fields,
+					o.pointTime(sample),
+				)
+				if o.PointMutator != nil {
+					o.PointMutator(p, sample)
 				}
-			} else {
-				tags = sample.Tags.Map()
-				o.extractTagsToValues(tags, values)
-				cache[sample.Tags] = cacheItem{tags, values}
+				points = append(points, p)
 			}
-			values["value"] = sample.Value
-			p := influxdbclient.NewPoint(
-				sample.Metric.Name,
-				tags,
-				values,
-				sample.Time,
-			)
-			points = append(points, p)
 		}
 	}
 
 	return points
 }
 
-func (o *Output) flushMetrics() {
-	samples := o.GetBufferedSamples()
-	if len(samples) == 0 {
-		return
+// addSampleValue adds the sample's value to the fields and returns the key of its field.
+func (o *Output) addSampleValue(sample metrics.Sample, values map[string]interface{}) string {
+	primary := o.valueField(sample.Metric.Type)
+	switch {
+	case o.isCheckAsBool(sample):
+		// a separate field, the existing points have a float value field
+		values[checkPassedField] = sample.Value != 0
+		primary = checkPassedField
+	// a point requires at least a field, so the value is kept if there isn't any other
+	case !o.config.OmitValueField.Bool || len(values) == 0:
+		values[primary] = sample.Value
 	}
+	return primary
+}
 
-	o.wg.Add(1)
-	o.semaphoreCh <- struct{}{}
-	go func() {
-		defer func() {
-			<-o.semaphoreCh
-			o.wg.Done()
-		}()
-
-		start := time.Now()
-		batch := o.batchFromSamples(samples)
+// valueField returns the key of the field with the value of the metric type's samples,
+// with FluxSchema it is named as the column of the values in the Flux tables.
+// A metric's type never changes, so its samples always have the same value field.
+func (o *Output) valueField(t metrics.MetricType) string {
+	if field, ok := o.valueFields[t]; ok {
+		return field
+	}
+	if o.config.FluxSchema.Bool {
+		return "_value"
+	}
+	return "value"
+}
 
-		o.logger.WithField("samples", len(samples)).WithField("points", len(batch)).Debug("Sending metrics points...")
-		if err := o.pointWriter.WritePoint(context.Background(), batch...); err != nil {
-			o.logger.WithError(err).
-				WithField("elapsed", time.Since(start)).
-				WithField("points", len(batch)).
-				Error("Couldn't send metrics points")
+// combinedBatchFromSamples returns a single measurement's point for each set of tags and timestamp,
+// with a field for each metric named as the metric. If the same metric has more samples
+// with the same tags and timestamp then an additional point is created for each of them,
+// so no value is overwritten. With MergeTolerance, a sample is combined in the latest point
+// of its tags if their timestamps are within the tolerance; the point keeps the full-precision
+// timestamp of its first sample, so it doesn't drift with the merged samples.
+func (o *Output) combinedBatchFromSamples(containers []metrics.SampleContainer) []*write.Point {
+	type combinedPoint struct {
+		tags   map[string]string
+		values map[string]interface{}
+		time   time.Time
+		sample metrics.Sample
+	}
+
+	cache := o.newTagsCache()
+	tolerance := time.Duration(o.config.MergeTolerance.Duration)
+	var combined []*combinedPoint
+	// the latest point created for each series and timestamp,
+	// or for each series with a merge tolerance
+	latest := make(map[string]*combinedPoint)
+	for _, container := range containers {
+		samples := container.GetSamples()
+		for _, sample := range samples {
+			if !o.isSampleWritten(sample) {
+				continue
+			}
+			tags, values, err := o.sampleTagsAndValues(sample, cache)
+			if err != nil {
+				// it is already logged
+				continue
+			}
+			if !o.finiteFields(sample.Metric.Name, values) {
+				continue
+			}
+			field, value := sample.Metric.Name, interface{}(sample.Value)
+			if o.isCheckAsBool(sample) {
+				field, value = sample.Metric.Name+"_"+checkPassedField, sample.Value != 0
+			} else if !isFinite(sample.Value) {
+				if o.config.NonFiniteMode.String == NonFiniteSkipSample {
+					o.logNonFinite(sample.Metric.Name, field, sample.Value)
+					continue
+				}
+				v, ok := o.finiteValue(sample.Metric.Name, field, sample.Value)
+				if !ok {
+					continue
+				}
+				value = v
+			}
+			t := o.pointTime(sample)
+			var key string
+			if tolerance > 0 {
+				key = tagsKey(tags)
+			} else {
+				key = seriesKey(tags, t)
+			}
+			cp, ok := latest[key]
+			if ok {
+				// the metric has already a value in the point
+				_, dup := cp.values[field]
+				ok = !dup && withinTolerance(cp.time, t, tolerance)
+			}
+			if !ok {
+				cp = &combinedPoint{tags: tags, values: values, time: t, sample: sample}
+				latest[key] = cp
+				combined = append(combined, cp)
+			}
+			cp.values[field] = value
+		}
+	}
+
+	points := make([]*write.Point, 0, len(combined))
+	for _, cp := range combined {
+		for _, fields := range o.limitFields(cp.values, "") {
+			p := influxdbclient.NewPoint(o.config.SingleMeasurementName.String, cp.tags, fields, cp.time)
+			if o.PointMutator != nil {
+				o.PointMutator(p, cp.sample)
+			}
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// logWriteError logs the write's error, the identical errors
+// are aggregated and logged at most once per the configured window.
+func (o *Output) logWriteError(err error, fields ...logrus.Fields) {
+	ok, occurrences, since := o.writeErrors.record(err, o.clock.Now())
+	if !ok {
+		return
+	}
+	logger := o.logger.WithError(err).WithFields(serverErrorFields(err))
+	for _, f := range fields {
+		logger = logger.WithFields(f)
+	}
+	if occurrences > 1 {
+		logger.Errorf("InfluxDB write failing: %d times in the last %s", occurrences, since.Round(time.Second))
+		return
+	}
+	logger.Error("Couldn't send metrics points")
+}
+
+// withinTolerance reports if the timestamps are at most the tolerance apart.
+func withinTolerance(a, b time.Time, tolerance time.Duration) bool {
+	d := a.Sub(b)
+	if d < 0 {
+		d = -d
+	}
+	return d <= tolerance
+}
+
+// tagsKey returns a key identifying the set of tags.
+func tagsKey(tags map[string]string) string {
+	var sb strings.Builder
+	writeTagsKey(&sb, tags)
+	return sb.String()
+}
+
+// seriesKey returns a key identifying the set of tags and the timestamp.
+func seriesKey(tags map[string]string, t time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatInt(t.UnixNano(), 10))
+	writeTagsKey(&sb, tags)
+	return sb.String()
+}
+
+// writeTagsKey writes a key identifying the set of tags, independent of the map's order.
+func writeTagsKey(sb *strings.Builder, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(tags[k])
+	}
+}
+
+// isMetricTypeWritten reports if the samples of the metric type are written,
+// the IncludedMetricTypes option is applied before ExcludedMetricTypes.
+func (o *Output) isMetricTypeWritten(t metrics.MetricType) bool {
+	if len(o.includedTypes) > 0 {
+		if _, ok := o.includedTypes[t]; !ok {
+			return false
+		}
+	}
+	_, excluded := o.excludedTypes[t]
+	return !excluded
+}
+
+// checkPassedField is the boolean field of the checks' results written by CheckAsBool.
+const checkPassedField = "passed"
+
+// isCheckAsBool reports if the sample is a check's result written as a boolean.
+func (o *Output) isCheckAsBool(sample metrics.Sample) bool {
+	return o.config.CheckAsBool.Bool && sample.Metric.Name == metrics.ChecksName
+}
+
+// isSampleWritten reports if the sample passes the metric's type, value and time window filters.
+func (o *Output) isSampleWritten(sample metrics.Sample) bool {
+	return o.isMetricTypeWritten(sample.Metric.Type) && o.isValueWritten(sample) &&
+		o.timeWindow.contains(sample.Time)
+}
+
+// isValueWritten reports if the sample's value passes the DropZeroValues and MinValue filters.
+// The rates are never filtered, their zero values are meaningful, as the excluded metrics' ones.
+func (o *Output) isValueWritten(sample metrics.Sample) bool {
+	if !o.config.DropZeroValues.Bool && !o.config.MinValue.Valid {
+		return true
+	}
+	if sample.Metric.Type == metrics.Rate {
+		return true
+	}
+	if _, excluded := o.valueExcluded[sample.Metric.Name]; excluded {
+		return true
+	}
+	if o.config.DropZeroValues.Bool && sample.Value == 0 {
+		return false
+	}
+	return !o.config.MinValue.Valid || sample.Value >= o.config.MinValue.Float64
+}
+
+// filterTags removes the tags not included in the KeepTags option, when it is set,
+// then it removes the tags included in the DropTags option.
+func (o *Output) filterTags(tags map[string]string) {
+	if len(o.keepTags) > 0 {
+		for tag := range tags {
+			if _, ok := o.keepTags[tag]; !ok {
+				delete(tags, tag)
+			}
+		}
+	}
+	for tag := range o.dropTags {
+		delete(tags, tag)
+	}
+}
+
+// dropEmptyTags removes the tags with an empty value, they would only create meaningless series.
+// Each dropped tag is logged at the debug level the first time.
+func (o *Output) dropEmptyTags(tags map[string]string) {
+	for tag, val := range tags {
+		if val != "" {
+			continue
+		}
+		delete(tags, tag)
+		if _, logged := o.emptyTags.LoadOrStore(tag, struct{}{}); !logged {
+			o.logger.WithField("tag", tag).Debug("The tag with an empty value has been dropped")
+		}
+	}
+}
+
+// measurementName returns the name of the measurement for the metric.
+// The metric's name is preserved when no prefix is configured.
+func (o *Output) measurementName(metricName string) string {
+	if o.config.MeasurementPrefix.String == "" {
+		return metricName
+	}
+	return o.config.MeasurementPrefix.String + o.config.MeasurementSeparator.String + metricName
+}
+
+func (o *Output) flushMetrics() {
+	o.flushSamples(o.GetBufferedSamples())
+}
+
+// drainBuffer flushes the samples buffered after the final flush, e.g. added by the last
+// iterations while it was writing, waiting for the in-flight writes until the buffer is empty.
+// When the StopTimeout expires, the samples still buffered are discarded.
+func (o *Output) drainBuffer() {
+	timeout := time.Duration(o.config.StopTimeout.Duration)
+	deadline := o.clock.Now().Add(timeout)
+	for {
+		o.wg.Wait()
+		samples := o.GetBufferedSamples()
+		if len(samples) == 0 {
+			return
+		}
+		if timeout > 0 && o.clock.Now().After(deadline) {
+			o.untrackBufferedSamples(samples)
+			n := int(countSamples(samples))
+			o.stats.recordDroppedSamples(n)
+			o.logger.WithField("samples", n).WithField("timeout", timeout).
+				Warn("The stop timeout has expired, the buffered samples have been discarded")
+			return
+		}
+		o.flushSamples(samples)
+	}
+}
+
+// recordIdleFlush counts a flush without any buffered sample, it does nothing else,
+// so the consecutive empty intervals don't take a write slot nor start a goroutine.
+func (o *Output) recordIdleFlush() {
+	if o.idleFlushes.Add(1) == 1 && !o.config.SkipEmptyFlushLogging.Bool {
+		o.logger.Debug("No metrics samples buffered, the flushes are skipped until new samples are buffered")
+	}
+}
+
+// recordActiveFlush ends the idle period, if any.
+func (o *Output) recordActiveFlush() {
+	if n := o.idleFlushes.Swap(0); n > 0 && !o.config.SkipEmptyFlushLogging.Bool {
+		o.logger.WithField("skipped", n).Debug("Metrics samples buffered again, the flushes are resumed")
+	}
+}
+
+// flushSamples writes the samples read from the buffer.
+func (o *Output) flushSamples(samples []metrics.SampleContainer) {
+	if len(samples) == 0 {
+		o.recordIdleFlush()
+		return
+	}
+	o.recordActiveFlush()
+	o.untrackBufferedSamples(samples)
+	if o.config.SummaryOnly.Bool {
+		o.summarizeSamples(samples)
+		return
+	}
+	o.reconnectIfFailing()
+
+	weight, err := o.acquireWriteSlot(countSamples(samples))
+	if err != nil {
+		if errors.Is(err, errWriteSlotTimeout) {
+			n := int(countSamples(samples))
+			o.stats.recordDroppedSamples(n)
+			o.logger.WithField("samples", n).WithField("timeout", time.Duration(o.config.WriteSlotTimeout.Duration)).
+				Warn("Write concurrency saturated, dropping batch")
 			return
 		}
+		o.logger.WithField("samples", len(samples)).
+			Warn("The output has been stopped, the metrics samples have been discarded")
+		return
+	}
+	o.wg.Add(1)
+	go func() {
+		defer func() {
+			o.releaseWriteSlot(weight)
+			o.wg.Done()
+		}()
+		// the error is already logged
+		_ = o.writeSamples(samples)
+	}()
+}
+
+// Flush synchronously writes the buffered samples, without waiting for the next push interval.
+// It can be called concurrently with the periodic flush, sharing the same limit of concurrent writes.
+// In the AsyncWrite mode it waits for the async writer's buffer to be sent,
+// but the errors are only logged.
+func (o *Output) Flush() error {
+	if o.ctx == nil {
+		return errors.New("the output hasn't been started")
+	}
+	if o.ctx.Err() != nil {
+		return errors.New("the output has been stopped")
+	}
+	// the flush is tracked in the same critical section where Stop marks the output as stopped,
+	// so it's never added while Stop waits for the in-flight writes
+	o.flusherMu.Lock()
+	if o.stopped {
+		o.flusherMu.Unlock()
+		return errors.New("the output has been stopped")
+	}
+	o.wg.Add(1)
+	o.flusherMu.Unlock()
+	defer o.wg.Done()
+
+	if _, err := o.acquireWriteSlot(0); err != nil {
+		if errors.Is(err, errWriteSlotTimeout) {
+			return err
+		}
+		return errors.New("the output has been stopped")
+	}
+	defer o.releaseWriteSlot(0)
+
+	samples := o.GetBufferedSamples()
+	if len(samples) == 0 {
+		return nil
+	}
+	o.untrackBufferedSamples(samples)
+	if o.config.SummaryOnly.Bool {
+		o.summarizeSamples(samples)
+		return nil
+	}
+	// the samples are known only now, so their share of the in-flight budget
+	// is taken separately from the write slot
+	weight, err := o.acquireInFlightPoints(countSamples(samples))
+	if err != nil {
+		if errors.Is(err, errWriteSlotTimeout) {
+			o.stats.recordDroppedSamples(int(countSamples(samples)))
+			return err
+		}
+		return errors.New("the output has been stopped")
+	}
+	defer o.releaseInFlightPoints(weight)
+	if err := o.writeSamples(samples); err != nil {
+		return err
+	}
+	if o.asyncWriter != nil {
+		o.asyncWriter.Flush()
+	}
+	return nil
+}
+
+// AddMetricSamples buffers the samples. When the FlushThreshold option is set,
+// it triggers an early flush if the buffered samples exceed the threshold.
+func (o *Output) AddMetricSamples(samples []metrics.SampleContainer) {
+	o.SampleBuffer.AddMetricSamples(samples)
+	threshold := o.config.FlushThreshold.Int64
+	if threshold <= 0 {
+		return
+	}
+	if o.bufferedSamples.Add(countSamples(samples)) < threshold {
+		return
+	}
+	select {
+	case o.earlyFlushCh <- struct{}{}:
+	default:
+		// a flush is already pending
+	}
+}
+
+// untrackBufferedSamples removes the samples taken from the buffer from the count.
+func (o *Output) untrackBufferedSamples(samples []metrics.SampleContainer) {
+	if o.config.FlushThreshold.Int64 > 0 {
+		o.bufferedSamples.Add(-countSamples(samples))
+	}
+}
+
+// startEarlyFlusher starts the goroutine flushing the buffer
+// when the FlushThreshold is exceeded, before the next push interval.
+func (o *Output) startEarlyFlusher() {
+	o.earlyFlushCh = make(chan struct{}, 1)
+	o.earlyFlushStop = make(chan struct{})
+	o.earlyFlushDone = make(chan struct{})
+	go func() {
+		defer close(o.earlyFlushDone)
+		for {
+			select {
+			case <-o.earlyFlushCh:
+				o.logger.WithField("threshold", o.config.FlushThreshold.Int64).
+					Debug("The buffered samples exceed the threshold, flushing...")
+				o.flushMetrics()
+			case <-o.earlyFlushStop:
+				return
+			}
+		}
+	}()
+}
+
+func countSamples(containers []metrics.SampleContainer) int64 {
+	var n int64
+	for _, c := range containers {
+		n += int64(len(c.GetSamples()))
+	}
+	return n
+}
+
+// errWriteSlotTimeout is returned when no write slot is available within the WriteSlotTimeout.
+var errWriteSlotTimeout = errors.New("write concurrency saturated")
+
+// acquireWriteSlot waits for a free slot of the concurrent writes and for the share
+// of the MaxInFlightPoints budget of the samples, the returned weight must be passed
+// to releaseWriteSlot. It waits at most the WriteSlotTimeout, if it is set,
+// so hung writes don't stall the flushes forever.
+func (o *Output) acquireWriteSlot(samples int64) (int64, error) {
+	ctx, cancel := o.writeSlotContext()
+	defer cancel()
+	if err := o.writeSlots.acquire(ctx); err != nil {
+		return 0, o.writeSlotErr()
+	}
+	weight, err := o.waitInFlightPoints(ctx, samples)
+	if err != nil {
+		o.writeSlots.release()
+		return 0, err
+	}
+	return weight, nil
+}
+
+// releaseWriteSlot releases the slot and the weight taken by acquireWriteSlot.
+func (o *Output) releaseWriteSlot(weight int64) {
+	o.releaseInFlightPoints(weight)
+	o.writeSlots.release()
+}
+
+// acquireInFlightPoints waits for the share of the MaxInFlightPoints budget of the samples,
+// at most the WriteSlotTimeout, the returned weight must be passed to releaseInFlightPoints.
+func (o *Output) acquireInFlightPoints(samples int64) (int64, error) {
+	ctx, cancel := o.writeSlotContext()
+	defer cancel()
+	return o.waitInFlightPoints(ctx, samples)
+}
+
+// waitInFlightPoints takes a weight proportional to the samples from the in-flight budget,
+// capped to the whole budget, so a batch larger than it is written alone.
+// It is a no-op when the MaxInFlightPoints option isn't set.
+func (o *Output) waitInFlightPoints(ctx context.Context, samples int64) (int64, error) {
+	if o.inFlight == nil || samples <= 0 {
+		return 0, nil
+	}
+	weight := samples
+	if budget := o.config.MaxInFlightPoints.Int64; weight > budget {
+		weight = budget
+	}
+	if err := o.inFlight.Acquire(ctx, weight); err != nil {
+		return 0, o.writeSlotErr()
+	}
+	return weight, nil
+}
+
+// releaseInFlightPoints gives back the weight to the in-flight budget.
+func (o *Output) releaseInFlightPoints(weight int64) {
+	if weight > 0 {
+		o.inFlight.Release(weight)
+	}
+}
+
+// writeSlotContext returns the output's context bounded by the WriteSlotTimeout, if it is set.
+func (o *Output) writeSlotContext() (context.Context, context.CancelFunc) {
+	if d := time.Duration(o.config.WriteSlotTimeout.Duration); d > 0 {
+		return context.WithTimeout(o.ctx, d)
+	}
+	return context.WithCancel(o.ctx)
+}
+
+// writeSlotErr returns the reason a wait for a write slot has been interrupted.
+func (o *Output) writeSlotErr() error {
+	if err := o.ctx.Err(); err != nil {
+		return err
+	}
+	return errWriteSlotTimeout
+}
+
+// writeBatch writes the points in a single request,
+// the returned error is already logged.
+func (o *Output) writeBatch(org string, batch []*write.Point, start time.Time) error {
+	if err := o.backoff.wait(o.ctx); err != nil {
+		o.logger.WithField("points", len(batch)).Warn("The metrics points write has been cancelled")
+		return err
+	}
+
+	wait := o.writeMirrors(len(batch), func(w pointsWriter) error {
+		return w.WritePoint(o.ctx, batch...)
+	})
+	defer wait()
+	if err := o.orgWriter(org).WritePoint(o.ctx, batch...); err != nil {
+		if errors.Is(err, context.Canceled) {
+			o.logger.WithField("points", len(batch)).Warn("The metrics points write has been cancelled")
+			return err
+		}
+		o.recordWriteOutcome(true)
+		if pause := o.backoff.record(err); pause > 0 {
+			o.logger.WithField("pause", pause).Warn("InfluxDB has requested to retry later, the writes are paused")
+		}
+		o.logWriteError(err, logrus.Fields{"elapsed": o.clock.Now().Sub(start), "points": len(batch)},
+			o.partialWriteFields(err, batch))
+		o.writeDeadLetter(err, batch)
+		o.notifyWriteError(err, len(batch))
+		return err
+	}
+	o.recordWriteOutcome(false)
+	o.recordThroughput(len(batch))
+	return nil
+}
+
+// splitBatch splits the batch in chunks of at most size points, a size of zero means no limit.
+func splitBatch(batch []*write.Point, size int) [][]*write.Point {
+	if size <= 0 || len(batch) <= size {
+		return [][]*write.Point{batch}
+	}
+	chunks := make([][]*write.Point, 0, (len(batch)+size-1)/size)
+	for len(batch) > size {
+		chunks = append(chunks, batch[:size])
+		batch = batch[size:]
+	}
+	return append(chunks, batch)
+}
+
+// splitBatchBytes splits the batch in chunks whose line protocol is at most maxBytes long,
+// a point longer than maxBytes is sent alone.
+func splitBatchBytes(batch []*write.Point, maxBytes int, precision time.Duration) [][]*write.Point {
+	var chunks [][]*write.Point
+	var buf bytes.Buffer
+	e := newLineEncoder(&buf, precision)
+	start, size := 0, 0
+	for i, p := range batch {
+		buf.Reset()
+		// a point that can't be encoded is counted as empty, its error is reported by the write,
+		// the buffer's length is used since the count returned by Encode misses the fields
+		n := 0
+		if _, err := e.Encode(p); err == nil {
+			n = buf.Len()
+		}
+		if i > start && size+n > maxBytes {
+			chunks = append(chunks, batch[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(batch) {
+		chunks = append(chunks, batch[start:])
+	}
+	return chunks
+}
+
+// batchChunks splits the batch in the chunks written by a request each,
+// respecting both the MaxBatchSize and the MaxBatchBytes options.
+func (o *Output) batchChunks(batch []*write.Point) [][]*write.Point {
+	chunks := splitBatch(batch, int(o.config.MaxBatchSize.Int64))
+	maxBytes := int(o.config.MaxBatchBytes.Int64)
+	if maxBytes <= 0 {
+		return chunks
+	}
+	precision := o.currentClient().Options().Precision()
+	bytesChunks := make([][]*write.Point, 0, len(chunks))
+	for _, chunk := range chunks {
+		bytesChunks = append(bytesChunks, splitBatchBytes(chunk, maxBytes, precision)...)
+	}
+	return bytesChunks
+}
+
+// writeSamples converts the samples to points and writes them,
+// the returned error is already logged.
+func (o *Output) writeSamples(samples []metrics.SampleContainer) error {
+	start := o.clock.Now()
+	if o.streamsLines() {
+		return o.writeSampleLines(samples, start)
+	}
+	batches := o.orgBatches(samples)
+	var batch []*write.Point
+	if len(batches) == 1 {
+		batch = batches[0].points
+	} else {
+		for _, b := range batches {
+			batch = append(batch, b.points...)
+		}
+	}
+	o.logTopMetrics(batch)
+
+	if err := o.waitRateLimit(len(batch)); err != nil {
+		o.logger.WithField("points", len(batch)).Warn("The metrics points write has been cancelled")
+		return err
+	}
+
+	if o.asyncWriter != nil {
+		o.logger.WithField("samples", len(samples)).WithField("points", len(batch)).
+			Debug("Enqueuing metrics points for the async writer...")
+		for _, p := range batch {
+			o.asyncWriter.WritePoint(p)
+		}
+		o.recordThroughput(len(batch))
+		return nil
+	}
+
+	o.logger.WithField("samples", len(samples)).WithField("points", len(batch)).Debug("Sending metrics points...")
+	segment := o.appendWAL(batch)
+	var werr error
+	failed, total := 0, 0
+	for _, b := range batches {
+		chunks := o.batchChunks(b.points)
+		total += len(chunks)
+		for _, chunk := range chunks {
+			if err := o.writeBatch(b.org, chunk, start); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				// the next chunks are still sent, the error is already logged
+				werr = err
+				failed++
+			}
+		}
+	}
+	if failed == 0 {
+		o.ackWAL(segment)
+	}
+	return o.endFlush(start, len(batch), failed == total, werr)
+}
 
-		d := time.Since(start)
-		o.logger.WithField("elapsed", d).Debug("Metrics points have been sent")
-		if d > time.Duration(o.config.PushInterval.Duration) {
-			msg := "The flush operation took higher than the expected set push interval. " +
-				"If you see this message multiple times then the setup or configuration " +
-				"need to be adjusted to achieve a sustainable rate."
-			o.logger.WithField("t", d).Warn(msg)
+// endFlush records the outcome of a flush of the points, werr is the last write's error.
+func (o *Output) endFlush(start time.Time, points int, failed bool, werr error) error {
+	o.recordFlushOutcome(failed)
+	d := o.clock.Now().Sub(start)
+	o.stats.recordFlush(d)
+	o.tuneConcurrency(d, failed)
+	if werr != nil {
+		return werr
+	}
+	o.logger.WithField("elapsed", d).Debug("Metrics points have been sent")
+	if pushInterval := time.Duration(o.pushInterval.Load()); d > pushInterval {
+		o.warnSlowFlush(d, points, pushInterval)
+	}
+	return nil
+}
+
+// orgBatches returns the points of the samples, grouped by the organization they are written to.
+func (o *Output) orgBatches(samples []metrics.SampleContainer) []orgBatch {
+	groups := o.groupSamplesByOrg(samples)
+	batches := make([]orgBatch, 0, len(groups))
+	for _, g := range groups {
+		batch := o.batchFromSamples(g.samples)
+		if o.config.DisambiguateTimestamps.Bool {
+			// the precision has been already validated by New
+			precision, _ := o.config.writePrecision()
+			if moved := disambiguateTimestamps(batch, precision); moved > 0 {
+				o.logger.WithField("points", moved).Debug("The timestamps of duplicated points have been moved forward")
+			}
+		}
+		if o.config.SortByTime.Bool {
+			// stable, so the points with the same timestamp keep the samples' order
+			sort.SliceStable(batch, func(i, j int) bool {
+				return batch[i].Time().Before(batch[j].Time())
+			})
+		}
+		batches = append(batches, orgBatch{org: g.org, points: batch})
+	}
+	return batches
+}
+
+// warnSlowFlush logs the flush operation that took longer than the push interval,
+// with the settings that would make the observed write rate sustainable.
+func (o *Output) warnSlowFlush(d time.Duration, points int, pushInterval time.Duration) {
+	concurrentWrites := o.writeSlots.limit()
+	interval, concurrency := suggestFlushSettings(d, pushInterval)
+	suggestions := []string{fmt.Sprintf("PushInterval to %s", interval)}
+	if concurrency > concurrentWrites {
+		suggestions = append(suggestions, fmt.Sprintf("ConcurrentWrites to %d", concurrency))
+	}
+	msg := "The flush operation took higher than the expected set push interval. " +
+		"If you see this message multiple times then the setup or configuration " +
+		"need to be adjusted to achieve a sustainable rate: consider increasing " +
+		strings.Join(suggestions, " or ") + "."
+	o.logger.WithFields(logrus.Fields{
+		"t":                 d,
+		"points":            points,
+		"write_rate":        fmt.Sprintf("%.0f points/s", float64(points)/d.Seconds()),
+		"push_interval":     pushInterval,
+		"concurrent_writes": concurrentWrites,
+	}).Warn(msg)
+}
+
+// suggestFlushSettings returns the push interval and the number of concurrent writes
+// that would sustain the write rate observed by a flush of duration d.
+// A flush writes the samples collected in a push interval, so the writes keep up
+// when a flush completes in a push interval or when enough flushes run concurrently.
+func suggestFlushSettings(d, pushInterval time.Duration) (time.Duration, int) {
+	// rounded up to the next second, so a flush completes before the next one
+	interval := d.Truncate(time.Second)
+	if interval < d {
+		interval += time.Second
+	}
+	// the concurrent flushes required for sustaining the rate, plus one for the spikes
+	concurrency := int(math.Ceil(float64(d)/float64(pushInterval))) + 1
+	return interval, concurrency
+}
+
+// startAsyncWriter creates the non-blocking writer and starts
+// the goroutine logging its errors, it ends when the client is closed.
+func (o *Output) startAsyncWriter() {
+	o.asyncWriter = o.client.WriteAPI(o.config.Organization.String, o.config.Bucket.String)
+	// Errors must be called before any write for collecting all the errors
+	errCh := o.asyncWriter.Errors()
+	o.asyncErrorsDone = make(chan struct{})
+	go func() {
+		defer close(o.asyncErrorsDone)
+		for err := range errCh {
+			o.logWriteError(err, logrus.Fields{"async": true}, o.partialWriteFields(err, nil))
+			o.notifyWriteError(err, 0)
 		}
 	}()
 }
 
+// waitRateLimit blocks until the configured MaxPointsPerSecond allows to write the points.
+// The points are never dropped, the samples are kept in the buffer in the meantime.
+// It returns an error only when the output is stopped.
+func (o *Output) waitRateLimit(points int) error {
+	if o.limiter == nil {
+		return nil
+	}
+	// a batch can be larger than the limiter's burst,
+	// so the tokens are reserved in chunks
+	for points > 0 {
+		n := points
+		if burst := o.limiter.Burst(); n > burst {
+			n = burst
+		}
+		if err := o.limiter.WaitN(o.ctx, n); err != nil {
+			return err
+		}
+		points -= n
+	}
+	return nil
+}
+
 // MakeFieldKinds reads the Config and returns a lookup map of tag names to
 // the field type their values should be converted to.
 func makeFieldKinds(conf Config) (map[string]FieldKind, error) {
 	fieldKinds := make(map[string]FieldKind)
 	for _, tag := range conf.TagsAsFields {
-		var fieldName, fieldType string
-		s := strings.SplitN(tag, ":", 2)
-		if len(s) == 1 {
-			fieldName, fieldType = s[0], "string"
-		} else {
-			fieldName, fieldType = s[0], s[1]
-		}
+		fieldName, fieldType, _, _ := parseTagAsField(tag)
 
 		err := checkDuplicatedTypeDefinitions(fieldKinds, fieldName)
 		if err != nil {
@@ -257,6 +1772,151 @@ func makeFieldKinds(conf Config) (map[string]FieldKind, error) {
 	return fieldKinds, nil
 }
 
+// makeFieldMetrics returns the metrics of the conditional TagsAsFields rules,
+// the tags without a condition are extracted for all the metrics.
+func makeFieldMetrics(conf Config) (map[string]map[string]struct{}, error) {
+	fieldMetrics := make(map[string]map[string]struct{})
+	for _, tag := range conf.TagsAsFields {
+		fieldName, _, _, metricNames := parseTagAsField(tag)
+		if metricNames == nil {
+			continue
+		}
+		set := make(map[string]struct{}, len(metricNames))
+		for _, name := range metricNames {
+			if name == "" {
+				return nil, fmt.Errorf("an empty metric's name is specified for an InfluxDB field (%s)", fieldName)
+			}
+			set[name] = struct{}{}
+		}
+		fieldMetrics[fieldName] = set
+	}
+	return fieldMetrics, nil
+}
+
+// makeFieldKeys returns the keys of the fields renamed by the TagsAsFields rules,
+// the other tags are extracted to the fields with their names.
+func makeFieldKeys(conf Config) (map[string]string, error) {
+	fieldKeys := make(map[string]string)
+	tags := make(map[string]string, len(conf.TagsAsFields))
+	for _, rule := range conf.TagsAsFields {
+		if strings.HasSuffix(strings.SplitN(rule, "@", 2)[0], ">") {
+			return nil, fmt.Errorf("an empty field's key is specified for an InfluxDB field (%s)", rule)
+		}
+		fieldName, _, fieldKey, _ := parseTagAsField(rule)
+		if tag, ok := tags[fieldKey]; ok {
+			return nil, fmt.Errorf("the InfluxDB field (%s) is set by both the %s and the %s tags",
+				fieldKey, tag, fieldName)
+		}
+		tags[fieldKey] = fieldName
+		if fieldKey != fieldName {
+			fieldKeys[fieldName] = fieldKey
+		}
+	}
+	return fieldKeys, nil
+}
+
+// fieldKey returns the key of the field extracted from the tag.
+func (o *Output) fieldKey(tag string) string {
+	if key, ok := o.fieldKeys[tag]; ok {
+		return key
+	}
+	return tag
+}
+
+// parseTagAsField parses a TagsAsFields rule in the name[:type][>field][@metric1|metric2] form,
+// the type is string if it isn't set, the field's key is the tag's name if it isn't renamed
+// and the metrics are nil without the condition.
+func parseTagAsField(rule string) (string, string, string, []string) {
+	var metricNames []string
+	if i := strings.LastIndex(rule, "@"); i >= 0 {
+		metricNames = strings.Split(rule[i+1:], "|")
+		rule = rule[:i]
+	}
+	fieldKey := ""
+	if i := strings.Index(rule, ">"); i >= 0 {
+		fieldKey = rule[i+1:]
+		rule = rule[:i]
+	}
+	fieldName, fieldType := rule, "string"
+	if s := strings.SplitN(rule, ":", 2); len(s) == 2 {
+		fieldName, fieldType = s[0], s[1]
+	}
+	if fieldKey == "" {
+		fieldKey = fieldName
+	}
+	return fieldName, fieldType, fieldKey, metricNames
+}
+
+// makeMetricTypeSet returns a lookup set from a list of metric type names.
+func makeMetricTypeSet(names []string) (map[metrics.MetricType]struct{}, error) {
+	set := make(map[metrics.MetricType]struct{}, len(names))
+	for _, name := range names {
+		t, err := parseMetricType(name)
+		if err != nil {
+			return nil, err
+		}
+		set[t] = struct{}{}
+	}
+	return set, nil
+}
+
+// parseMetricType returns the metric type with the name, it is case-insensitive.
+func parseMetricType(name string) (metrics.MetricType, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "counter":
+		return metrics.Counter, nil
+	case "gauge":
+		return metrics.Gauge, nil
+	case "trend":
+		return metrics.Trend, nil
+	case "rate":
+		return metrics.Rate, nil
+	default:
+		return 0, fmt.Errorf("an invalid metric type (%s) is specified, "+
+			"the allowed values are: counter, gauge, trend and rate", name)
+	}
+}
+
+// makeValueFields returns the keys of the value field of the metric types set by ValueFieldByType.
+// A field set by a tag of TagsAsFields can't be reused, as its type could be different.
+func makeValueFields(conf Config) (map[metrics.MetricType]string, error) {
+	fields := make(map[metrics.MetricType]string, len(conf.ValueFieldByType))
+	if len(conf.ValueFieldByType) == 0 {
+		return fields, nil
+	}
+	tagFields := make(map[string]struct{}, len(conf.TagsAsFields))
+	for _, rule := range conf.TagsAsFields {
+		_, _, fieldKey, _ := parseTagAsField(rule)
+		tagFields[fieldKey] = struct{}{}
+	}
+	for name, field := range conf.ValueFieldByType {
+		t, err := parseMetricType(name)
+		if err != nil {
+			return nil, fmt.Errorf("ValueFieldByType: %w", err)
+		}
+		if field == "" {
+			return nil, fmt.Errorf("an empty value field is specified for the %s metric type in ValueFieldByType", name)
+		}
+		if _, ok := tagFields[field]; ok {
+			return nil, fmt.Errorf("the value field (%s) of the %s metric type is already set by TagsAsFields", field, name)
+		}
+		if field == checkPassedField {
+			return nil, fmt.Errorf("the value field (%s) of the %s metric type is reserved", field, name)
+		}
+		fields[t] = field
+	}
+	return fields, nil
+}
+
+// makeTagSet returns a lookup set from a list of tag names.
+func makeTagSet(tags []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		set[tag] = struct{}{}
+	}
+	return set
+}
+
 func checkDuplicatedTypeDefinitions(fieldKinds map[string]FieldKind, tag string) error {
 	if _, found := fieldKinds[tag]; found {
 		return fmt.Errorf("a tag name (%s) shows up more than once in InfluxDB field type configurations", tag)
@@ -0,0 +1,83 @@
+package influxdb
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRejectedLinesLogged is the maximum number of rejected points logged for a partial write.
+const maxRejectedLinesLogged = 3
+
+var (
+	// v1.x and Enterprise report the number of the rejected points, e.g.
+	// "partial write: field type conflict: ... dropped=1"
+	partialWriteDropped = regexp.MustCompile(`dropped=(\d+)`)
+	// v2.x reports the rejected lines, e.g.
+	// "partial write has occurred, errors encountered on line(s): line 2: ..."
+	partialWriteLine = regexp.MustCompile(`line (\d+):`)
+)
+
+// partialWrite is a write where the server has accepted
+// only a part of the points, rejecting the others.
+type partialWrite struct {
+	// dropped is the number of the rejected points
+	dropped int
+	// lines are the 1-based positions in the batch of the rejected points, when they are reported
+	lines []int
+}
+
+// parsePartialWrite reports if the error is a partial write and its details.
+func parsePartialWrite(err error) (partialWrite, bool) {
+	var serr *http2.Error
+	if !errors.As(err, &serr) || !strings.Contains(serr.Message, "partial write") {
+		return partialWrite{}, false
+	}
+
+	pw := partialWrite{}
+	for _, m := range partialWriteLine.FindAllStringSubmatch(serr.Message, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			pw.lines = append(pw.lines, n)
+		}
+	}
+	pw.dropped = len(pw.lines)
+	if m := partialWriteDropped.FindStringSubmatch(serr.Message); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			pw.dropped = n
+		}
+	}
+	return pw, true
+}
+
+// partialWriteFields records the rejected points if the error is a partial write,
+// and it returns the log fields with their count and a sample of their lines.
+// The batch is used for the sample, it can be nil when it isn't available.
+func (o *Output) partialWriteFields(err error, batch []*write.Point) logrus.Fields {
+	pw, ok := parsePartialWrite(err)
+	if !ok {
+		return nil
+	}
+	o.stats.recordRejectedPoints(pw.dropped)
+
+	fields := logrus.Fields{"rejected": pw.dropped}
+	var lines []string
+	for _, n := range pw.lines {
+		if len(lines) == maxRejectedLinesLogged {
+			break
+		}
+		if n < 1 || n > len(batch) {
+			continue
+		}
+		line := write.PointToLineProtocol(batch[n-1], o.currentClient().Options().Precision())
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if len(lines) > 0 {
+		fields["rejected_lines"] = lines
+	}
+	return fields
+}
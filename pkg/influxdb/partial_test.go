@@ -0,0 +1,48 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePartialWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err        error
+		exp        partialWrite
+		expPartial bool
+	}{
+		"NotServerError": {
+			err: errors.New("partial write: connection reset"),
+		},
+		"NotPartial": {
+			err: &http2.Error{StatusCode: 400, Code: "invalid", Message: "unable to parse"},
+		},
+		"V1": {
+			err: &http2.Error{StatusCode: 400, Code: "invalid", Message: `partial write: field type conflict: ` +
+				`input field "value" on measurement "vus" is type float, already exists as type integer dropped=2`},
+			exp:        partialWrite{dropped: 2},
+			expPartial: true,
+		},
+		"V2": {
+			err: fmt.Errorf("write failed: %w", &http2.Error{StatusCode: 400, Code: "invalid", Message: `partial write has occurred, ` +
+				`errors encountered on line(s): line 2: no field values, line 5: invalid timestamp`}),
+			exp:        partialWrite{dropped: 2, lines: []int{2, 5}},
+			expPartial: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pw, ok := parsePartialWrite(tc.err)
+			assert.Equal(t, tc.expPartial, ok)
+			assert.Equal(t, tc.exp, pw)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package influxdb
+
+import (
+	"fmt"
+
+	"go.k6.io/k6/metrics"
+)
+
+// checkMeasurementFromTag returns an error if MeasurementFromTag is set
+// with SingleMeasurement, that has a single measurement, or without the tag of the metric's name.
+func checkMeasurementFromTag(c Config) error {
+	if c.MeasurementFromTag.String == "" {
+		return nil
+	}
+	if c.SingleMeasurement.Bool {
+		return fmt.Errorf("the MeasurementFromTag option isn't supported with SingleMeasurement")
+	}
+	if c.MetricNameTag.String == "" {
+		return fmt.Errorf("the MetricNameTag option can't be empty when MeasurementFromTag is set")
+	}
+	if c.MetricNameTag.String == c.MeasurementFromTag.String {
+		return fmt.Errorf("the MetricNameTag option can't be the same of MeasurementFromTag (%s)",
+			c.MetricNameTag.String)
+	}
+	return nil
+}
+
+// pointMeasurement returns the measurement and the tags of the sample's point. With MeasurementFromTag,
+// the measurement is the tag's value and the metric's name is moved to the MetricNameTag's tag,
+// the samples without the tag, or with an empty value, keep the metric's name as the measurement.
+// The tags are copied when they are changed, since they can be shared between the points.
+func (o *Output) pointMeasurement(sample metrics.Sample, tags map[string]string) (string, map[string]string) {
+	tag := o.config.MeasurementFromTag.String
+	if tag == "" {
+		return o.measurementName(sample.Metric.Name), tags
+	}
+	measurement, ok := sample.Tags.Get(tag)
+	if !ok || measurement == "" {
+		return o.measurementName(sample.Metric.Name), tags
+	}
+	pivoted := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		pivoted[k] = v
+	}
+	pivoted[o.config.MetricNameTag.String] = safeTagText(sample.Metric.Name)
+	return o.measurementName(measurement), pivoted
+}
@@ -0,0 +1,68 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+)
+
+// The classes of the errors returned by Preflight, they can be checked with errors.Is.
+var (
+	// ErrPreflightUnreachable is returned when InfluxDB can't be reached, e.g. the address is wrong.
+	ErrPreflightUnreachable = errors.New("InfluxDB isn't reachable")
+	// ErrPreflightUnauthorized is returned when the token is invalid or it can't write in the bucket.
+	ErrPreflightUnauthorized = errors.New("the token isn't authorized to write in the bucket")
+	// ErrPreflightNotFound is returned when the bucket or the organization don't exist.
+	ErrPreflightNotFound = errors.New("the bucket or the organization doesn't exist")
+)
+
+// Preflight checks that the configuration works before a test is started: InfluxDB is reachable,
+// the token is valid and the bucket is writable. It sends a write without any point, so nothing
+// is stored. The returned error wraps one of the ErrPreflight errors when its cause is known.
+// With the telegraf flavor it only checks the listener is reachable. It doesn't require Start.
+func (o *Output) Preflight(ctx context.Context) error {
+	var err error
+	if sw, ok := o.currentWriter().(*socketWriter); ok {
+		err = sw.dial(ctx)
+	} else {
+		err = o.currentWriter().WriteRecord(ctx, "")
+	}
+	if err != nil {
+		return preflightError(err)
+	}
+	o.logger.Debug("The preflight check has passed")
+	return nil
+}
+
+// dial checks the listener is reachable, a UDP address is only resolved.
+func (w *socketWriter) dial(ctx context.Context) error {
+	conn, err := w.dialer.DialContext(ctx, w.network, w.address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// preflightError adds the class of the Preflight's error.
+func preflightError(err error) error {
+	var serr *http2.Error
+	if errors.As(err, &serr) && serr.StatusCode != 0 {
+		switch serr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %w", ErrPreflightUnauthorized, err)
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %w", ErrPreflightNotFound, err)
+		default:
+			return fmt.Errorf("the preflight write has been rejected: %w", err)
+		}
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return fmt.Errorf("%w: %w", ErrPreflightUnreachable, err)
+	}
+	return fmt.Errorf("the preflight check failed: %w", err)
+}
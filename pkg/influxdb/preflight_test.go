@@ -0,0 +1,102 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputPreflight(t *testing.T) {
+	t.Parallel()
+
+	testdata := map[string]struct {
+		status int
+		err    error
+		msg    string
+	}{
+		"success":      {status: http.StatusNoContent},
+		"unauthorized": {status: http.StatusUnauthorized, err: ErrPreflightUnauthorized},
+		"forbidden":    {status: http.StatusForbidden, err: ErrPreflightUnauthorized},
+		"not found":    {status: http.StatusNotFound, err: ErrPreflightNotFound},
+		"rejected":     {status: http.StatusBadRequest, msg: "the preflight write has been rejected"},
+	}
+	for name, tc := range testdata {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var body []byte
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v2/write", r.URL.Path)
+				assert.Equal(t, "testbucket", r.URL.Query().Get("bucket"))
+				assert.Equal(t, "testorg", r.URL.Query().Get("org"))
+				body, _ = io.ReadAll(r.Body)
+				if tc.status != http.StatusNoContent {
+					rw.Header().Set("Content-Type", "application/json")
+					rw.WriteHeader(tc.status)
+					_, _ = rw.Write([]byte(`{"code":"invalid","message":"preflight"}`))
+					return
+				}
+				rw.WriteHeader(tc.status)
+			}))
+			defer ts.Close()
+
+			logger, _ := logtest.NewNullLogger()
+			o, err := New(output.Params{
+				Logger:         logger,
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     json.RawMessage(`{"token":"secret","organization":"testorg"}`),
+			})
+			require.NoError(t, err)
+
+			err = o.Preflight(context.Background())
+			assert.Empty(t, body, "no point is expected to be written")
+			switch {
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			case tc.msg != "":
+				assert.ErrorContains(t, err, tc.msg)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOutputPreflightUnreachable(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.NotFoundHandler())
+	addr := ts.URL
+	ts.Close()
+
+	logger, _ := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: addr + "/testbucket",
+		JSONConfig:     json.RawMessage(`{}`),
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, o.Preflight(context.Background()), ErrPreflightUnreachable)
+}
+
+func TestOutputPreflightTelegraf(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	o := newTelegrafOutput(t, "tcp://"+ln.Addr().String())
+	assert.NoError(t, o.Preflight(context.Background()))
+
+	require.NoError(t, ln.Close())
+	assert.ErrorIs(t, o.Preflight(context.Background()), ErrPreflightUnreachable)
+}
@@ -0,0 +1,83 @@
+package influxdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/lib/types"
+	"gopkg.in/guregu/null.v3"
+)
+
+// The write profiles, presets of the write options for the common needs.
+const (
+	WriteProfileLowLatency     = "low-latency"
+	WriteProfileHighThroughput = "high-throughput"
+	WriteProfileLowBandwidth   = "low-bandwidth"
+)
+
+// writeProfile contains the values set by a profile.
+type writeProfile struct {
+	pushInterval     time.Duration
+	concurrentWrites int64
+	maxBatchSize     int64
+	gzip             bool
+}
+
+var writeProfiles = map[string]writeProfile{ //nolint:gochecknoglobals // it is read-only
+	// frequent and small writes, without the compression's overhead
+	WriteProfileLowLatency: {
+		pushInterval:     500 * time.Millisecond,
+		concurrentWrites: 8,
+		maxBatchSize:     1000,
+		gzip:             false,
+	},
+	// large compressed writes, with more of them in flight
+	WriteProfileHighThroughput: {
+		pushInterval:     5 * time.Second,
+		concurrentWrites: 8,
+		maxBatchSize:     10000,
+		gzip:             true,
+	},
+	// few compressed writes
+	WriteProfileLowBandwidth: {
+		pushInterval:     10 * time.Second,
+		concurrentWrites: 2,
+		maxBatchSize:     5000,
+		gzip:             true,
+	},
+}
+
+// applyWriteProfile sets the options of the configured profile,
+// the options set explicitly are not overridden.
+func applyWriteProfile(c Config) (Config, error) {
+	if c.WriteProfile.String == "" {
+		return c, nil
+	}
+	p, ok := writeProfiles[c.WriteProfile.String]
+	if !ok {
+		names := make([]string, 0, len(writeProfiles))
+		for name := range writeProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return c, fmt.Errorf("an invalid write profile (%s) is specified, the allowed values are: %s",
+			c.WriteProfile.String, strings.Join(names, ", "))
+	}
+
+	// the profile's values aren't valid, as the defaults, so they are reported as not explicitly set
+	if !c.PushInterval.Valid {
+		c.PushInterval = types.NewNullDuration(p.pushInterval, false)
+	}
+	if !c.ConcurrentWrites.Valid {
+		c.ConcurrentWrites = null.NewInt(p.concurrentWrites, false)
+	}
+	if !c.MaxBatchSize.Valid {
+		c.MaxBatchSize = null.NewInt(p.maxBatchSize, false)
+	}
+	if !c.Gzip.Valid {
+		c.Gzip = null.NewBool(p.gzip, false)
+	}
+	return c, nil
+}
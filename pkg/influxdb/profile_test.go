@@ -0,0 +1,260 @@
+package influxdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestWriteProfiles(t *testing.T) {
+	t.Parallel()
+
+	type effective struct {
+		pushInterval     time.Duration
+		concurrentWrites int64
+		maxBatchSize     int64
+		gzip             bool
+	}
+	tests := map[string]effective{
+		"":                         {pushInterval: time.Second, concurrentWrites: 4, maxBatchSize: 0, gzip: false},
+		WriteProfileLowLatency:     {pushInterval: 500 * time.Millisecond, concurrentWrites: 8, maxBatchSize: 1000, gzip: false},
+		WriteProfileHighThroughput: {pushInterval: 5 * time.Second, concurrentWrites: 8, maxBatchSize: 10000, gzip: true},
+		WriteProfileLowBandwidth:   {pushInterval: 10 * time.Second, concurrentWrites: 2, maxBatchSize: 5000, gzip: true},
+	}
+	for profile, exp := range tests {
+		profile, exp := profile, exp
+		t.Run(profile, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := GetConsolidatedConfig(nil, map[string]string{"K6_INFLUXDB_WRITE_PROFILE": profile}, "")
+			require.NoError(t, err)
+			assert.Equal(t, exp, effective{
+				pushInterval:     time.Duration(c.PushInterval.Duration),
+				concurrentWrites: c.ConcurrentWrites.Int64,
+				maxBatchSize:     c.MaxBatchSize.Int64,
+				gzip:             c.Gzip.Bool,
+			})
+		})
+	}
+}
+
+func TestWriteProfileExplicitOverrides(t *testing.T) {
+	t.Parallel()
+
+	c, err := GetConsolidatedConfig(
+		json.RawMessage(`{"writeProfile":"high-throughput","concurrentWrites":1,"gzip":false}`),
+		map[string]string{"K6_INFLUXDB_PUSH_INTERVAL": "2s"},
+		"",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), c.ConcurrentWrites.Int64)
+	assert.False(t, c.Gzip.Bool)
+	assert.Equal(t, 2*time.Second, time.Duration(c.PushInterval.Duration))
+	// not overridden
+	assert.Equal(t, int64(10000), c.MaxBatchSize.Int64)
+}
+
+func TestWriteProfileInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := GetConsolidatedConfig(nil, map[string]string{"K6_INFLUXDB_WRITE_PROFILE": "fast"}, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "high-throughput, low-bandwidth, low-latency")
+}
+
+func TestSplitBatch(t *testing.T) {
+	t.Parallel()
+
+	batch := make([]*write.Point, 5)
+	sizes := func(chunks [][]*write.Point) []int {
+		s := make([]int, 0, len(chunks))
+		for _, c := range chunks {
+			s = append(s, len(c))
+		}
+		return s
+	}
+	assert.Equal(t, []int{5}, sizes(splitBatch(batch, 0)))
+	assert.Equal(t, []int{5}, sizes(splitBatch(batch, 5)))
+	assert.Equal(t, []int{2, 2, 1}, sizes(splitBatch(batch, 2)))
+	assert.Equal(t, []int{1, 1, 1, 1, 1}, sizes(splitBatch(batch, 1)))
+}
+
+func TestOutputMaxBatchSizeGzip(t *testing.T) {
+	t.Parallel()
+
+	for _, flavor := range []string{FlavorV2, FlavorV3} {
+		flavor := flavor
+		t.Run(flavor, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu       sync.Mutex
+				requests []int
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Encoding") != "gzip" {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				b, err := io.ReadAll(zr)
+				if err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				mu.Lock()
+				requests = append(requests, strings.Count(string(b), "\n"))
+				mu.Unlock()
+				rw.WriteHeader(http.StatusNoContent)
+			}))
+			t.Cleanup(ts.Close)
+
+			o, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     json.RawMessage(`{"maxBatchSize":2,"gzip":true,"flavor":"` + flavor + `"}`),
+			})
+			require.NoError(t, err)
+			o.ctx = context.Background()
+
+			registry := metrics.NewRegistry()
+			metric, err := registry.NewMetric("test_counter", metrics.Counter)
+			require.NoError(t, err)
+			samples := make(metrics.Samples, 0, 5)
+			for i := 0; i < 5; i++ {
+				samples = append(samples, metrics.Sample{
+					TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+					Time:       time.Unix(int64(i), 0),
+					Value:      1,
+				})
+			}
+			require.NoError(t, o.writeSamples([]metrics.SampleContainer{samples}))
+			assert.Equal(t, []int{2, 2, 1}, requests)
+		})
+	}
+}
+
+func TestSplitBatchBytes(t *testing.T) {
+	t.Parallel()
+
+	// the lines are 23, 34, 124 and 23 bytes long, the new line included
+	batch := []*write.Point{
+		write.NewPoint("test_counter", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		write.NewPoint("test_counter", map[string]string{"name": "abcde"},
+			map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+		write.NewPoint("test_counter", map[string]string{"name": strings.Repeat("a", 95)},
+			map[string]interface{}{"value": 3.0}, time.Unix(3, 0)),
+		write.NewPoint("test_counter", nil, map[string]interface{}{"value": 4.0}, time.Unix(4, 0)),
+	}
+	lineSize := func(p *write.Point) int {
+		lines, err := encodeLines([]*write.Point{p}, time.Second)
+		require.NoError(t, err)
+		return len(lines[0])
+	}
+	require.Equal(t, []int{23, 34, 124, 23}, []int{
+		lineSize(batch[0]), lineSize(batch[1]), lineSize(batch[2]), lineSize(batch[3]),
+	})
+
+	tests := []struct {
+		maxBytes int
+		exp      []int
+	}{
+		{maxBytes: 1000, exp: []int{4}},
+		{maxBytes: 57, exp: []int{2, 1, 1}},
+		{maxBytes: 56, exp: []int{1, 1, 1, 1}},
+		// the longer point is sent alone
+		{maxBytes: 100, exp: []int{2, 1, 1}},
+	}
+	for _, tc := range tests {
+		chunks := splitBatchBytes(batch, tc.maxBytes, time.Second)
+		sizes := make([]int, 0, len(chunks))
+		for _, chunk := range chunks {
+			sizes = append(sizes, len(chunk))
+			lines, err := encodeLines(chunk, time.Second)
+			require.NoError(t, err)
+			if len(chunk) > 1 {
+				assert.LessOrEqual(t, len(bytes.Join(lines, nil)), tc.maxBytes)
+			}
+		}
+		assert.Equal(t, tc.exp, sizes, "maxBytes %d", tc.maxBytes)
+	}
+}
+
+func TestOutputMaxBatchBytes(t *testing.T) {
+	t.Parallel()
+
+	const maxBytes = 300
+	var (
+		mu       sync.Mutex
+		requests []int
+		lines    int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, len(b))
+		lines += strings.Count(string(b), "\n")
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"maxBatchBytes":300,"maxBatchSize":8,"gzip":true}`),
+	})
+	require.NoError(t, err)
+	o.ctx = context.Background()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	samples := make(metrics.Samples, 0, 30)
+	for i := 0; i < 30; i++ {
+		// the points have different sizes
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: metric,
+				Tags:   registry.RootTagSet().With("name", strings.Repeat("a", i*3)),
+			},
+			Time:  time.Unix(int64(i), 0),
+			Value: 1,
+		})
+	}
+	require.NoError(t, o.writeSamples([]metrics.SampleContainer{samples}))
+
+	assert.Equal(t, 30, lines)
+	assert.Greater(t, len(requests), 30/8+1)
+	for _, size := range requests {
+		assert.LessOrEqual(t, size, maxBytes)
+	}
+}
@@ -0,0 +1,82 @@
+package influxdb
+
+import (
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// currentClient returns the client, that is replaced when it is rebuilt.
+func (o *Output) currentClient() influxdbclient.Client {
+	o.clientMu.RLock()
+	defer o.clientMu.RUnlock()
+	return o.client
+}
+
+// currentWriter returns the synchronous writer, that is replaced when the client is rebuilt.
+func (o *Output) currentWriter() pointsWriter {
+	o.clientMu.RLock()
+	defer o.clientMu.RUnlock()
+	return o.pointWriter
+}
+
+// recordFlushOutcome counts the consecutive flushes whose writes have all failed,
+// a flush with at least a successful write resets the count.
+func (o *Output) recordFlushOutcome(failed bool) {
+	if o.config.ReconnectAfterFailures.Int64 <= 0 {
+		return
+	}
+	if !failed {
+		o.failedFlushes.Store(0)
+		return
+	}
+	o.failedFlushes.Add(1)
+}
+
+// reconnectIfFailing rebuilds the client and the writer, with a new connections' pool,
+// when the consecutive failed flushes reach ReconnectAfterFailures. A client can stay broken
+// after a transient failure, e.g. of the DNS. The rebuilds are at most one per ReconnectCooldown,
+// so a down InfluxDB doesn't cause a rebuild for each flush.
+func (o *Output) reconnectIfFailing() {
+	threshold := o.config.ReconnectAfterFailures.Int64
+	if threshold <= 0 || o.failedFlushes.Load() < threshold {
+		return
+	}
+
+	o.clientMu.Lock()
+	defer o.clientMu.Unlock()
+	now := o.clock.Now()
+	cooldown := time.Duration(o.config.ReconnectCooldown.Duration)
+	if !o.lastReconnect.IsZero() && now.Sub(o.lastReconnect) < cooldown {
+		return
+	}
+	// the config has been already validated by New, so they don't fail
+	opts, err := newClientOptions(o.ctx, o.config)
+	if err != nil {
+		o.logger.WithError(err).Warn("The InfluxDB client can't be rebuilt")
+		return
+	}
+	cl := influxdbclient.NewClientWithOptions(serverURL(o.config), o.config.Token.String, opts)
+	pw, err := newPointsWriter(o.config, cl, opts)
+	if err != nil {
+		cl.Close()
+		o.logger.WithError(err).Warn("The InfluxDB client can't be rebuilt")
+		return
+	}
+	mirrors, err := newMirrorWriters(o.config, cl, opts)
+	if err != nil {
+		cl.Close()
+		o.logger.WithError(err).Warn("The InfluxDB client can't be rebuilt")
+		return
+	}
+
+	failures := o.failedFlushes.Swap(0)
+	// the in-flight writes complete with the old client, only its idle connections are closed
+	o.client.Close()
+	o.client, o.pointWriter, o.mirrors = cl, pw, mirrors
+	// they are created again with the new client
+	o.orgWriters = nil
+	o.lastReconnect = now
+	o.stats.recordReconnect()
+	o.logger.WithField("failures", failures).Warn("The InfluxDB client has been rebuilt after consecutive failed flushes")
+}
@@ -0,0 +1,72 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputReconnect(t *testing.T) {
+	t.Parallel()
+
+	const failures = 4
+	var requests int64
+	lc := &lineCollector{}
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) <= failures {
+			_, _ = io.Copy(io.Discard, r.Body)
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		lc.ServeHTTP(rw, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		// the periodic flushes don't happen during the test
+		JSONConfig: json.RawMessage(`{"pushInterval":"1h","reconnectAfterFailures":2,"reconnectCooldown":"1h"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+	t.Cleanup(func() { require.NoError(t, o.Stop()) })
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	flush := func(i int) {
+		o.AddMetricSamples([]metrics.SampleContainer{metrics.Samples{{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+			Time:       time.Unix(int64(i), 0),
+			Value:      1,
+		}}})
+		o.flushMetrics()
+		o.wg.Wait()
+	}
+
+	client := o.currentClient()
+	// the third flush rebuilds the client after two failed flushes, the fifth one
+	// doesn't rebuild it again because of the cooldown, but InfluxDB has recovered
+	for i := 1; i <= failures+1; i++ {
+		flush(i)
+	}
+	assert.True(t, client != o.currentClient(), "the client hasn't been rebuilt")
+	assert.Equal(t, 1, o.Stats().Reconnects)
+	assert.Equal(t, []string{"test_counter value=1 5000000000"}, lc.Lines())
+
+	flush(failures + 2)
+	assert.Equal(t, 1, o.Stats().Reconnects)
+	assert.Equal(t, int64(0), o.failedFlushes.Load())
+	assert.Len(t, lc.Lines(), 2)
+}
@@ -0,0 +1,209 @@
+package influxdb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"go.k6.io/k6/lib/types"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	// replayBatchSize is the number of the lines written by a request
+	// when the MaxBatchSize option isn't set.
+	replayBatchSize = 5000
+	// replayMaxAttempts is the number of the attempts to write a batch
+	// when InfluxDB requests to retry later.
+	replayMaxAttempts = 5
+	// replayMaxLineSize is the maximum length of a line of the replayed file.
+	replayMaxLineSize = 1024 * 1024
+)
+
+// precisionMarkerPrefix starts the comment line declaring the precision of the following lines' timestamps.
+const precisionMarkerPrefix = "# precision="
+
+// precisionMarker returns the marker line of the precision, named as the write API does.
+func precisionMarker(precision time.Duration) string {
+	unit := "ns"
+	for name, p := range precisionUnits {
+		if p == precision {
+			unit = name
+		}
+	}
+	return precisionMarkerPrefix + unit + "\n"
+}
+
+// ReplayFile writes the line protocol of the file, e.g. a dead letter file or a gzipped file,
+// to the destination of the config, with the same writer used by the output.
+// The config is consolidated with the defaults and the write profile,
+// the empty lines and the comments are skipped.
+// A precision marker, e.g. "# precision=s", sets the precision of the following lines,
+// the config's precision is used for the lines before the first marker.
+// The lines are written in batches of MaxBatchSize and MaxBatchBytes, a batch is retried
+// when InfluxDB requests to retry later, waiting the Retry-After capped by MaxRetryAfter.
+func ReplayFile(ctx context.Context, cfg Config, path string) error {
+	conf, err := applyWriteProfile(NewConfig().Apply(cfg))
+	if err != nil {
+		return err
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	precision, err := conf.writePrecision()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path) //nolint:forbidigo // the file is outside of the k6's file system
+	if err != nil {
+		return fmt.Errorf("the replayed file can't be opened: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	r, err := replayReader(f)
+	if err != nil {
+		return err
+	}
+
+	rp := &replayer{
+		conf:     conf,
+		writers:  make(map[time.Duration]pointsWriter),
+		backoff:  &writeBackoff{clock: realClock{}, max: time.Duration(conf.MaxRetryAfter.Duration)},
+		size:     int(conf.MaxBatchSize.Int64),
+		maxBytes: int(conf.MaxBatchBytes.Int64),
+	}
+	defer rp.close()
+	if rp.size == 0 {
+		rp.size = replayBatchSize
+	}
+	if err := rp.setPrecision(ctx, precision); err != nil {
+		return err
+	}
+	return rp.replay(ctx, r)
+}
+
+// replayer writes the lines of a replayed file in batches, with a writer for each precision of the lines.
+type replayer struct {
+	conf     Config
+	writers  map[time.Duration]pointsWriter
+	clients  []influxdbclient.Client
+	backoff  *writeBackoff
+	size     int
+	maxBytes int
+
+	pw                      pointsWriter
+	lines                   []string
+	first, last, batchBytes int
+}
+
+// setPrecision sets the writer of the following lines, the writer of a new precision
+// is created with the config of the replay and the precision.
+func (rp *replayer) setPrecision(ctx context.Context, precision time.Duration) error {
+	if pw, ok := rp.writers[precision]; ok {
+		rp.pw = pw
+		return nil
+	}
+	conf := rp.conf
+	conf.Precision, conf.PrecisionUnit = types.NullDurationFrom(precision), null.String{}
+	opts, err := newClientOptions(ctx, conf)
+	if err != nil {
+		return err
+	}
+	cl := influxdbclient.NewClientWithOptions(serverURL(conf), conf.Token.String, opts)
+	rp.clients = append(rp.clients, cl)
+	pw, err := newPointsWriter(conf, cl, opts)
+	if err != nil {
+		return err
+	}
+	rp.writers[precision] = pw
+	rp.pw = pw
+	return nil
+}
+
+func (rp *replayer) close() {
+	for _, cl := range rp.clients {
+		cl.Close()
+	}
+}
+
+func (rp *replayer) replay(ctx context.Context, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, replayMaxLineSize)
+	rp.lines = make([]string, 0, rp.size)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if unit, ok := strings.CutPrefix(line, precisionMarkerPrefix); ok {
+			precision, err := parsePrecisionUnit(unit)
+			if err != nil || precision == 0 {
+				return fmt.Errorf("the line %d has an invalid precision marker (%s)", n, line)
+			}
+			// the buffered lines are written with their own precision
+			if err := rp.flush(ctx); err != nil {
+				return err
+			}
+			if err := rp.setPrecision(ctx, precision); err != nil {
+				return err
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// the line plus its new line
+		if rp.maxBytes > 0 && rp.batchBytes+len(line)+1 > rp.maxBytes {
+			if err := rp.flush(ctx); err != nil {
+				return err
+			}
+		}
+		if len(rp.lines) == 0 {
+			rp.first = n
+		}
+		rp.lines = append(rp.lines, line)
+		rp.batchBytes += len(line) + 1
+		rp.last = n
+		if len(rp.lines) == rp.size {
+			if err := rp.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("the replayed file can't be read: %w", err)
+	}
+	return rp.flush(ctx)
+}
+
+func (rp *replayer) flush(ctx context.Context) error {
+	if len(rp.lines) == 0 {
+		return nil
+	}
+	if err := replayLines(ctx, rp.pw, rp.backoff, rp.lines); err != nil {
+		return fmt.Errorf("the lines %d-%d can't be replayed: %w", rp.first, rp.last, err)
+	}
+	rp.lines, rp.batchBytes = rp.lines[:0], 0
+	return nil
+}
+
+// replayLines writes the lines, retrying them when InfluxDB requests to retry later.
+func replayLines(ctx context.Context, pw pointsWriter, backoff *writeBackoff, lines []string) error {
+	for attempt := 1; ; attempt++ {
+		if err := backoff.wait(ctx); err != nil {
+			return err
+		}
+		err := pw.WriteRecord(ctx, lines...)
+		if err == nil {
+			return nil
+		}
+		if attempt == replayMaxAttempts || errors.Is(err, context.Canceled) || backoff.record(err) == 0 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package influxdb
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/types"
+	"gopkg.in/guregu/null.v3"
+)
+
+const replayedFile = `# dead-lettered points
+test_counter value=1 1000000000
+
+test_counter value=2 2000000000
+test_counter value=3 3000000000
+`
+
+func writeReplayedFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "dead-letter.lp")
+	require.NoError(t, os.WriteFile(path, []byte(replayedFile), 0o600)) //nolint:forbidigo
+	return path
+}
+
+func TestReplayFile(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	t.Cleanup(ts.Close)
+
+	err := ReplayFile(context.Background(), Config{
+		Addr:         null.StringFrom(ts.URL),
+		Bucket:       null.StringFrom("testbucket"),
+		MaxBatchSize: null.IntFrom(2),
+	}, writeReplayedFile(t))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"test_counter value=1 1000000000",
+		"test_counter value=2 2000000000",
+		"test_counter value=3 3000000000",
+	}, lc.Lines())
+}
+
+func TestReplayFileRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	var requests int64
+	lc := &lineCollector{}
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			_, _ = io.Copy(io.Discard, r.Body)
+			rw.Header().Set("Retry-After", "1")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		lc.ServeHTTP(rw, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	err := ReplayFile(context.Background(), Config{
+		Addr:          null.StringFrom(ts.URL),
+		Bucket:        null.StringFrom("testbucket"),
+		MaxRetryAfter: types.NullDurationFrom(10 * time.Millisecond),
+	}, writeReplayedFile(t))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+	assert.Len(t, lc.Lines(), 3)
+}
+
+func TestReplayFileError(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"code":"invalid","message":"field type conflict"}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	err := ReplayFile(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, writeReplayedFile(t))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the lines 2-5 can't be replayed")
+}
+
+// precisionCollector collects the body of each write prefixed by its precision.
+type precisionCollector struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (pc *precisionCollector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	pc.mu.Lock()
+	pc.writes = append(pc.writes, r.URL.Query().Get("precision")+": "+strings.TrimSpace(string(b)))
+	pc.mu.Unlock()
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (pc *precisionCollector) Writes() []string {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return append([]string(nil), pc.writes...)
+}
+
+func TestReplayFilePrecisionMarker(t *testing.T) {
+	t.Parallel()
+
+	pc := &precisionCollector{}
+	ts := httptest.NewServer(pc)
+	t.Cleanup(ts.Close)
+
+	// a file written with the seconds' precision, followed by the lines of a run with the default precision
+	path := filepath.Join(t.TempDir(), "dead-letter.lp")
+	require.NoError(t, os.WriteFile(path, []byte(`# precision=s
+test_counter value=1 1
+test_counter value=2 2
+# precision=ns
+test_counter value=3 3000000000
+`), 0o600)) //nolint:forbidigo
+
+	// the default config has the nanoseconds' precision
+	err := ReplayFile(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"s: test_counter value=1 1\ntest_counter value=2 2",
+		"ns: test_counter value=3 3000000000",
+	}, pc.Writes())
+
+	require.NoError(t, os.WriteFile(path, []byte("# precision=h\ntest_counter value=1 1\n"), 0o600)) //nolint:forbidigo
+	err = ReplayFile(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, path)
+	assert.ErrorContains(t, err, "the line 1 has an invalid precision marker (# precision=h)")
+}
@@ -0,0 +1,47 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"go.k6.io/k6/lib/consts"
+)
+
+// runMetadataFields returns the fields of the run's metadata: the k6's version, the script
+// and the resolved config, as JSON, with the token and the other secrets redacted.
+func (o *Output) runMetadataFields() (map[string]interface{}, error) {
+	conf, err := json.Marshal(o.config.Redacted())
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"k6_version": consts.Version,
+		"config":     string(conf),
+		"flavor":     o.config.Flavor.String,
+		"bucket":     o.config.Bucket.String,
+	}
+	if o.params.ScriptPath != nil {
+		// a remote script's URL can contain the credentials
+		fields["script"] = redactURL(o.params.ScriptPath.String())
+	}
+	return fields, nil
+}
+
+// writeRunMetadata writes immediately a point recording the run's config and the k6's version,
+// for auditing and reproducing the run. It is tagged as the lifecycle events,
+// a failed write is only logged, so it doesn't abort the test.
+func (o *Output) writeRunMetadata(ctx context.Context, t time.Time) {
+	fields, err := o.runMetadataFields()
+	if err != nil {
+		o.logger.WithError(err).Warn("Couldn't encode the run's metadata")
+		return
+	}
+	p := influxdbclient.NewPoint(o.config.RunMetadataMeasurement.String, o.runTags(), fields, t)
+	if err := o.currentWriter().WritePoint(ctx, p); err != nil {
+		o.logger.WithError(err).Warn("Couldn't write the run's metadata")
+		return
+	}
+	o.logger.Debug("The run's metadata has been written")
+}
@@ -0,0 +1,114 @@
+package influxdb
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	// TrendSamplingUniform writes each trend's sample with the TrendSamplingRate probability.
+	TrendSamplingUniform = "uniform"
+	// TrendSamplingReservoir writes at most TrendReservoirSize random samples
+	// for each trend's series in a flush, so the series with less samples are written entirely.
+	TrendSamplingReservoir = "reservoir"
+)
+
+// checkTrendSampling returns an error if the trends' sampling options aren't valid.
+func checkTrendSampling(conf Config) error {
+	switch conf.TrendSampling.String {
+	case "":
+		return nil
+	case TrendSamplingUniform:
+		if rate := conf.TrendSamplingRate.Float64; rate <= 0 || rate > 1 {
+			return fmt.Errorf("the TrendSamplingRate option must be in the (0, 1] range")
+		}
+		return nil
+	case TrendSamplingReservoir:
+		if conf.TrendReservoirSize.Int64 <= 0 {
+			return fmt.Errorf("the TrendReservoirSize option must be a positive number")
+		}
+		return nil
+	default:
+		return fmt.Errorf("an invalid trend sampling (%s) is specified, the allowed values are: %s and %s",
+			conf.TrendSampling.String, TrendSamplingUniform, TrendSamplingReservoir)
+	}
+}
+
+// sampleTrends returns the containers with only the trends' samples kept by the sampling,
+// the samples of the other metrics are always kept.
+func (o *Output) sampleTrends(containers []metrics.SampleContainer) []metrics.SampleContainer {
+	var samples []metrics.Sample
+	switch o.config.TrendSampling.String {
+	case TrendSamplingUniform:
+		samples = uniformTrendSamples(containers, o.config.TrendSamplingRate.Float64, rand.Float64) //nolint:gosec
+	case TrendSamplingReservoir:
+		samples = reservoirTrendSamples(containers, int(o.config.TrendReservoirSize.Int64), rand.Intn) //nolint:gosec
+	default:
+		return containers
+	}
+	return []metrics.SampleContainer{metrics.Samples(samples)}
+}
+
+// uniformTrendSamples keeps each trend's sample with the rate probability.
+func uniformTrendSamples(
+	containers []metrics.SampleContainer, rate float64, random func() float64,
+) []metrics.Sample {
+	var kept []metrics.Sample
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric.Type != metrics.Trend || random() < rate {
+				kept = append(kept, sample)
+			}
+		}
+	}
+	return kept
+}
+
+// reservoirTrendSamples keeps at most size samples for each trend's series,
+// chosen with the reservoir sampling, so each sample has the same probability to be kept.
+// The kept samples are in the containers' order.
+func reservoirTrendSamples(
+	containers []metrics.SampleContainer, size int, intn func(int) int,
+) []metrics.Sample {
+	type reservoir struct {
+		seen    int
+		indexes []int
+	}
+	var all []metrics.Sample
+	reservoirs := make(map[metrics.TimeSeries]*reservoir)
+	var keep []int
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			i := len(all)
+			all = append(all, sample)
+			if sample.Metric.Type != metrics.Trend {
+				keep = append(keep, i)
+				continue
+			}
+			r, ok := reservoirs[sample.TimeSeries]
+			if !ok {
+				r = &reservoir{}
+				reservoirs[sample.TimeSeries] = r
+			}
+			r.seen++
+			if len(r.indexes) < size {
+				r.indexes = append(r.indexes, i)
+			} else if j := intn(r.seen); j < size {
+				r.indexes[j] = i
+			}
+		}
+	}
+	for _, r := range reservoirs {
+		keep = append(keep, r.indexes...)
+	}
+	sort.Ints(keep)
+
+	kept := make([]metrics.Sample, 0, len(keep))
+	for _, i := range keep {
+		kept = append(kept, all[i])
+	}
+	return kept
+}
@@ -0,0 +1,167 @@
+package influxdb
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"gopkg.in/guregu/null.v3"
+)
+
+// percentile returns the nearest-rank percentile of the values.
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+}
+
+func TestTrendSamplingPercentileError(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	// a busy endpoint and a rarely called one, with log-normal durations
+	series := []metrics.TimeSeries{
+		{Metric: metric, Tags: registry.RootTagSet().With("name", "busy")},
+		{Metric: metric, Tags: registry.RootTagSet().With("name", "rare")},
+	}
+	counts := []int{20000, 60}
+
+	r := rand.New(rand.NewSource(1)) //nolint:gosec
+	values := make(map[metrics.TimeSeries][]float64)
+	var samples metrics.Samples
+	for i, ts := range series {
+		for j := 0; j < counts[i]; j++ {
+			v := math.Exp(4 + 0.5*r.NormFloat64())
+			values[ts] = append(values[ts], v)
+			samples = append(samples, metrics.Sample{TimeSeries: ts, Time: time.Unix(int64(j), 0), Value: v})
+		}
+	}
+	containers := []metrics.SampleContainer{samples}
+
+	// the sum of the p95 and p99 relative errors of all the series
+	percentileError := func(kept []metrics.Sample) float64 {
+		keptValues := make(map[metrics.TimeSeries][]float64)
+		for _, s := range kept {
+			keptValues[s.TimeSeries] = append(keptValues[s.TimeSeries], s.Value)
+		}
+		var sum float64
+		for _, ts := range series {
+			require.NotEmpty(t, keptValues[ts])
+			for _, p := range []float64{0.95, 0.99} {
+				exp := percentile(values[ts], p)
+				sum += math.Abs(percentile(keptValues[ts], p)-exp) / exp
+			}
+		}
+		return sum
+	}
+
+	// about the same number of written samples
+	uniform := uniformTrendSamples(containers, 0.05, r.Float64)
+	reservoir := reservoirTrendSamples(containers, 1000, r.Intn)
+	assert.InDelta(t, len(uniform), len(reservoir), 100)
+
+	uniformErr, reservoirErr := percentileError(uniform), percentileError(reservoir)
+	assert.Less(t, reservoirErr, uniformErr)
+	t.Logf("uniform error: %.3f, reservoir error: %.3f", uniformErr, reservoirErr)
+}
+
+func TestReservoirTrendSamples(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	trend, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	counter, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+
+	var samples metrics.Samples
+	for i := 0; i < 100; i++ {
+		samples = append(samples,
+			metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: trend, Tags: registry.RootTagSet()},
+				Time:       time.Unix(int64(i), 0),
+				Value:      float64(i),
+			},
+			metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: counter, Tags: registry.RootTagSet()},
+				Time:       time.Unix(int64(i), 0),
+				Value:      1,
+			})
+	}
+	r := rand.New(rand.NewSource(1)) //nolint:gosec
+	kept := reservoirTrendSamples([]metrics.SampleContainer{samples}, 10, r.Intn)
+
+	var trends, counters int
+	for i, s := range kept {
+		if s.Metric == trend {
+			trends++
+		} else {
+			counters++
+		}
+		if i > 0 {
+			// the samples' order is kept
+			assert.False(t, s.Time.Before(kept[i-1].Time))
+		}
+	}
+	assert.Equal(t, 10, trends)
+	assert.Equal(t, 100, counters)
+}
+
+func TestBatchFromSamplesTrendSampling(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	var samples metrics.Samples
+	for i := 0; i < 50; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+			Time:       time.Unix(int64(i), 0),
+			Value:      float64(i),
+		})
+	}
+
+	o := newTestOutput(t, `{"trendSampling":"reservoir","trendReservoirSize":5}`)
+	assert.Len(t, o.batchFromSamples([]metrics.SampleContainer{samples}), 5)
+	o = newTestOutput(t, `{"trendSampling":"uniform","trendSamplingRate":1}`)
+	assert.Len(t, o.batchFromSamples([]metrics.SampleContainer{samples}), 50)
+	o = newTestOutput(t, `{}`)
+	assert.Len(t, o.batchFromSamples([]metrics.SampleContainer{samples}), 50)
+}
+
+func TestCheckTrendSampling(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		conf   Config
+		expErr string
+	}{
+		"Disabled":        {conf: Config{}},
+		"Uniform":         {conf: Config{TrendSampling: null.StringFrom("uniform"), TrendSamplingRate: null.FloatFrom(0.1)}},
+		"UniformZeroRate": {conf: Config{TrendSampling: null.StringFrom("uniform")}, expErr: "TrendSamplingRate"},
+		"UniformHighRate": {
+			conf:   Config{TrendSampling: null.StringFrom("uniform"), TrendSamplingRate: null.FloatFrom(1.5)},
+			expErr: "TrendSamplingRate",
+		},
+		"Reservoir":       {conf: Config{TrendSampling: null.StringFrom("reservoir"), TrendReservoirSize: null.IntFrom(10)}},
+		"ReservoirNoSize": {conf: Config{TrendSampling: null.StringFrom("reservoir")}, expErr: "TrendReservoirSize"},
+		"Invalid":         {conf: Config{TrendSampling: null.StringFrom("random")}, expErr: "invalid trend sampling (random)"},
+	}
+	for name, tc := range tests {
+		err := checkTrendSampling(tc.conf)
+		if tc.expErr == "" {
+			assert.NoError(t, err, name)
+			continue
+		}
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), tc.expErr, name)
+	}
+}
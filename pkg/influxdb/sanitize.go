@@ -0,0 +1,66 @@
+package influxdb
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// keyIllegalChars are the characters replaced in the tag and field keys. The client escapes
+// some of them, but they are a frequent cause of rejected writes and of keys hard to query.
+const keyIllegalChars = " ,=\"\\\n\r\t"
+
+// keySanitizer replaces the illegal characters in the tag and field keys,
+// the result is memoized, so each rewritten key is logged only once.
+type keySanitizer struct {
+	replacer *strings.Replacer
+	logger   logrus.FieldLogger
+	keys     sync.Map
+}
+
+func newKeySanitizer(replacement string, logger logrus.FieldLogger) *keySanitizer {
+	oldnew := make([]string, 0, 2*len(keyIllegalChars))
+	for _, c := range keyIllegalChars {
+		oldnew = append(oldnew, string(c), replacement)
+	}
+	return &keySanitizer{
+		replacer: strings.NewReplacer(oldnew...),
+		logger:   logger,
+	}
+}
+
+// sanitize returns the key without the illegal characters.
+func (ks *keySanitizer) sanitize(key string) string {
+	if v, ok := ks.keys.Load(key); ok {
+		return v.(string) //nolint:forcetypeassert
+	}
+	sanitized := ks.replacer.Replace(key)
+	if sanitized != key {
+		ks.logger.WithField("key", key).WithField("sanitized", sanitized).Debug("A tag or field key has been rewritten")
+	}
+	ks.keys.Store(key, sanitized)
+	return sanitized
+}
+
+// sanitizeTags rewrites in place the tag keys with illegal characters.
+// When a rewritten key collides with an existing one, the latter is overwritten.
+func (ks *keySanitizer) sanitizeTags(tags map[string]string) {
+	for k, v := range tags {
+		if sanitized := ks.sanitize(k); sanitized != k {
+			delete(tags, k)
+			tags[sanitized] = v
+		}
+	}
+}
+
+// sanitizeValues rewrites in place the field keys with illegal characters.
+// When a rewritten key collides with an existing one, the latter is overwritten.
+func (ks *keySanitizer) sanitizeValues(values map[string]interface{}) {
+	for k, v := range values {
+		if sanitized := ks.sanitize(k); sanitized != k {
+			delete(values, k)
+			values[sanitized] = v
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package influxdb
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySanitizer(t *testing.T) {
+	t.Parallel()
+
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	ks := newKeySanitizer("_", logger)
+
+	tags := map[string]string{"my tag": "a b", "k,e=y": "v", "status": "200"}
+	ks.sanitizeTags(tags)
+	assert.Equal(t, map[string]string{"my_tag": "a b", "k_e_y": "v", "status": "200"}, tags)
+
+	values := map[string]interface{}{"my\tfield": 1, "vu": 2}
+	ks.sanitizeValues(values)
+	assert.Equal(t, map[string]interface{}{"my_field": 1, "vu": 2}, values)
+	assert.Len(t, hook.AllEntries(), 3)
+
+	// the rewritten keys are logged only once
+	hook.Reset()
+	tags = map[string]string{"my tag": "c"}
+	ks.sanitizeTags(tags)
+	assert.Equal(t, map[string]string{"my_tag": "c"}, tags)
+	assert.Empty(t, hook.AllEntries())
+
+	assert.Equal(t, "a--b", newKeySanitizer("-", logger).sanitize(`a"\b`))
+}
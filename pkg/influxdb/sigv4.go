@@ -0,0 +1,116 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// checkAWSSigV4 returns an error if the AWSSigV4 options are inconsistent.
+func checkAWSSigV4(c Config) error {
+	if !c.AWSSigV4.Bool {
+		return nil
+	}
+	if c.Flavor.String == FlavorTelegraf {
+		return fmt.Errorf("the AWSSigV4 option isn't supported by the %s flavor", FlavorTelegraf)
+	}
+	if c.AWSSigV4Service.String == "" {
+		return fmt.Errorf("the AWSSigV4Service option is required by AWSSigV4")
+	}
+	if (c.AWSSigV4AccessKeyID.String == "") != (c.AWSSigV4SecretAccessKey.String == "") {
+		return fmt.Errorf("the AWSSigV4AccessKeyID and AWSSigV4SecretAccessKey options must be set together")
+	}
+	return nil
+}
+
+// signRequests replaces the client's transport with one signing the requests with AWS SigV4.
+// The client is copied, so an injected client isn't changed.
+func signRequests(ctx context.Context, opts *influxdbclient.Options, conf Config) error {
+	client := *opts.HTTPClient()
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tr, err := newSigV4Transport(ctx, base, conf)
+	if err != nil {
+		return err
+	}
+	client.Transport = tr
+	opts.SetHTTPClient(&client)
+	return nil
+}
+
+// sigV4Transport signs the requests with AWS Signature Version 4, it is required
+// by the managed endpoints fronting the InfluxDB-compatible APIs.
+type sigV4Transport struct {
+	base        http.RoundTripper
+	signer      *v4.Signer
+	credentials aws.CredentialsProvider
+	service     string
+	region      string
+}
+
+// newSigV4Transport returns a signing transport, the region and the credentials are resolved
+// by the standard AWS chain, e.g. the environment variables and the shared config files,
+// unless they are explicitly set.
+func newSigV4Transport(ctx context.Context, base http.RoundTripper, conf Config) (*sigV4Transport, error) {
+	var optFns []func(*awsconfig.LoadOptions) error
+	if conf.AWSSigV4Region.String != "" {
+		optFns = append(optFns, awsconfig.WithRegion(conf.AWSSigV4Region.String))
+	}
+	if conf.AWSSigV4AccessKeyID.String != "" {
+		optFns = append(optFns, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			conf.AWSSigV4AccessKeyID.String, conf.AWSSigV4SecretAccessKey.String, conf.AWSSigV4SessionToken.String)))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load the AWS config for AWSSigV4: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("the AWS region is required by AWSSigV4, set it with AWSSigV4Region or the AWS config")
+	}
+	return &sigV4Transport{
+		base:        base,
+		signer:      v4.NewSigner(),
+		credentials: cfg.Credentials,
+		service:     conf.AWSSigV4Service.String,
+		region:      cfg.Region,
+	}, nil
+}
+
+// RoundTrip signs a copy of the request, including the hash of its body, and sends it.
+func (t *sigV4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var payload []byte
+	signed := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		payload, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		signed.Body = io.NopCloser(bytes.NewReader(payload))
+	}
+	hash := sha256.Sum256(payload)
+
+	creds, err := t.credentials.Retrieve(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't retrieve the AWS credentials: %w", err)
+	}
+	err = t.signer.SignHTTP(req.Context(), creds, signed, hex.EncodeToString(hash[:]), t.service, t.region, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sign the request with AWS SigV4: %w", err)
+	}
+	return t.base.RoundTrip(signed)
+}
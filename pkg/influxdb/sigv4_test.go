@@ -0,0 +1,69 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputAWSSigV4(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		headers http.Header
+		body    string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		headers, body = r.Header.Clone(), string(b)
+		mu.Unlock()
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig: json.RawMessage(`{"token":"my-token","awsSigV4":true,"awsSigV4Region":"eu-west-1",` +
+			`"awsSigV4Service":"timestream","awsSigV4AccessKeyID":"AKIDEXAMPLE",` +
+			`"awsSigV4SecretAccessKey":"secret","awsSigV4SessionToken":"session"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: metric,
+			Tags:   registry.RootTagSet().With("method", "GET"),
+		},
+		Time:  time.Unix(1700000000, 0),
+		Value: 1,
+	}})
+	require.NoError(t, o.Stop())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotNil(t, headers, "the write request has not been received")
+	// the scope is the date of the signature, the region and the service
+	assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/\d{8}/eu-west-1/timestream/aws4_request, `+
+		`SignedHeaders=[a-z0-9;-]*host[a-z0-9;-]*, Signature=[0-9a-f]{64}$`, headers.Get("Authorization"))
+	assert.NotEmpty(t, headers.Get("X-Amz-Date"))
+	assert.Equal(t, "session", headers.Get("X-Amz-Security-Token"))
+	// the signed body is sent unchanged
+	assert.Equal(t, "test_gauge,method=GET value=1 1700000000000000000\n", body)
+}
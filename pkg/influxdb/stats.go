@@ -0,0 +1,210 @@
+package influxdb
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats contains the statistics collected by the Output during the test run.
+type Stats struct {
+	// FlushDuration summarizes the time spent by the flush operations.
+	FlushDuration DurationSummary
+	// RejectedPoints is the number of the points rejected by partial writes.
+	RejectedPoints int
+	// DroppedSamples is the number of the samples dropped because
+	// all the concurrent writes were busy for longer than the WriteSlotTimeout.
+	DroppedSamples int
+	// Reconnects is the number of the times the client has been rebuilt
+	// after ReconnectAfterFailures consecutive failed flushes.
+	Reconnects int
+	// Writes is the number of the write requests, FailedWrites the ones that have failed.
+	Writes       int
+	FailedWrites int
+	// WriteErrorBudgetExceeded is set when the rate of the failed writes
+	// has exceeded the MaxWriteErrorRate.
+	WriteErrorBudgetExceeded bool
+	// MirrorWrites are the writes to each bucket of MirrorBuckets,
+	// they aren't counted by Writes and FailedWrites.
+	MirrorWrites map[string]MirrorWrites
+}
+
+// MirrorWrites is the number of the write requests to a mirror bucket,
+// FailedWrites the ones that have failed.
+type MirrorWrites struct {
+	Writes       int
+	FailedWrites int
+}
+
+// DurationSummary is an aggregation of the observed durations.
+type DurationSummary struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+}
+
+// statsCollector collects the Output's statistics,
+// it is safe for concurrent use.
+type statsCollector struct {
+	mu             sync.Mutex
+	flushDurations durationReservoir
+	rejectedPoints int
+	droppedSamples int
+	reconnects     int
+	writes         int
+	failedWrites   int
+	budgetExceeded bool
+	mirrorWrites   map[string]MirrorWrites
+}
+
+func (sc *statsCollector) recordFlush(d time.Duration) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.flushDurations.add(d)
+}
+
+func (sc *statsCollector) recordRejectedPoints(n int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.rejectedPoints += n
+}
+
+func (sc *statsCollector) recordDroppedSamples(n int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.droppedSamples += n
+}
+
+func (sc *statsCollector) recordReconnect() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.reconnects++
+}
+
+// recordWrite counts the write request, it returns the count of the writes and of the failed ones.
+func (sc *statsCollector) recordWrite(failed bool) (int, int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.writes++
+	if failed {
+		sc.failedWrites++
+	}
+	return sc.writes, sc.failedWrites
+}
+
+func (sc *statsCollector) recordMirrorWrite(bucket string, failed bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.mirrorWrites == nil {
+		sc.mirrorWrites = make(map[string]MirrorWrites)
+	}
+	w := sc.mirrorWrites[bucket]
+	w.Writes++
+	if failed {
+		w.FailedWrites++
+	}
+	sc.mirrorWrites[bucket] = w
+}
+
+// exceedWriteErrorBudget flags the error budget as exceeded,
+// it returns true only the first time.
+func (sc *statsCollector) exceedWriteErrorBudget() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.budgetExceeded {
+		return false
+	}
+	sc.budgetExceeded = true
+	return true
+}
+
+func (sc *statsCollector) stats() Stats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var mirrorWrites map[string]MirrorWrites
+	if len(sc.mirrorWrites) > 0 {
+		mirrorWrites = make(map[string]MirrorWrites, len(sc.mirrorWrites))
+		for bucket, w := range sc.mirrorWrites {
+			mirrorWrites[bucket] = w
+		}
+	}
+	return Stats{
+		FlushDuration:  sc.flushDurations.summary(),
+		RejectedPoints: sc.rejectedPoints,
+		DroppedSamples: sc.droppedSamples,
+		Reconnects:     sc.reconnects,
+
+		Writes:                   sc.writes,
+		FailedWrites:             sc.failedWrites,
+		WriteErrorBudgetExceeded: sc.budgetExceeded,
+		MirrorWrites:             mirrorWrites,
+	}
+}
+
+// durationReservoirSize is the number of the durations sampled for the percentiles,
+// they are exact up to this number of observations.
+const durationReservoirSize = 1024
+
+// durationReservoir keeps the count, the min and the max of the observed durations and
+// a uniform sample of them, with the reservoir sampling, so a long run uses a bounded memory.
+type durationReservoir struct {
+	count    int
+	min, max time.Duration
+	samples  []time.Duration
+}
+
+func (r *durationReservoir) add(d time.Duration) {
+	r.count++
+	if r.count == 1 || d < r.min {
+		r.min = d
+	}
+	if d > r.max {
+		r.max = d
+	}
+	if len(r.samples) < durationReservoirSize {
+		r.samples = append(r.samples, d)
+		return
+	}
+	// each of the observed durations has the same probability to be in the sample
+	if i := rand.Intn(r.count); i < durationReservoirSize { //nolint:gosec
+		r.samples[i] = d
+	}
+}
+
+// summary returns the summary of the observed durations, the percentiles are computed on the sample.
+func (r *durationReservoir) summary() DurationSummary {
+	s := summarizeDurations(r.samples)
+	s.Count, s.Min, s.Max = r.count, r.min, r.max
+	return s
+}
+
+// summarizeDurations computes the summary of the provided durations,
+// the percentiles are calculated using the nearest-rank method.
+func summarizeDurations(durations []time.Duration) DurationSummary {
+	if len(durations) == 0 {
+		return DurationSummary{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(p float64) time.Duration {
+		rank := int(math.Ceil(p * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+
+	return DurationSummary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+	}
+}
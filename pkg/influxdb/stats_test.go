@@ -0,0 +1,86 @@
+package influxdb
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeDurations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, DurationSummary{}, summarizeDurations(nil))
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		t.Parallel()
+		d := 3 * time.Millisecond
+		assert.Equal(t, DurationSummary{Count: 1, Min: d, Max: d, P50: d, P95: d}, summarizeDurations([]time.Duration{d}))
+	})
+
+	t.Run("Many", func(t *testing.T) {
+		t.Parallel()
+		durations := make([]time.Duration, 0, 100)
+		// reversed order, it checks the sorting
+		for i := 100; i > 0; i-- {
+			durations = append(durations, time.Duration(i)*time.Millisecond)
+		}
+		exp := DurationSummary{
+			Count: 100,
+			Min:   1 * time.Millisecond,
+			Max:   100 * time.Millisecond,
+			P50:   50 * time.Millisecond,
+			P95:   95 * time.Millisecond,
+		}
+		assert.Equal(t, exp, summarizeDurations(durations))
+		// the source slice isn't changed
+		assert.Equal(t, 100*time.Millisecond, durations[0])
+	})
+}
+
+func TestStatsCollectorConcurrentRecords(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sc statsCollector
+		wg sync.WaitGroup
+	)
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sc.recordFlush(time.Duration(i) * time.Second)
+		}(i)
+	}
+	wg.Wait()
+
+	fd := sc.stats().FlushDuration
+	assert.Equal(t, 10, fd.Count)
+	assert.Equal(t, time.Second, fd.Min)
+	assert.Equal(t, 10*time.Second, fd.Max)
+	assert.Equal(t, 5*time.Second, fd.P50)
+	assert.Equal(t, 10*time.Second, fd.P95)
+}
+
+func TestDurationReservoir(t *testing.T) {
+	t.Parallel()
+
+	var r durationReservoir
+	n := 10 * durationReservoirSize
+	for i := 1; i <= n; i++ {
+		r.add(time.Duration(i) * time.Millisecond)
+	}
+	assert.Len(t, r.samples, durationReservoirSize)
+
+	s := r.summary()
+	assert.Equal(t, n, s.Count)
+	assert.Equal(t, time.Millisecond, s.Min)
+	assert.Equal(t, time.Duration(n)*time.Millisecond, s.Max)
+	// the percentiles of the uniform sample are close to the exact ones
+	assert.InDelta(t, float64(n/2), float64(s.P50/time.Millisecond), float64(n)/10)
+	assert.InDelta(t, float64(n*95/100), float64(s.P95/time.Millisecond), float64(n)/10)
+}
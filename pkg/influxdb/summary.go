@@ -0,0 +1,143 @@
+package influxdb
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.k6.io/k6/metrics"
+)
+
+const summaryMetricTag = "metric"
+
+// summarySampleSize is the number of the values of a metric sampled for the p95,
+// it is exact up to this number of values. Beyond it, the p95 of the uniform sample
+// is between the p93.6 and the p96.4 of all the values, with a 95% confidence.
+const summarySampleSize = 1024
+
+// metricSummary aggregates the values of a metric during the test run, the count, min, max
+// and sum are exact, the p95 is computed on a uniform sample with the reservoir sampling,
+// so the memory doesn't grow with the number of the samples.
+type metricSummary struct {
+	count         int
+	min, max, sum float64
+	sample        []float64
+}
+
+func (ms *metricSummary) add(value float64) {
+	ms.count++
+	ms.min = math.Min(ms.min, value)
+	ms.max = math.Max(ms.max, value)
+	ms.sum += value
+	if len(ms.sample) < summarySampleSize {
+		ms.sample = append(ms.sample, value)
+		return
+	}
+	// each of the values has the same probability to be in the sample
+	if i := rand.Intn(ms.count); i < summarySampleSize { //nolint:gosec
+		ms.sample[i] = value
+	}
+}
+
+// p95 returns the p95 of the sample, calculated using the nearest-rank method.
+func (ms *metricSummary) p95() float64 {
+	sorted := make([]float64, len(ms.sample))
+	copy(sorted, ms.sample)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(0.95 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// summaryCollector aggregates the samples of each metric for the SummaryOnly mode,
+// it is safe for concurrent use.
+type summaryCollector struct {
+	mu      sync.Mutex
+	metrics map[string]*metricSummary
+}
+
+func (sc *summaryCollector) add(name string, value float64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.metrics == nil {
+		sc.metrics = make(map[string]*metricSummary)
+	}
+	ms, ok := sc.metrics[name]
+	if !ok {
+		ms = &metricSummary{min: value, max: value}
+		sc.metrics[name] = ms
+	}
+	ms.add(value)
+}
+
+// fields returns the aggregates of each metric.
+func (sc *summaryCollector) fields() map[string]map[string]interface{} {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	fields := make(map[string]map[string]interface{}, len(sc.metrics))
+	for name, ms := range sc.metrics {
+		fields[name] = map[string]interface{}{
+			"count": ms.count,
+			"min":   ms.min,
+			"max":   ms.max,
+			"avg":   ms.sum / float64(ms.count),
+			"p95":   ms.p95(),
+		}
+	}
+	return fields
+}
+
+// summarizeSamples aggregates the samples instead of writing them,
+// the samples excluded by the metric types and the value filters aren't aggregated.
+func (o *Output) summarizeSamples(containers []metrics.SampleContainer) {
+	for _, container := range containers {
+		for _, sample := range container.GetSamples() {
+			if !o.isSampleWritten(sample) {
+				continue
+			}
+			o.summary.add(sample.Metric.Name, sample.Value)
+		}
+	}
+}
+
+// summaryPoints returns a point for each metric with its name as tag,
+// and the count, min, max, avg and p95 fields. The points are ordered by metric.
+func (o *Output) summaryPoints(t time.Time) []*write.Point {
+	fields := o.summary.fields()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	points := make([]*write.Point, 0, len(names))
+	for _, name := range names {
+		tags := o.runTags()
+		tags[summaryMetricTag] = name
+		points = append(points, influxdbclient.NewPoint(o.config.SummaryMeasurement.String, tags, fields[name], t))
+	}
+	return points
+}
+
+// writeSummary writes the metrics' aggregates at the end of the test in the SummaryOnly mode,
+// a failed write is logged as an error because it was the only write of the metrics.
+func (o *Output) writeSummary(ctx context.Context, t time.Time) {
+	points := o.summaryPoints(t)
+	if len(points) == 0 {
+		return
+	}
+	if err := o.currentWriter().WritePoint(ctx, points...); err != nil {
+		o.logger.WithError(err).WithField("points", len(points)).Error("Couldn't write the metrics' summary")
+		o.writeDeadLetter(err, points)
+		o.notifyWriteError(err, len(points))
+		return
+	}
+	o.logger.WithField("points", len(points)).Debug("The metrics' summary has been written")
+}
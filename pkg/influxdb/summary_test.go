@@ -0,0 +1,84 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputSummaryOnly(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	t.Cleanup(ts.Close)
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"summaryOnly":true,"pushInterval":"1h"}`),
+		ScriptOptions:  lib.Options{RunTags: map[string]string{"env": "staging"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	trend, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	counter, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	var samples metrics.Samples
+	for i := 1; i <= 20; i++ {
+		for _, m := range []*metrics.Metric{trend, counter} {
+			value := float64(i)
+			if m == counter {
+				value = 1
+			}
+			samples = append(samples, metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: m, Tags: registry.RootTagSet().With("status", "200")},
+				Time:       time.Unix(int64(i), 0),
+				Value:      value,
+			})
+		}
+	}
+	// the samples are aggregated by more flushes, without writing them
+	o.AddMetricSamples([]metrics.SampleContainer{samples[:10]})
+	o.flushMetrics()
+	o.AddMetricSamples([]metrics.SampleContainer{samples[10:]})
+	require.NoError(t, o.Flush())
+	o.wg.Wait()
+	assert.Empty(t, lc.Lines())
+
+	require.NoError(t, o.Stop())
+	lines := lc.Lines()
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^k6_summary,env=staging,metric=http_req_duration avg=10.5,count=20i,max=20,min=1,p95=19 \d+$`, lines[0])
+	assert.Regexp(t, `^k6_summary,env=staging,metric=http_reqs avg=1,count=20i,max=1,min=1,p95=1 \d+$`, lines[1])
+}
+
+func TestMetricSummaryBounded(t *testing.T) {
+	t.Parallel()
+
+	sc := &summaryCollector{}
+	n := 100 * summarySampleSize
+	for i := 1; i <= n; i++ {
+		sc.add("http_req_duration", float64(i))
+	}
+	assert.Len(t, sc.metrics["http_req_duration"].sample, summarySampleSize)
+
+	fields := sc.fields()["http_req_duration"]
+	assert.Equal(t, n, fields["count"])
+	assert.Equal(t, float64(1), fields["min"])
+	assert.Equal(t, float64(n), fields["max"])
+	assert.Equal(t, float64(n+1)/2, fields["avg"])
+	// the p95 of the uniform sample is close to the exact one
+	assert.InDelta(t, float64(n)*0.95, fields["p95"], float64(n)*0.03)
+}
@@ -0,0 +1,202 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	lp "github.com/influxdata/line-protocol"
+)
+
+// udpPayloadSize is the maximum size of a datagram sent to Telegraf,
+// so it isn't fragmented with the common networks' MTU.
+const udpPayloadSize = 1400
+
+// checkSocketAddr returns an error if the address isn't a TCP or UDP URL with a host and a port.
+func checkSocketAddr(addr string) error {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "udp") {
+		return fmt.Errorf("addr must be a full URL with the tcp or udp scheme for the %s flavor, got %q",
+			FlavorTelegraf, redactURL(addr))
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return fmt.Errorf("addr must be a full URL with host and port for the %s flavor, got %q",
+			FlavorTelegraf, redactURL(addr))
+	}
+	return nil
+}
+
+// socketWriter writes the points as raw line protocol over TCP or UDP,
+// e.g. to the Telegraf's socket_listener input plugin.
+type socketWriter struct {
+	network   string
+	address   string
+	dialer    *net.Dialer
+	precision time.Duration
+}
+
+func newSocketWriter(addr string, precision time.Duration) (*socketWriter, error) {
+	if err := checkSocketAddr(addr); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &socketWriter{
+		network: u.Scheme,
+		address: u.Host,
+		// the same timeout of the client's default dialer
+		dialer:    &net.Dialer{Timeout: 5 * time.Second},
+		precision: precision,
+	}, nil
+}
+
+// WritePoint writes the points on a new connection. With UDP, the lines are split
+// in more datagrams of at most udpPayloadSize bytes, a longer line is sent alone.
+func (w *socketWriter) WritePoint(ctx context.Context, points ...*write.Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	lines, err := encodeLines(points, w.precision)
+	if err != nil {
+		return err
+	}
+	return w.send(ctx, lines)
+}
+
+// WriteRecord writes the lines on a new connection, the same as WritePoint.
+func (w *socketWriter) WriteRecord(ctx context.Context, lines ...string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	return w.send(ctx, recordLines(lines))
+}
+
+func (w *socketWriter) send(ctx context.Context, lines [][]byte) error {
+	chunks := [][]byte{bytes.Join(lines, nil)}
+	if w.network == "udp" {
+		chunks = chunkLines(lines, udpPayloadSize)
+	}
+
+	conn, err := w.dialer.DialContext(ctx, w.network, w.address)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// it unblocks the pending write when the context is cancelled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	for _, chunk := range chunks {
+		if _, err := conn.Write(chunk); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeLines returns the line protocol of each point, terminated by a new line.
+// The tags and the fields are sorted by key, so the lines are deterministic.
+func encodeLines(points []*write.Point, precision time.Duration) ([][]byte, error) {
+	var buf bytes.Buffer
+	e := newLineEncoder(&buf, precision)
+	ends := make([]int, 0, len(points))
+	for _, p := range points {
+		if _, err := e.Encode(sortedKeys(p)); err != nil {
+			return nil, err
+		}
+		ends = append(ends, buf.Len())
+	}
+	// the buffer's content is sliced only at the end, when it doesn't grow anymore
+	b := buf.Bytes()
+	lines := make([][]byte, 0, len(ends))
+	start := 0
+	for _, end := range ends {
+		lines = append(lines, b[start:end:end])
+		start = end
+	}
+	return lines, nil
+}
+
+// sortedMetric is a point whose tags and fields are sorted by key.
+type sortedMetric struct {
+	*write.Point
+	tags   []*lp.Tag
+	fields []*lp.Field
+}
+
+func (m sortedMetric) TagList() []*lp.Tag {
+	return m.tags
+}
+
+func (m sortedMetric) FieldList() []*lp.Field {
+	return m.fields
+}
+
+// sortedKeys returns the point with its tags and fields sorted by key. NewPoint already sorts them,
+// but a PointMutator can add more with AddTag and AddField, that append them. The keys' order
+// doesn't matter to InfluxDB, so it is only for the encoded lines to be the same across the runs,
+// e.g. for diffing the files. The point isn't changed, it can be encoded concurrently by other writes.
+func sortedKeys(p *write.Point) lp.Metric {
+	tags, fields := p.TagList(), p.FieldList()
+	tagsSorted := sort.SliceIsSorted(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	fieldsSorted := sort.SliceIsSorted(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	if tagsSorted && fieldsSorted {
+		return p
+	}
+	if !tagsSorted {
+		tags = append([]*lp.Tag(nil), tags...)
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	}
+	if !fieldsSorted {
+		fields = append([]*lp.Field(nil), fields...)
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	}
+	return sortedMetric{Point: p, tags: tags, fields: fields}
+}
+
+// newLineEncoder returns an encoder of the line protocol with the same settings of the client's writer.
+func newLineEncoder(w io.Writer, precision time.Duration) *lp.Encoder {
+	e := lp.NewEncoder(w)
+	e.SetFieldTypeSupport(lp.UintSupport)
+	e.FailOnFieldErr(true)
+	e.SetPrecision(precision)
+	return e
+}
+
+// chunkLines joins the lines in chunks of at most size bytes, without splitting a line.
+func chunkLines(lines [][]byte, size int) [][]byte {
+	var chunks [][]byte
+	var chunk []byte
+	for _, line := range lines {
+		if len(chunk) > 0 && len(chunk)+len(line) > size {
+			chunks = append(chunks, chunk)
+			chunk = nil
+		}
+		chunk = append(chunk, line...)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
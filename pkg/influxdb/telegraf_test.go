@@ -0,0 +1,159 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+// telegrafSamples returns n samples with a long tag, so their lines need more datagrams.
+func telegrafSamples(t *testing.T, n int) (metrics.Samples, []string) {
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	url := "https://example.com/" + strings.Repeat("a", 200)
+	samples := make(metrics.Samples, 0, n)
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("name", url)},
+			Time:       time.Unix(int64(i), 0),
+			Value:      1,
+		})
+		lines = append(lines, fmt.Sprintf("test_counter,name=%s value=1 %d\n", url, int64(i)*int64(time.Second)))
+	}
+	return samples, lines
+}
+
+func newTelegrafOutput(t *testing.T, addr string) *Output {
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: addr,
+		JSONConfig:     json.RawMessage(`{"flavor":"telegraf"}`),
+	})
+	require.NoError(t, err)
+	o.ctx = context.Background()
+	return o
+}
+
+func TestOutputFlavorTelegrafUDP(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = pc.Close()
+	}()
+
+	samples, lines := telegrafSamples(t, 20)
+	o := newTelegrafOutput(t, "udp://"+pc.LocalAddr().String())
+	require.NoError(t, o.writeSamples([]metrics.SampleContainer{samples}))
+
+	var received strings.Builder
+	buf := make([]byte, 64*1024)
+	datagrams := 0
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for received.Len() < len(strings.Join(lines, "")) {
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, n, udpPayloadSize)
+		// a datagram contains only whole lines
+		assert.True(t, strings.HasSuffix(string(buf[:n]), "\n"))
+		received.Write(buf[:n])
+		datagrams++
+	}
+	assert.Equal(t, strings.Join(lines, ""), received.String())
+	assert.Greater(t, datagrams, 1)
+}
+
+func TestOutputFlavorTelegrafTCP(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = l.Close()
+	}()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		b, _ := io.ReadAll(conn)
+		received <- string(b)
+	}()
+
+	samples, lines := telegrafSamples(t, 20)
+	o := newTelegrafOutput(t, "tcp://"+l.Addr().String())
+	require.NoError(t, o.writeSamples([]metrics.SampleContainer{samples}))
+
+	select {
+	case b := <-received:
+		assert.Equal(t, strings.Join(lines, ""), b)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lines haven't been received")
+	}
+}
+
+func TestNewFlavorTelegraf(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		addr   string
+		conf   string
+		expErr string
+	}{
+		"UDP":    {addr: "udp://localhost:8094", conf: `{"flavor":"telegraf"}`},
+		"TCP":    {addr: "tcp://localhost:8094/", conf: `{"flavor":"telegraf"}`},
+		"HTTP":   {addr: "http://localhost:8094", conf: `{"flavor":"telegraf"}`, expErr: "tcp or udp scheme"},
+		"NoPort": {addr: "udp://localhost", conf: `{"flavor":"telegraf"}`, expErr: "host and port"},
+		"Async":  {addr: "udp://localhost:8094", conf: `{"flavor":"telegraf","asyncWrite":true}`, expErr: "AsyncWrite"},
+		"Gzip":   {addr: "tcp://localhost:8094", conf: `{"flavor":"telegraf","gzip":true}`, expErr: "Gzip"},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: tc.addr,
+				JSONConfig:     json.RawMessage(tc.conf),
+			})
+			if tc.expErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+		})
+	}
+}
+
+func TestChunkLines(t *testing.T) {
+	t.Parallel()
+
+	lines := [][]byte{[]byte("aaa\n"), []byte("bb\n"), []byte("cccccccc\n"), []byte("d\n")}
+	chunks := chunkLines(lines, 8)
+	got := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		got = append(got, string(c))
+	}
+	// the line longer than the size is sent alone
+	assert.Equal(t, []string{"aaa\nbb\n", "cccccccc\n", "d\n"}, got)
+}
@@ -0,0 +1,59 @@
+package influxdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.k6.io/k6/metrics"
+)
+
+const (
+	thresholdMetricTag   = "metric"
+	thresholdSourceTag   = "threshold"
+	thresholdPassedField = "passed"
+)
+
+// SetThresholds receives the thresholds of the test from k6, before the output is started.
+// Their status is updated by k6 when they are evaluated.
+func (o *Output) SetThresholds(thresholds map[string]metrics.Thresholds) {
+	o.thresholds = thresholds
+}
+
+// thresholdPoints returns a point for each threshold with its metric and expression as tags,
+// and its status as the passed field. The points are ordered by metric and by definition.
+func (o *Output) thresholdPoints(t time.Time) []*write.Point {
+	names := make([]string, 0, len(o.thresholds))
+	for name := range o.thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var points []*write.Point
+	for _, name := range names {
+		for _, th := range o.thresholds[name].Thresholds {
+			tags := o.runTags()
+			tags[thresholdMetricTag] = name
+			tags[thresholdSourceTag] = th.Source
+			values := map[string]interface{}{thresholdPassedField: !th.LastFailed}
+			points = append(points, influxdbclient.NewPoint(o.config.ThresholdsMeasurement.String, tags, values, t))
+		}
+	}
+	return points
+}
+
+// writeThresholds writes immediately the thresholds' status at the end of the test,
+// a failed write is only logged, the same as the lifecycle events.
+func (o *Output) writeThresholds(ctx context.Context, t time.Time) {
+	points := o.thresholdPoints(t)
+	if len(points) == 0 {
+		return
+	}
+	if err := o.currentWriter().WritePoint(ctx, points...); err != nil {
+		o.logger.WithError(err).WithField("points", len(points)).Warn("Couldn't write the thresholds")
+		return
+	}
+	o.logger.WithField("points", len(points)).Debug("The thresholds have been written")
+}
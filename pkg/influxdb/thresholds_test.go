@@ -0,0 +1,75 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputThresholds(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		conf     string
+		expLines []string
+	}{
+		"Enabled": {
+			conf: `{"emitThresholds":true,"addRunID":true,"runID":"abc"}`,
+			expLines: []string{
+				`k6_thresholds,env=staging,metric=checks,run_id=abc,threshold=rate>0.99 passed=false`,
+				`k6_thresholds,env=staging,metric=http_req_duration,run_id=abc,threshold=p(95)<200 passed=true`,
+				`k6_thresholds,env=staging,metric=http_req_duration,run_id=abc,threshold=p(99)<500 passed=false`,
+				`k6_thresholds,env=staging,metric=http_req_duration{status:200},run_id=abc,threshold=avg<100 passed=true`,
+			},
+		},
+		"Disabled": {
+			conf:     `{}`,
+			expLines: []string{},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lc := &lineCollector{}
+			ts := httptest.NewServer(lc)
+			t.Cleanup(ts.Close)
+
+			o, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     json.RawMessage(tc.conf),
+				ScriptOptions:  lib.Options{RunTags: map[string]string{"env": "staging"}},
+			})
+			require.NoError(t, err)
+
+			failed := &metrics.Threshold{Source: "p(99)<500"}
+			o.SetThresholds(map[string]metrics.Thresholds{
+				"http_req_duration":             {Thresholds: []*metrics.Threshold{{Source: "p(95)<200"}, failed}},
+				"http_req_duration{status:200}": {Thresholds: []*metrics.Threshold{{Source: "avg<100"}}},
+				"checks":                        {Thresholds: []*metrics.Threshold{{Source: "rate>0.99", LastFailed: true}}},
+			})
+			require.NoError(t, o.Start())
+			// the status is read when the output is stopped
+			failed.LastFailed = true
+			require.NoError(t, o.Stop())
+
+			lines := lc.Lines()
+			got := make([]string, 0, len(lines))
+			for _, line := range lines {
+				// without the timestamp
+				got = append(got, line[:strings.LastIndex(line, " ")])
+			}
+			assert.Equal(t, tc.expLines, got)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package influxdb
+
+import (
+	"sync"
+	"time"
+)
+
+// throughputMeter measures the rate of the written points over consecutive windows,
+// it is safe for concurrent use by the flushes.
+type throughputMeter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	start    time.Time
+	points   int
+}
+
+// record adds the points written at the time, when the window is longer than the interval
+// it returns the points per second of the window and a new window is started.
+func (tm *throughputMeter) record(points int, now time.Time) (float64, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.start.IsZero() {
+		tm.start = now
+	}
+	tm.points += points
+	elapsed := now.Sub(tm.start)
+	if elapsed < tm.interval || elapsed <= 0 {
+		return 0, false
+	}
+	rate := float64(tm.points) / elapsed.Seconds()
+	tm.start, tm.points = now, 0
+	return rate, true
+}
+
+// recordThroughput logs at the info level the points per second written
+// in the last LogThroughputInterval, if LogThroughput is enabled.
+func (o *Output) recordThroughput(points int) {
+	if o.throughput == nil {
+		return
+	}
+	if rate, ok := o.throughput.record(points, o.clock.Now()); ok {
+		o.logger.WithField("points_per_second", int64(rate)).Info("InfluxDB write throughput")
+	}
+}
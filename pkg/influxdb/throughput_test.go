@@ -0,0 +1,88 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestThroughputMeter(t *testing.T) {
+	t.Parallel()
+
+	tm := &throughputMeter{interval: 10 * time.Second}
+	start := time.Unix(0, 0)
+	steps := []struct {
+		points  int
+		elapsed time.Duration
+		expRate float64
+		expOK   bool
+	}{
+		{points: 100},
+		{points: 400, elapsed: 5 * time.Second},
+		{points: 500, elapsed: 10 * time.Second, expRate: 100, expOK: true},
+		// a new window is started
+		{points: 300, elapsed: 15 * time.Second},
+		{points: 300, elapsed: 22 * time.Second, expRate: 50, expOK: true},
+	}
+	for _, step := range steps {
+		rate, ok := tm.record(step.points, start.Add(step.elapsed))
+		assert.Equal(t, step.expOK, ok)
+		assert.InDelta(t, step.expRate, rate, 0.001)
+	}
+}
+
+func TestOutputLogThroughput(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	t.Cleanup(ts.Close)
+
+	logger, hook := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"logThroughput":true,"logThroughputInterval":"50ms"}`),
+	})
+	require.NoError(t, err)
+	o.ctx = context.Background()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	samples := make(metrics.Samples, 100)
+	for i := range samples {
+		samples[i] = metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+			Time:       time.Unix(int64(i), 0),
+			Value:      1,
+		}
+	}
+	// at most 100 points every 10ms, so at most about 10000 points per second
+	for i := 0; i < 12; i++ {
+		require.NoError(t, o.writeSamples([]metrics.SampleContainer{samples}))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var rates []int64
+	for _, e := range hook.AllEntries() {
+		if e.Message == "InfluxDB write throughput" {
+			assert.Equal(t, logrus.InfoLevel, e.Level)
+			rates = append(rates, e.Data["points_per_second"].(int64)) //nolint:forcetypeassert
+		}
+	}
+	require.NotEmpty(t, rates)
+	for _, rate := range rates {
+		assert.Greater(t, rate, int64(0))
+		assert.LessOrEqual(t, rate, int64(12000))
+	}
+}
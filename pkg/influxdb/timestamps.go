@@ -0,0 +1,69 @@
+package influxdb
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// disambiguateTimestamps moves forward, by a step of the write precision each time, the points with
+// the same measurement, tags and timestamp of a previous point of the batch, so InfluxDB doesn't
+// overwrite them. The points are processed in order, so the result is deterministic. A point is
+// never moved to the next second, it keeps its timestamp if no free one is left in its second.
+// It returns the number of the moved points.
+func disambiguateTimestamps(points []*write.Point, precision time.Duration) int {
+	if precision <= 0 || precision >= time.Second {
+		return 0
+	}
+	taken := make(map[string]struct{}, len(points))
+	// the last timestamp assigned to the duplicates of each point,
+	// so the probing restarts from it instead of the original timestamp
+	last := make(map[string]time.Time)
+	moved := 0
+	for _, p := range points {
+		series := pointSeriesKey(p)
+		t := p.Time().Truncate(precision)
+		second := t.Truncate(time.Second)
+		origKey := series + strconv.FormatInt(t.UnixNano(), 10)
+		nudged := t
+		if l, ok := last[origKey]; ok {
+			nudged = l
+		}
+		for {
+			key := series + strconv.FormatInt(nudged.UnixNano(), 10)
+			if _, ok := taken[key]; !ok {
+				taken[key] = struct{}{}
+				break
+			}
+			nudged = nudged.Add(precision)
+			if !nudged.Truncate(time.Second).Equal(second) {
+				// no free timestamp in the second, it keeps the original
+				nudged = t
+				break
+			}
+		}
+		if !nudged.Equal(t) {
+			last[origKey] = nudged
+			p.SetTime(nudged)
+			moved++
+		}
+	}
+	return moved
+}
+
+// pointSeriesKey returns a key identifying the point's measurement and tags,
+// the tags of a point are sorted by key.
+func pointSeriesKey(p *write.Point) string {
+	var sb strings.Builder
+	sb.WriteString(p.Name())
+	for _, tag := range p.TagList() {
+		sb.WriteByte(0)
+		sb.WriteString(tag.Key)
+		sb.WriteByte(0)
+		sb.WriteString(tag.Value)
+	}
+	sb.WriteByte(0)
+	return sb.String()
+}
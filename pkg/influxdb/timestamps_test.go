@@ -0,0 +1,107 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	influxdbclient "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputDisambiguateTimestamps(t *testing.T) {
+	t.Parallel()
+
+	lc := &lineCollector{}
+	ts := httptest.NewServer(lc)
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"disambiguateTimestamps":true,"pushInterval":"1h"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	now := time.Unix(10, 500)
+	sample := func(status string, v float64) metrics.Sample {
+		return metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("status", status)},
+			Time:       now,
+			Value:      v,
+		}
+	}
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Samples{
+		sample("200", 1), sample("200", 2), sample("404", 3), sample("200", 4),
+	}})
+	require.NoError(t, o.Flush())
+	require.NoError(t, o.Stop())
+
+	assert.Equal(t, []string{
+		"http_reqs,status=200 value=1 10000000500",
+		"http_reqs,status=200 value=2 10000000501",
+		// another series keeps its timestamp
+		"http_reqs,status=404 value=3 10000000500",
+		"http_reqs,status=200 value=4 10000000502",
+	}, lc.Lines())
+}
+
+func TestDisambiguateTimestamps(t *testing.T) {
+	t.Parallel()
+
+	newPoint := func(tag string, ts time.Time) *write.Point {
+		return influxdbclient.NewPoint("m", map[string]string{"t": tag}, map[string]interface{}{"value": 1}, ts)
+	}
+	times := func(points []*write.Point) []time.Time {
+		res := make([]time.Time, 0, len(points))
+		for _, p := range points {
+			res = append(res, p.Time())
+		}
+		return res
+	}
+
+	t.Run("Precision", func(t *testing.T) {
+		t.Parallel()
+		base := time.Unix(10, int64(5*time.Millisecond+300))
+		points := []*write.Point{newPoint("a", base), newPoint("a", base.Add(100)), newPoint("a", base)}
+		assert.Equal(t, 2, disambiguateTimestamps(points, time.Millisecond))
+		assert.Equal(t, []time.Time{
+			base,
+			time.Unix(10, int64(6*time.Millisecond)),
+			time.Unix(10, int64(7*time.Millisecond)),
+		}, times(points))
+	})
+
+	t.Run("SecondBoundary", func(t *testing.T) {
+		t.Parallel()
+		base := time.Unix(10, int64(time.Second-2*time.Millisecond))
+		points := []*write.Point{newPoint("a", base), newPoint("a", base), newPoint("a", base), newPoint("a", base)}
+		assert.Equal(t, 1, disambiguateTimestamps(points, time.Millisecond))
+		// the last ones would be moved to the next second, so they are left as they are
+		assert.Equal(t, []time.Time{base, base.Add(time.Millisecond), base, base}, times(points))
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		t.Parallel()
+		base := time.Unix(10, 0)
+		build := func() []*write.Point {
+			return []*write.Point{newPoint("a", base), newPoint("b", base), newPoint("a", base), newPoint("a", base.Add(1))}
+		}
+		first, second := build(), build()
+		disambiguateTimestamps(first, time.Nanosecond)
+		disambiguateTimestamps(second, time.Nanosecond)
+		assert.Equal(t, times(first), times(second))
+		// the third point takes the next nanosecond, so the fourth is moved too
+		assert.Equal(t, []time.Time{base, base, base.Add(1), base.Add(2)}, times(first))
+	})
+}
@@ -0,0 +1,41 @@
+package influxdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeWindow is the wall-clock window of the written samples,
+// the start is inclusive and the end is exclusive. A zero bound is open.
+type timeWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// parseTimeWindow parses the RFC 3339 bounds, an empty bound is open.
+func parseTimeWindow(start, end string) (timeWindow, error) {
+	var w timeWindow
+	var err error
+	if start != "" {
+		if w.start, err = time.Parse(time.RFC3339Nano, start); err != nil {
+			return timeWindow{}, fmt.Errorf("an invalid TimeWindowStart (%s), an RFC 3339 time is expected", start)
+		}
+	}
+	if end != "" {
+		if w.end, err = time.Parse(time.RFC3339Nano, end); err != nil {
+			return timeWindow{}, fmt.Errorf("an invalid TimeWindowEnd (%s), an RFC 3339 time is expected", end)
+		}
+	}
+	if !w.start.IsZero() && !w.end.IsZero() && !w.start.Before(w.end) {
+		return timeWindow{}, fmt.Errorf("the TimeWindowStart option must be before TimeWindowEnd")
+	}
+	return w, nil
+}
+
+// contains reports if the time is in the window.
+func (w timeWindow) contains(t time.Time) bool {
+	if !w.start.IsZero() && t.Before(w.start) {
+		return false
+	}
+	return w.end.IsZero() || t.Before(w.end)
+}
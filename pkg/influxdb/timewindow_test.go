@@ -0,0 +1,93 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+)
+
+func TestTimeWindowContains(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	testCases := map[string]struct {
+		start, end string
+		in, out    []time.Time
+	}{
+		"Open": {
+			in: []time.Time{start, end},
+		},
+		"Start": {
+			start: "2024-01-01T10:00:00Z",
+			in:    []time.Time{start, end},
+			out:   []time.Time{start.Add(-time.Nanosecond)},
+		},
+		"End": {
+			end: "2024-01-01T11:00:00Z",
+			in:  []time.Time{start, end.Add(-time.Nanosecond)},
+			out: []time.Time{end},
+		},
+		"Both": {
+			start: "2024-01-01T12:00:00+02:00",
+			end:   "2024-01-01T11:00:00Z",
+			in:    []time.Time{start, start.Add(30 * time.Minute)},
+			out:   []time.Time{start.Add(-time.Second), end},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			w, err := parseTimeWindow(tc.start, tc.end)
+			require.NoError(t, err)
+			for _, tm := range tc.in {
+				assert.True(t, w.contains(tm), tm)
+			}
+			for _, tm := range tc.out {
+				assert.False(t, w.contains(tm), tm)
+			}
+		})
+	}
+}
+
+func TestBatchFromSamplesTimeWindow(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	var samples metrics.Samples
+	// a sample each 10 minutes, from 9:00 to 11:50
+	for i := -6; i < 12; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: metric,
+				Tags:   registry.RootTagSet().With("method", "GET"),
+			},
+			Time:  start.Add(time.Duration(i) * 10 * time.Minute),
+			Value: float64(i),
+		})
+	}
+
+	for _, conf := range []string{
+		`{"timeWindowStart":"2024-01-01T10:00:00Z","timeWindowEnd":"2024-01-01T11:00:00Z"}`,
+		`{"timeWindowStart":"2024-01-01T10:00:00Z","timeWindowEnd":"2024-01-01T11:00:00Z","singleMeasurement":true}`,
+	} {
+		o := newTestOutput(t, conf)
+		points := o.batchFromSamples([]metrics.SampleContainer{samples})
+		require.Len(t, points, 6, conf)
+		for i, p := range points {
+			assert.Equal(t, start.Add(time.Duration(i)*10*time.Minute), p.Time(), conf)
+		}
+	}
+
+	o := newTestOutput(t, `{"timeWindowStart":"2024-01-01T11:30:00Z"}`)
+	points := o.batchFromSamples([]metrics.SampleContainer{samples})
+	require.Len(t, points, 3)
+	assert.Equal(t, start.Add(90*time.Minute), points[0].Time())
+}
@@ -0,0 +1,56 @@
+package influxdb
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"path"
+)
+
+// checkHostPatterns returns an error if any of the host patterns is malformed.
+func checkHostPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("an invalid host pattern (%s) is specified for InsecureSkipTLSVerifyHosts: %w",
+				pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchHost reports if the host matches any of the patterns.
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		// the patterns are already validated
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// insecureHostsDialer returns a TLS dialer that skips the certificate verification
+// only for the hosts matching the patterns, the other hosts are verified as usual.
+// The host is known only at dial time, the tls.Config's VerifyConnection can't be used
+// since the server name isn't set for the IP addresses.
+func insecureHostsDialer(
+	base *tls.Config, netDialer *net.Dialer, patterns []string,
+) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := base.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{} //nolint:gosec
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+		cfg.InsecureSkipVerify = matchHost(patterns, host) //nolint:gosec
+		d := &tls.Dialer{NetDialer: netDialer, Config: cfg}
+		return d.DialContext(ctx, network, addr)
+	}
+}
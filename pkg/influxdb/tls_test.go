@@ -0,0 +1,101 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputInsecureSkipTLSVerifyHosts(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	// both the servers have a self-signed certificate
+	internal := httptest.NewTLSServer(handler)
+	t.Cleanup(internal.Close)
+	external := httptest.NewTLSServer(handler)
+	t.Cleanup(external.Close)
+
+	// the internal server is reached using the localhost name
+	u, err := url.Parse(internal.URL)
+	require.NoError(t, err)
+	internalURL := "https://localhost:" + u.Port()
+
+	tests := map[string]struct {
+		config         string
+		expInternalErr bool
+		expExternalErr bool
+	}{
+		"Verified": {
+			config:         `{}`,
+			expInternalErr: true,
+			expExternalErr: true,
+		},
+		"HostPattern": {
+			config:         `{"insecureSkipTLSVerifyHosts":["local*"]}`,
+			expExternalErr: true,
+		},
+		"IPAddress": {
+			config:         `{"insecureSkipTLSVerifyHosts":["127.0.0.1"]}`,
+			expInternalErr: true,
+		},
+		"Blanket": {
+			config: `{"insecureSkipTLSVerify":true,"insecureSkipTLSVerifyHosts":["example.com"]}`,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			o, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: internalURL + "/testbucket",
+				JSONConfig:     json.RawMessage(tc.config),
+			})
+			require.NoError(t, err)
+			client := o.client.Options().HTTPClient()
+
+			for target, expErr := range map[string]bool{internalURL: tc.expInternalErr, external.URL: tc.expExternalErr} {
+				resp, err := client.Get(target) //nolint:noctx
+				if expErr {
+					require.Error(t, err, target)
+					assert.Contains(t, err.Error(), "certificate", target)
+					continue
+				}
+				require.NoError(t, err, target)
+				_ = resp.Body.Close()
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestCheckHostPatterns(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, checkHostPatterns([]string{"influxdb.internal", "*.local"}))
+	err := checkHostPatterns([]string{"[local"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "an invalid host pattern ([local)")
+}
+
+func TestMatchHost(t *testing.T) {
+	t.Parallel()
+
+	patterns := []string{"influxdb.internal", "*.local"}
+	assert.True(t, matchHost(patterns, "influxdb.internal"))
+	assert.True(t, matchHost(patterns, "db.local"))
+	assert.False(t, matchHost(patterns, "db.local.example.com"))
+	assert.False(t, matchHost(patterns, "influxdb.example.com"))
+	assert.False(t, matchHost(patterns, ""))
+}
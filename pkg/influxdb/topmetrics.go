@@ -0,0 +1,70 @@
+package influxdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/sirupsen/logrus"
+)
+
+// metricPoints is the number of the points written for a metric in a flush.
+type metricPoints struct {
+	metric string
+	points int
+}
+
+func (m metricPoints) String() string {
+	return fmt.Sprintf("%s=%d", m.metric, m.points)
+}
+
+// topMetrics returns the n metrics with the most points in the batch,
+// ordered by points and then by name. The points are counted by measurement,
+// with SingleMeasurement each metric's field of a point is counted instead.
+func topMetrics(batch []*write.Point, n int, singleMeasurement bool) []metricPoints {
+	counts := make(map[string]int)
+	for _, p := range batch {
+		if !singleMeasurement {
+			counts[p.Name()]++
+			continue
+		}
+		for _, f := range p.FieldList() {
+			counts[f.Key]++
+		}
+	}
+
+	top := make([]metricPoints, 0, len(counts))
+	for metric, points := range counts {
+		top = append(top, metricPoints{metric: metric, points: points})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].points != top[j].points {
+			return top[i].points > top[j].points
+		}
+		return top[i].metric < top[j].metric
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// logTopMetrics logs at the debug level the metrics with the most points in the batch,
+// the breakdown is computed only when LogTopMetrics is set and the debug level is enabled.
+func (o *Output) logTopMetrics(batch []*write.Point) {
+	n := int(o.config.LogTopMetrics.Int64)
+	if n <= 0 || len(batch) == 0 {
+		return
+	}
+	if e, ok := o.logger.(*logrus.Entry); ok && !e.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+	top := topMetrics(batch, n, o.config.SingleMeasurement.Bool)
+	names := make([]string, 0, len(top))
+	for _, m := range top {
+		names = append(names, m.String())
+	}
+	o.logger.WithField("points", len(batch)).WithField("metrics", strings.Join(names, ", ")).
+		Debug("Top metrics by points in the flush")
+}
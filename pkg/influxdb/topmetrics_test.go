@@ -0,0 +1,100 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func skewedSamples(t *testing.T) metrics.Samples {
+	registry := metrics.NewRegistry()
+	var samples metrics.Samples
+	now := time.Now()
+	for name, n := range map[string]int{
+		"http_req_duration": 6,
+		"http_reqs":         3,
+		"vus":               1,
+		"iterations":        3,
+	} {
+		m, err := registry.NewMetric(name, metrics.Counter)
+		require.NoError(t, err)
+		for i := 0; i < n; i++ {
+			samples = append(samples, metrics.Sample{
+				TimeSeries: metrics.TimeSeries{Metric: m, Tags: registry.RootTagSet()},
+				Time:       now.Add(time.Duration(i) * time.Second),
+				Value:      1,
+			})
+		}
+	}
+	return samples
+}
+
+func TestTopMetrics(t *testing.T) {
+	t.Parallel()
+
+	samples := skewedSamples(t)
+	for name, conf := range map[string]string{
+		"Measurements":      `{}`,
+		"SingleMeasurement": `{"singleMeasurement":true}`,
+	} {
+		conf := conf
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestOutput(t, conf)
+			batch := o.batchFromSamples([]metrics.SampleContainer{samples})
+			if o.config.SingleMeasurement.Bool {
+				batch = o.combinedBatchFromSamples([]metrics.SampleContainer{samples})
+			}
+			assert.Equal(t, []metricPoints{
+				{metric: "http_req_duration", points: 6},
+				{metric: "http_reqs", points: 3},
+				{metric: "iterations", points: 3},
+			}, topMetrics(batch, 3, o.config.SingleMeasurement.Bool))
+		})
+	}
+}
+
+func TestOutputLogTopMetrics(t *testing.T) {
+	t.Parallel()
+
+	for name, level := range map[string]logrus.Level{
+		"Debug": logrus.DebugLevel,
+		"Info":  logrus.InfoLevel,
+	} {
+		level := level
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			logger, hook := logtest.NewNullLogger()
+			logger.SetLevel(level)
+			o, err := New(output.Params{
+				Logger:         logger,
+				ConfigArgument: "http://localhost:8086/testbucket",
+				JSONConfig:     json.RawMessage(`{"logTopMetrics":2}`),
+			})
+			require.NoError(t, err)
+
+			o.logTopMetrics(o.batchFromSamples([]metrics.SampleContainer{skewedSamples(t)}))
+			var logged []string
+			for _, e := range hook.AllEntries() {
+				if e.Message == "Top metrics by points in the flush" {
+					assert.Equal(t, 13, e.Data["points"])
+					logged = append(logged, e.Data["metrics"].(string)) //nolint:forcetypeassert
+				}
+			}
+			if level == logrus.DebugLevel {
+				assert.Equal(t, []string{"http_req_duration=6, http_reqs=3"}, logged)
+			} else {
+				assert.Empty(t, logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package influxdb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"unicode/utf8"
+)
+
+// The suffixes of the tag values truncated by MaxTagValueLength.
+const (
+	// TagTruncationEllipsis ends the truncated value with an ellipsis.
+	TagTruncationEllipsis = "ellipsis"
+	// TagTruncationHash ends the truncated value with a hash of the full value,
+	// so the values with the same prefix are still distinct series.
+	TagTruncationHash = "hash"
+)
+
+const (
+	ellipsis = "…"
+	// the hash's suffix is a tilde followed by the 8 hexadecimal digits of the FNV-1a hash
+	tagHashSuffixLen = 9
+)
+
+// checkTagValueTruncation returns an error if the tag values can't be truncated as configured,
+// a value can't be shorter than its suffix.
+func checkTagValueTruncation(c Config) error {
+	maxLen := c.MaxTagValueLength.Int64
+	if maxLen < 0 {
+		return fmt.Errorf("the MaxTagValueLength option can't be negative")
+	}
+	var suffixLen int64
+	switch c.TagValueTruncation.String {
+	case TagTruncationEllipsis:
+		suffixLen = 1
+	case TagTruncationHash:
+		suffixLen = tagHashSuffixLen
+	default:
+		return fmt.Errorf("an invalid tag value truncation (%s) is specified, the allowed values are: %s and %s",
+			c.TagValueTruncation.String, TagTruncationEllipsis, TagTruncationHash)
+	}
+	if maxLen > 0 && maxLen <= suffixLen {
+		return fmt.Errorf("the MaxTagValueLength option must be greater than %d with the %s truncation",
+			suffixLen, c.TagValueTruncation.String)
+	}
+	return nil
+}
+
+// truncateTagValues truncates the tag values longer than MaxTagValueLength characters.
+func (o *Output) truncateTagValues(tags map[string]string) {
+	maxLen := int(o.config.MaxTagValueLength.Int64)
+	if maxLen == 0 {
+		return
+	}
+	for k, v := range tags {
+		tags[k] = truncateTagValue(v, maxLen, o.config.TagValueTruncation.String)
+	}
+}
+
+// truncateTagValue returns the value truncated to maxLen characters, including the suffix.
+func truncateTagValue(v string, maxLen int, mode string) string {
+	if utf8.RuneCountInString(v) <= maxLen {
+		return v
+	}
+	keep := maxLen - 1
+	if mode == TagTruncationHash {
+		keep = maxLen - tagHashSuffixLen
+	}
+	// the byte offset of the first dropped character
+	end := 0
+	for i := 0; i < keep; i++ {
+		_, size := utf8.DecodeRuneInString(v[end:])
+		end += size
+	}
+	if mode == TagTruncationHash {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(v))
+		return fmt.Sprintf("%s~%08x", v[:end], h.Sum32())
+	}
+	return v[:end] + ellipsis
+}
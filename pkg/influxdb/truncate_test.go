@@ -0,0 +1,83 @@
+package influxdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+)
+
+func TestTruncateTagValue(t *testing.T) {
+	t.Parallel()
+
+	long := "http://test.k6.io/" + strings.Repeat("a", 100)
+	testdata := map[string]struct {
+		value    string
+		maxLen   int
+		mode     string
+		expected string
+	}{
+		"short":           {"http://test.k6.io", 20, TagTruncationEllipsis, "http://test.k6.io"},
+		"max length":      {"abcdef", 6, TagTruncationEllipsis, "abcdef"},
+		"ellipsis":        {"abcdefgh", 6, TagTruncationEllipsis, "abcde…"},
+		"multi-byte":      {"àèìòùàèìòù", 4, TagTruncationEllipsis, "àèì…"},
+		"hash":            {long, 20, TagTruncationHash, `^http://test~[0-9a-f]{8}$`},
+		"short with hash": {"abc", 20, TagTruncationHash, "abc"},
+	}
+	for name, tc := range testdata {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := truncateTagValue(tc.value, tc.maxLen, tc.mode)
+			if tc.mode == TagTruncationHash && got != tc.value {
+				assert.Regexp(t, tc.expected, got)
+			} else {
+				assert.Equal(t, tc.expected, got)
+			}
+			assert.LessOrEqual(t, utf8.RuneCountInString(got), tc.maxLen)
+		})
+	}
+
+	// the values with the same prefix are still distinct
+	assert.NotEqual(t,
+		truncateTagValue(long+"1", 20, TagTruncationHash),
+		truncateTagValue(long+"2", 20, TagTruncationHash))
+}
+
+func TestBatchFromSamplesMaxTagValueLength(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_req_duration", metrics.Trend)
+	require.NoError(t, err)
+	url := "http://test.k6.io/" + strings.Repeat("x", 50)
+	samples := metrics.Samples{{
+		TimeSeries: metrics.TimeSeries{
+			Metric: metric,
+			Tags: registry.RootTagSet().WithTagsFromMap(map[string]string{
+				"name": url, "url": url, "method": "GET",
+			}),
+		},
+		Time:  time.Now(),
+		Value: 1,
+	}}
+
+	o := newTestOutput(t, `{"maxTagValueLength":20,"tagsAsFields":["url"]}`)
+	points := o.batchFromSamples([]metrics.SampleContainer{samples})
+	require.Len(t, points, 1)
+	tags := map[string]string{}
+	for _, tag := range points[0].TagList() {
+		tags[tag.Key] = tag.Value
+	}
+	assert.Equal(t, map[string]string{"name": "http://test.k6.io/x…", "method": "GET"}, tags)
+	for _, f := range points[0].FieldList() {
+		if f.Key == "url" {
+			assert.Equal(t, url, f.Value, "the field values are expected to be unaffected")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package influxdb
+
+import (
+	"context"
+	"net"
+	"net/url"
+)
+
+// unixScheme is the scheme of a Unix domain socket's address, e.g. unix:///var/run/influxdb/influxd.sock.
+const unixScheme = "unix"
+
+// unixSocketHTTPAddr is the address of the HTTP requests sent over the Unix domain socket,
+// the host is only used for the Host header, the connection is always to the socket.
+const unixSocketHTTPAddr = "http://localhost"
+
+// unixSocketPath returns the socket's path if the address is a Unix domain socket.
+func unixSocketPath(addr string) (string, bool) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme != unixScheme {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// httpAddr returns the address used by the HTTP client for the configured address.
+func httpAddr(addr string) string {
+	if _, ok := unixSocketPath(addr); ok {
+		return unixSocketHTTPAddr
+	}
+	return addr
+}
+
+// unixSocketDialer returns a DialContext function that connects to the socket,
+// whatever is the requested address.
+func unixSocketDialer(dialer *net.Dialer, socket string) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
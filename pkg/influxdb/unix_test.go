@@ -0,0 +1,51 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "influxd.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	lc := &lineCollector{}
+	ts := httptest.NewUnstartedServer(lc)
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "unix://" + socket,
+		JSONConfig:     json.RawMessage(`{"bucket":"testbucket"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "unix://"+socket, o.config.Addr.String)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_counter", metrics.Counter)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+		Time:       time.Unix(1, 0),
+		Value:      1,
+	}})
+	require.NoError(t, o.Flush())
+	require.NoError(t, o.Stop())
+
+	assert.Equal(t, []string{"test_counter value=1 1000000000"}, lc.Lines())
+}
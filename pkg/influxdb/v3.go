@@ -0,0 +1,164 @@
+package influxdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	http2 "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+const (
+	// FlavorV2 is the InfluxDB v2 API, it is compatible with v1.8+.
+	FlavorV2 = "v2"
+	// FlavorV3 is the InfluxDB 3 API, where the bucket is the database.
+	FlavorV3 = "v3"
+	// FlavorTelegraf is the raw line protocol sent over TCP or UDP, e.g. to a Telegraf's socket_listener.
+	FlavorTelegraf = "telegraf"
+)
+
+// v3WritePath is the InfluxDB 3's endpoint for writing the line protocol.
+const v3WritePath = "/api/v3/write_lp"
+
+// pointsWriter writes the points, or the lines already in the line protocol, synchronously.
+type pointsWriter interface {
+	WritePoint(ctx context.Context, point ...*write.Point) error
+	WriteRecord(ctx context.Context, line ...string) error
+}
+
+// v3Writer writes the points using the InfluxDB 3's write endpoint.
+type v3Writer struct {
+	client    *http.Client
+	url       string
+	token     string
+	precision time.Duration
+	gzip      bool
+}
+
+func newV3Writer(
+	client *http.Client, addr, database, token string, precision time.Duration, useGzip bool,
+) (*v3Writer, error) {
+	p, err := v3Precision(precision)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("db", database)
+	query.Set("precision", p)
+	return &v3Writer{
+		client:    client,
+		url:       strings.TrimSuffix(addr, "/") + v3WritePath + "?" + query.Encode(),
+		token:     token,
+		precision: precision,
+		gzip:      useGzip,
+	}, nil
+}
+
+// v3Precision returns the write API's precision of the duration,
+// InfluxDB 3 supports only the standard units.
+func v3Precision(precision time.Duration) (string, error) {
+	switch precision {
+	case time.Nanosecond:
+		return "nanosecond", nil
+	case time.Microsecond:
+		return "microsecond", nil
+	case time.Millisecond:
+		return "millisecond", nil
+	case time.Second:
+		return "second", nil
+	default:
+		return "", fmt.Errorf("the precision (%s) isn't supported by InfluxDB 3, "+
+			"the allowed values are: 1ns, 1us, 1ms and 1s", precision)
+	}
+}
+
+// WritePoint writes the points in a single request.
+// The server's error response is returned as an http2.Error,
+// the same as the v2 writer, so it is reported in the same way.
+func (w *v3Writer) WritePoint(ctx context.Context, points ...*write.Point) error {
+	lines, err := encodeLines(points, w.precision)
+	if err != nil {
+		return err
+	}
+	return w.send(ctx, bytes.Join(lines, nil))
+}
+
+// WriteRecord writes the lines in a single request, the same as WritePoint.
+func (w *v3Writer) WriteRecord(ctx context.Context, lines ...string) error {
+	return w.send(ctx, bytes.Join(recordLines(lines), nil))
+}
+
+func (w *v3Writer) send(ctx context.Context, lines []byte) error {
+	body := bytes.NewBuffer(lines)
+	if w.gzip {
+		body = &bytes.Buffer{}
+		zw := gzip.NewWriter(body)
+		if _, err := zw.Write(lines); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	serr := http2.NewError(nil)
+	serr.StatusCode = resp.StatusCode
+	serr.Code = resp.Status
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if retryAfter, err := strconv.ParseUint(v, 10, 32); err == nil {
+			serr.RetryAfter = uint(retryAfter)
+		}
+	}
+	serr.Message = strings.TrimSpace(string(respBody))
+	if serr.Message == "" {
+		serr.Message = http.StatusText(resp.StatusCode)
+	}
+	return serr
+}
+
+// recordLines returns the lines terminated by a new line, as required by the line protocol.
+func recordLines(records []string) [][]byte {
+	lines := make([][]byte, 0, len(records))
+	for _, r := range records {
+		line := []byte(r)
+		if !strings.HasSuffix(r, "\n") {
+			line = append(line, '\n')
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,133 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+)
+
+func TestOutputFlavorV3(t *testing.T) {
+	t.Parallel()
+
+	var (
+		reqPath, reqQuery, reqAuth string
+		reqBody                    []byte
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		reqPath, reqQuery, reqAuth = r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization")
+		var err error
+		reqBody, err = io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/mydb",
+		JSONConfig:     json.RawMessage(`{"flavor":"v3","token":"secret","precision":"1ms"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Samples{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+			Time:       time.UnixMilli(1700000000123),
+			Value:      1,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet().With("status", "200")},
+			Time:       time.UnixMilli(1700000000456),
+			Value:      2,
+		},
+	}})
+	require.NoError(t, o.Flush())
+	require.NoError(t, o.Stop())
+
+	assert.Equal(t, "/api/v3/write_lp", reqPath)
+	assert.Equal(t, "db=mydb&precision=millisecond", reqQuery)
+	assert.Equal(t, "Token secret", reqAuth)
+	assert.Equal(t, "http_reqs value=1 1700000000123\nhttp_reqs,status=200 value=2 1700000000456\n", string(reqBody))
+}
+
+func TestOutputFlavorV3WriteError(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"error":"parsing failed for write_lp endpoint"}`))
+	}))
+	defer ts.Close()
+
+	o, err := New(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: ts.URL + "/mydb",
+		JSONConfig:     json.RawMessage(`{"flavor":"v3"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+	defer func() {
+		require.NoError(t, o.Stop())
+	}()
+
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("http_reqs", metrics.Counter)
+	require.NoError(t, err)
+	o.AddMetricSamples([]metrics.SampleContainer{metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+		Time:       time.Now(),
+		Value:      1,
+	}})
+	err = o.Flush()
+	require.Error(t, err)
+	assert.Equal(t, "400 Bad Request: {\"error\":\"parsing failed for write_lp endpoint\"}", err.Error())
+	assert.Equal(t, 400, serverErrorFields(err)["status"])
+}
+
+func TestNewFlavor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config string
+		expErr string
+	}{
+		"V2":                {config: `{"flavor":"v2"}`},
+		"V3":                {config: `{"flavor":"v3"}`},
+		"Invalid":           {config: `{"flavor":"v4"}`, expErr: "an invalid flavor (v4)"},
+		"V3AsyncWrite":      {config: `{"flavor":"v3","asyncWrite":true}`, expErr: "AsyncWrite option isn't supported"},
+		"V3CreateBucket":    {config: `{"flavor":"v3","createBucket":true}`, expErr: "CreateBucket option isn't supported"},
+		"V3InvalidPrecison": {config: `{"flavor":"v3","precision":"10ms"}`, expErr: "precision (10ms) isn't supported"},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(output.Params{
+				Logger:         testutils.NewLogger(t),
+				ConfigArgument: "http://localhost:8086/mydb",
+				JSONConfig:     json.RawMessage(tc.config),
+			})
+			if tc.expErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package influxdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// walSegmentExt is the extension of the write-ahead log's segments, a segment is a gzipped batch of line protocol.
+const walSegmentExt = ".lp.gz"
+
+// checkWAL returns an error if WALDir is set with an option that writes the points
+// after the flush or to more organizations, that a segment can't track.
+func checkWAL(c Config) error {
+	if c.WALDir.String == "" {
+		return nil
+	}
+	if c.AsyncWrite.Bool {
+		return fmt.Errorf("the WALDir option isn't supported with AsyncWrite, since the write's outcome isn't known")
+	}
+	if c.OrgFromTag.String != "" {
+		return fmt.Errorf("the WALDir option isn't supported with OrgFromTag")
+	}
+	return nil
+}
+
+// writeAheadLog stores each flushed batch in a segment before it is written,
+// the segment is removed when all the batch's points are written. The segments left
+// by a crash or by the failed writes can be recovered with ReplayWAL.
+type writeAheadLog struct {
+	dir       string
+	precision time.Duration
+	seq       atomic.Uint64
+}
+
+// open creates the directory, if it doesn't exist, and returns the number
+// of the segments left by the previous runs.
+func (wal *writeAheadLog) open() (int, error) {
+	if err := os.MkdirAll(wal.dir, 0o700); err != nil { //nolint:forbidigo
+		return 0, fmt.Errorf("the WAL directory can't be created: %w", err)
+	}
+	segments, err := walSegments(wal.dir)
+	if err != nil {
+		return 0, err
+	}
+	return len(segments), nil
+}
+
+// append stores the points' lines in a new segment and returns its path, the points that
+// can't be encoded are skipped, as InfluxDB would reject them. The segment is synced
+// to the disk and then renamed, so a crash never leaves a truncated segment.
+func (wal *writeAheadLog) append(points []*write.Point, now time.Time) (string, error) {
+	name := fmt.Sprintf("%020d-%06d%s", now.UnixNano(), wal.seq.Add(1), walSegmentExt)
+	path := filepath.Join(wal.dir, name)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) //nolint:forbidigo
+	if err != nil {
+		return "", fmt.Errorf("the WAL segment can't be created: %w", err)
+	}
+	if err := wal.writeSegment(f, points); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp) //nolint:forbidigo
+		return "", fmt.Errorf("the WAL segment can't be written: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp) //nolint:forbidigo
+		return "", fmt.Errorf("the WAL segment can't be written: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil { //nolint:forbidigo
+		_ = os.Remove(tmp) //nolint:forbidigo
+		return "", fmt.Errorf("the WAL segment can't be committed: %w", err)
+	}
+	return path, nil
+}
+
+func (wal *writeAheadLog) writeSegment(f *os.File, points []*write.Point) error { //nolint:forbidigo
+	zw := gzip.NewWriter(f)
+	// the segment is replayed with its precision, whatever is the precision of the replay's config
+	if _, err := io.WriteString(zw, precisionMarker(wal.precision)); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	e := newLineEncoder(&buf, wal.precision)
+	for _, p := range points {
+		buf.Reset()
+		if _, err := e.Encode(sortedKeys(p)); err != nil {
+			continue
+		}
+		if _, err := zw.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// appendWAL stores the batch in the write-ahead log, if it is enabled, and returns the segment's path.
+// A failure is only logged, the batch is still written.
+func (o *Output) appendWAL(batch []*write.Point) string {
+	if o.wal == nil || len(batch) == 0 {
+		return ""
+	}
+	path, err := o.wal.append(batch, o.clock.Now())
+	if err != nil {
+		o.logger.WithError(err).WithField("points", len(batch)).
+			Error("Couldn't store the metrics points in the WAL, they can't be recovered if the write fails")
+		return ""
+	}
+	return path
+}
+
+// ackWAL removes the segment of a batch whose points have been all written.
+func (o *Output) ackWAL(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil { //nolint:forbidigo
+		o.logger.WithError(err).WithField("segment", path).
+			Warn("Couldn't remove the WAL segment of the written points, replaying it writes them again")
+	}
+}
+
+// walSegments returns the paths of the directory's segments, from the oldest.
+func walSegments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir) //nolint:forbidigo
+	if err != nil {
+		return nil, fmt.Errorf("the WAL directory can't be read: %w", err)
+	}
+	var segments []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), walSegmentExt) {
+			segments = append(segments, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// the names start with the zero-padded time of the flush
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// ReplayWAL writes the segments left in the write-ahead log's directory, e.g. by a crash
+// or by the failed writes, to the destination of the config, from the oldest, as ReplayFile does.
+// A segment is removed once it is written, it stops at the first segment that can't be written,
+// so it can be called again. The points of a segment written again overwrite themselves,
+// since they have the same series and timestamps.
+func ReplayWAL(ctx context.Context, cfg Config, dir string) error {
+	segments, err := walSegments(dir)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if err := ReplayFile(ctx, cfg, segment); err != nil {
+			return fmt.Errorf("the WAL segment %s can't be replayed: %w", filepath.Base(segment), err)
+		}
+		if err := os.Remove(segment); err != nil { //nolint:forbidigo
+			return fmt.Errorf("the replayed WAL segment can't be removed: %w", err)
+		}
+	}
+	return nil
+}
+
+// replayReader returns the reader of the replayed file's lines, a gzipped file, e.g. a WAL segment,
+// is decompressed.
+func replayReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("the replayed file can't be decompressed: %w", err)
+		}
+		return zr, nil
+	}
+	return br, nil
+}
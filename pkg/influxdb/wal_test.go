@@ -0,0 +1,203 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/metrics"
+	"go.k6.io/k6/output"
+	"gopkg.in/guregu/null.v3"
+)
+
+func walSamples(t *testing.T) []metrics.SampleContainer {
+	registry := metrics.NewRegistry()
+	metric, err := registry.NewMetric("test_gauge", metrics.Gauge)
+	require.NoError(t, err)
+	samples := make(metrics.Samples, 0, 3)
+	for i := 1; i <= 3; i++ {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: metric, Tags: registry.RootTagSet()},
+			Time:       time.Unix(int64(i), 0),
+			Value:      float64(i),
+		})
+	}
+	return []metrics.SampleContainer{samples}
+}
+
+func TestOutputWAL(t *testing.T) {
+	t.Parallel()
+
+	testdata := map[string]struct {
+		failing  string
+		segments int
+	}{
+		"written": {segments: 0},
+		"failed":  {failing: "testbucket", segments: 1},
+	}
+	for name, tc := range testdata {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bc := &bucketCollector{failing: tc.failing}
+			ts := httptest.NewServer(bc)
+			defer ts.Close()
+
+			dir := filepath.Join(t.TempDir(), "wal")
+			jsonConf, err := json.Marshal(map[string]interface{}{"walDir": dir})
+			require.NoError(t, err)
+			logger, _ := logtest.NewNullLogger()
+			o, err := New(output.Params{
+				Logger:         logger,
+				ConfigArgument: ts.URL + "/testbucket",
+				JSONConfig:     jsonConf,
+			})
+			require.NoError(t, err)
+			require.NoError(t, o.Start())
+			o.AddMetricSamples(walSamples(t))
+			require.NoError(t, o.Stop())
+
+			segments, err := walSegments(dir)
+			require.NoError(t, err)
+			assert.Len(t, segments, tc.segments)
+			if tc.failing == "" {
+				assert.Len(t, bc.Lines("testbucket"), 3)
+				return
+			}
+			assert.Empty(t, bc.Lines("testbucket"))
+
+			// the failed batch is recovered by the replay
+			recovered := &bucketCollector{}
+			rs := httptest.NewServer(recovered)
+			defer rs.Close()
+			err = ReplayWAL(context.Background(), Config{
+				Addr:   null.StringFrom(rs.URL),
+				Bucket: null.StringFrom("testbucket"),
+			}, dir)
+			require.NoError(t, err)
+			assert.Equal(t, []string{
+				"test_gauge value=1 1000000000",
+				"test_gauge value=2 2000000000",
+				"test_gauge value=3 3000000000",
+			}, recovered.Lines("testbucket"))
+
+			segments, err = walSegments(dir)
+			require.NoError(t, err)
+			assert.Empty(t, segments)
+		})
+	}
+}
+
+func TestReplayWALAfterCrash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	wal := &writeAheadLog{dir: dir, precision: time.Nanosecond}
+	_, err := wal.open()
+	require.NoError(t, err)
+
+	// the batches are stored before their writes, that never happen since the process crashes
+	point := func(v int) *write.Point {
+		return write.NewPoint("test_counter", nil, map[string]interface{}{"value": v}, time.Unix(int64(v), 0))
+	}
+	_, err = wal.append([]*write.Point{point(3)}, time.Unix(20, 0))
+	require.NoError(t, err)
+	_, err = wal.append([]*write.Point{point(1), point(2)}, time.Unix(10, 0))
+	require.NoError(t, err)
+	// a segment whose append has been interrupted is never committed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000030-000003.lp.gz.tmp"), //nolint:forbidigo
+		[]byte("test_counter value=4i"), 0o600))
+
+	bc := &bucketCollector{}
+	ts := httptest.NewServer(bc)
+	defer ts.Close()
+
+	// the restarted output reports the unwritten batches
+	logger, hook := logtest.NewNullLogger()
+	o, err := New(output.Params{
+		Logger:         logger,
+		ConfigArgument: ts.URL + "/testbucket",
+		JSONConfig:     json.RawMessage(`{"walDir":"` + dir + `"}`),
+	})
+	require.NoError(t, err)
+	require.NoError(t, o.Start())
+	require.NoError(t, o.Stop())
+	var warned bool
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && e.Data["segments"] == 2 {
+			warned = true
+		}
+	}
+	assert.True(t, warned, "the unwritten segments are expected to be reported")
+
+	err = ReplayWAL(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"test_counter value=1i 1000000000",
+		"test_counter value=2i 2000000000",
+		"test_counter value=3i 3000000000",
+	}, bc.Lines("testbucket"))
+	segments, err := walSegments(dir)
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+}
+
+func TestReplayWALPrecision(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	wal := &writeAheadLog{dir: dir, precision: time.Second}
+	_, err := wal.append([]*write.Point{
+		write.NewPoint("test_counter", nil, map[string]interface{}{"value": 1}, time.Unix(1, 0)),
+	}, time.Unix(1, 0))
+	require.NoError(t, err)
+
+	// the replay's config has the default precision, the nanoseconds
+	pc := &precisionCollector{}
+	ts := httptest.NewServer(pc)
+	defer ts.Close()
+	err = ReplayWAL(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s: test_counter value=1i 1"}, pc.Writes())
+}
+
+func TestReplayWALError(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	wal := &writeAheadLog{dir: dir, precision: time.Nanosecond}
+	_, err := wal.append([]*write.Point{
+		write.NewPoint("test_counter", nil, map[string]interface{}{"value": 1}, time.Unix(1, 0)),
+	}, time.Unix(1, 0))
+	require.NoError(t, err)
+
+	bc := &bucketCollector{failing: "testbucket"}
+	ts := httptest.NewServer(bc)
+	defer ts.Close()
+	err = ReplayWAL(context.Background(), Config{
+		Addr:   null.StringFrom(ts.URL),
+		Bucket: null.StringFrom("testbucket"),
+	}, dir)
+	assert.ErrorContains(t, err, "the WAL segment 00000000001000000000-000001.lp.gz can't be replayed")
+
+	// the segment is kept for the next replay
+	segments, err := walSegments(dir)
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
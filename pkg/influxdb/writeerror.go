@@ -0,0 +1,44 @@
+package influxdb
+
+import (
+	"sync"
+)
+
+// WriteErrorCallback is called when a write of the metrics' points fails, with the write's error
+// and the number of the points that haven't been written, e.g. for alerting from an external system.
+// The points are zero when they aren't known, as for the errors of AsyncWrite. It isn't called
+// for the cancelled writes nor for the writes to the mirror buckets. It is called by the concurrent
+// writes, so it must be safe for concurrent use, and it should return quickly, since the write waits for it.
+type WriteErrorCallback func(err error, points int)
+
+var ( //nolint:gochecknoglobals // the callback is set by the extensions before the outputs are created
+	defaultWriteErrorCallbackMu sync.RWMutex
+	defaultWriteErrorCallback   WriteErrorCallback
+)
+
+// SetOnWriteError sets the OnWriteError callback of the outputs created after the call,
+// from the init function of a custom k6 build's package, as SetPointMutator:
+//
+//	func init() {
+//		influxdb.SetOnWriteError(func(err error, points int) {
+//			alerts.Trigger(fmt.Sprintf("%d points haven't been written to InfluxDB: %v", points, err))
+//		})
+//	}
+func SetOnWriteError(cb WriteErrorCallback) {
+	defaultWriteErrorCallbackMu.Lock()
+	defer defaultWriteErrorCallbackMu.Unlock()
+	defaultWriteErrorCallback = cb
+}
+
+func getWriteErrorCallback() WriteErrorCallback {
+	defaultWriteErrorCallbackMu.RLock()
+	defer defaultWriteErrorCallbackMu.RUnlock()
+	return defaultWriteErrorCallback
+}
+
+// notifyWriteError calls the OnWriteError callback, if it is set.
+func (o *Output) notifyWriteError(err error, points int) {
+	if o.OnWriteError != nil {
+		o.OnWriteError(err, points)
+	}
+}